@@ -0,0 +1,13 @@
+package blocklist_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestBlocklist(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Blocklist Suite")
+}
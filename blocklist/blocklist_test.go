@@ -0,0 +1,153 @@
+package blocklist_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/denisvmedia/urlshortener/blocklist"
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Blocklist", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "blocklist")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	var writeFile = func(name, content string) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(Succeed())
+		return path
+	}
+
+	var hashPrefix = func(expression string, length int) string {
+		sum := sha256.Sum256([]byte(expression))
+		return hex.EncodeToString(sum[:])[:length]
+	}
+
+	It("builds the url expressions", func() {
+		u, err := url.Parse("http://a.b.c/1/2.html?param=1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(blocklist.Expressions(u)).To(ConsistOf(
+			"a.b.c/1/2.html?param=1", "a.b.c/1/2.html", "a.b.c/", "a.b.c/1/",
+			"b.c/1/2.html?param=1", "b.c/1/2.html", "b.c/", "b.c/1/",
+		))
+
+		u, err = url.Parse("http://1.2.3.4/")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(blocklist.Expressions(u)).To(ConsistOf("1.2.3.4/"))
+	})
+
+	It("checks the urls against the domains and the hash prefixes", func() {
+		domains := writeFile("domains.txt", "# phishing\nevil.com\n\nBad.Example.org.\nbücher.example\n")
+		hashes := writeFile("hashes.txt", hashPrefix("example.net/malware/", 8)+"\n"+hashPrefix("1.2.3.4/x.exe", 64)+"\n")
+		list, err := blocklist.Load([]string{domains}, []string{hashes})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(list.Len()).To(Equal(5))
+
+		for _, rawURL := range []string{
+			"https://evil.com/",
+			"https://login.evil.com/account",
+			"https://bad.example.org/",
+			"https://www.example.net/malware/payload.zip?x=1",
+			"http://1.2.3.4/x.exe",
+			"https://shop.BÜCHER.example/",
+			"https://xn--bcher-kva.example/",
+		} {
+			reason, blocked := list.Check(rawURL)
+			Expect(blocked).To(BeTrue(), rawURL)
+			Expect(reason).ToNot(BeEmpty())
+		}
+		for _, rawURL := range []string{
+			"https://notevil.com/",
+			"https://example.org/",
+			"https://example.net/",
+			"https://example.net/malware",
+			"http://1.2.3.4/y.exe",
+		} {
+			_, blocked := list.Check(rawURL)
+			Expect(blocked).To(BeFalse(), rawURL)
+		}
+	})
+
+	It("fails on the invalid files", func() {
+		_, err := blocklist.Load([]string{filepath.Join(dir, "missing.txt")}, nil)
+		Expect(err).To(MatchError(ContainSubstring("can't read blocklist file")))
+
+		hashes := writeFile("hashes.txt", "abcd\n")
+		_, err = blocklist.Load(nil, []string{hashes})
+		Expect(err).To(MatchError(ContainSubstring("hashes.txt:1: invalid hash prefix abcd")))
+	})
+
+	It("flags the links which destinations become malicious", func() {
+		s := linkstorage.NewInMemoryStorage()
+		good, err := s.Insert(model.Link{ShortName: "good", OriginalURL: "https://example.com/"})
+		Expect(err).ToNot(HaveOccurred())
+		bad, err := s.Insert(model.Link{ShortName: "bad", OriginalURL: "https://phish.example.org/login"})
+		Expect(err).ToNot(HaveOccurred())
+
+		domains := writeFile("domains.txt", "")
+		scanner, err := blocklist.NewScanner(s, []string{domains}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(scanner.Scan()).To(Succeed())
+		_, flagged := scanner.Flagged(bad)
+		Expect(flagged).To(BeFalse())
+
+		writeFile("domains.txt", "phish.example.org\n")
+		_, blocked := scanner.Check("https://phish.example.org/")
+		Expect(blocked).To(BeFalse(), "the files are only reloaded by the scans")
+		Expect(scanner.Scan()).To(Succeed())
+		_, blocked = scanner.Check("https://phish.example.org/")
+		Expect(blocked).To(BeTrue())
+		reason, flagged := scanner.Flagged(bad)
+		Expect(flagged).To(BeTrue())
+		Expect(reason).To(Equal("domain phish.example.org"))
+		_, flagged = scanner.Flagged(good)
+		Expect(flagged).To(BeFalse())
+
+		By("not flagging the link once its destination is changed")
+		fixed := *bad
+		fixed.OriginalURL = "https://example.org/login"
+		_, flagged = scanner.Flagged(&fixed)
+		Expect(flagged).To(BeFalse())
+
+		By("keeping the list if the files become invalid")
+		Expect(os.Remove(domains)).To(Succeed())
+		Expect(scanner.Scan()).ToNot(Succeed())
+		_, flagged = scanner.Flagged(bad)
+		Expect(flagged).To(BeTrue())
+	})
+
+	It("scans in the background until closed", func() {
+		s := linkstorage.NewInMemoryStorage()
+		bad, err := s.Insert(model.Link{ShortName: "bad", OriginalURL: "https://evil.com/"})
+		Expect(err).ToNot(HaveOccurred())
+		scanner, err := blocklist.NewScanner(s, []string{writeFile("domains.txt", "evil.com\n")}, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		scanner.Start(time.Hour, func(err error) {
+			defer GinkgoRecover()
+			Fail(err.Error())
+		})
+		Eventually(func() bool {
+			_, flagged := scanner.Flagged(bad)
+			return flagged
+		}).Should(BeTrue())
+		Expect(scanner.Close()).To(Succeed())
+	})
+})
@@ -0,0 +1,181 @@
+package blocklist
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/denisvmedia/urlshortener/validator"
+	"github.com/go-extras/errors"
+)
+
+// List is a set of the malicious domains and of the hash prefixes of the malicious url expressions
+type List struct {
+	domains map[string]bool
+	// prefixes holds the hex encoded hash prefixes, prefixLengths are their distinct lengths
+	prefixes      map[string]bool
+	prefixLengths []int
+}
+
+// minPrefixLength is the min length of the hex encoded hash prefixes (4 bytes, as in Safe Browsing)
+const minPrefixLength = 8
+
+// Load reads the lists from the files. The domain files have a domain per line, it also matches its subdomains.
+// The hash files have a hex encoded prefix (4 to 32 bytes) of the SHA-256 hash of a url expression per line
+// (see Expressions). Empty lines and the lines starting with # are skipped.
+func Load(domainFiles, hashFiles []string) (*List, error) {
+	l := &List{
+		domains:  make(map[string]bool),
+		prefixes: make(map[string]bool),
+	}
+	for _, path := range domainFiles {
+		err := readLines(path, func(line string) error {
+			l.domains[strings.TrimSuffix(strings.ToLower(validator.ASCIIHost(line)), ".")] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lengths := make(map[int]bool)
+	for _, path := range hashFiles {
+		err := readLines(path, func(line string) error {
+			prefix := strings.ToLower(line)
+			if _, err := hex.DecodeString(prefix); err != nil || len(prefix) < minPrefixLength || len(prefix) > 2*sha256.Size {
+				return errors.Errorf("invalid hash prefix %s", line)
+			}
+			l.prefixes[prefix] = true
+			if !lengths[len(prefix)] {
+				lengths[len(prefix)] = true
+				l.prefixLengths = append(l.prefixLengths, len(prefix))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+func readLines(path string, fn func(line string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "can't read blocklist file")
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err = fn(line); err != nil {
+			return errors.Wrapf(err, "%s:%d", path, n)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Len returns the number of the entries of the list
+func (l *List) Len() int {
+	return len(l.domains) + len(l.prefixes)
+}
+
+// Check tells whether the url is malicious and why
+func (l *List) Check(rawURL string) (reason string, blocked bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+
+	// the lists have the punycode form of the internationalized domains
+	if ascii := validator.ASCIIHost(u.Hostname()); ascii != u.Hostname() {
+		u.Host = ascii
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for domain := host; domain != ""; {
+		if l.domains[domain] {
+			return "domain " + domain, true
+		}
+		i := strings.Index(domain, ".")
+		if i < 0 || net.ParseIP(host) != nil {
+			break
+		}
+		domain = domain[i+1:]
+	}
+
+	if len(l.prefixes) == 0 {
+		return "", false
+	}
+	for _, expression := range Expressions(u) {
+		sum := sha256.Sum256([]byte(expression))
+		hash := hex.EncodeToString(sum[:])
+		for _, length := range l.prefixLengths {
+			if l.prefixes[hash[:length]] {
+				return "hash prefix " + hash[:length] + " of " + expression, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// Expressions returns the host suffix and path prefix combinations of the url in the way of Safe Browsing
+// (https://developers.google.com/safe-browsing/v4/urls-hashing): the exact host and up to 4 hosts formed
+// by the last 5 components, each with the exact path and query, the exact path and up to 4 path prefixes,
+// e.g. a.b.example.com/1/2.html?x=1, a.b.example.com/1/2.html, a.b.example.com/1/, a.b.example.com/, b.example.com/...
+func Expressions(u *url.URL) []string {
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	hosts := []string{host}
+	if net.ParseIP(host) == nil {
+		components := strings.Split(host, ".")
+		if len(components) > 5 {
+			components = components[len(components)-5:]
+		}
+		// the top level domain alone is skipped
+		for i := 0; i < len(components)-1 && len(hosts) < 5; i++ {
+			if suffix := strings.Join(components[i:], "."); suffix != host {
+				hosts = append(hosts, suffix)
+			}
+		}
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	var paths []string
+	if u.RawQuery != "" {
+		paths = append(paths, path+"?"+u.RawQuery)
+	}
+	paths = append(paths, path)
+	prefix := "/"
+	components := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i < len(components) && len(paths) < 6; i++ {
+		if prefix != path {
+			paths = append(paths, prefix)
+		}
+		if i == len(components)-1 {
+			break
+		}
+		prefix += components[i] + "/"
+	}
+
+	expressions := make([]string, 0, len(hosts)*len(paths))
+	for _, h := range hosts {
+		for _, p := range paths {
+			expressions = append(expressions, h+p)
+		}
+	}
+
+	return expressions
+}
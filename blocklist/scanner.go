@@ -0,0 +1,136 @@
+package blocklist
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/denisvmedia/urlshortener/metrics"
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+)
+
+// scanPageSize is the number of the links checked at once
+const scanPageSize = 1000
+
+// flag tells why a link was flagged, the flag only applies while the link has the same original url
+type flag struct {
+	originalURL string
+	reason      string
+}
+
+// Scanner keeps the list loaded from the files and periodically reloads them and re-checks all the links,
+// so that the links which destinations became malicious after their creation are flagged
+type Scanner struct {
+	storage     linkstorage.Storage
+	domainFiles []string
+	hashFiles   []string
+
+	list    atomic.Value // *List
+	flagged atomic.Value // map[string]flag by link id
+
+	started  int32
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewScanner creates a scanner and loads the files, the links are not checked until Scan or Start is called
+func NewScanner(s linkstorage.Storage, domainFiles, hashFiles []string) (*Scanner, error) {
+	list, err := Load(domainFiles, hashFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := &Scanner{
+		storage:     s,
+		domainFiles: domainFiles,
+		hashFiles:   hashFiles,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	scanner.list.Store(list)
+	scanner.flagged.Store(map[string]flag{})
+
+	return scanner, nil
+}
+
+// Check tells whether the url is malicious according to the current list (see validator.SetBlocklist)
+func (s *Scanner) Check(rawURL string) (reason string, blocked bool) {
+	return s.list.Load().(*List).Check(rawURL)
+}
+
+// Flagged tells whether the link was flagged by the last scan
+func (s *Scanner) Flagged(link *model.Link) (reason string, flagged bool) {
+	f, ok := s.flagged.Load().(map[string]flag)[link.ID]
+	if !ok || f.originalURL != link.OriginalURL {
+		return "", false
+	}
+
+	return f.reason, true
+}
+
+// Scan reloads the files (the current list is kept if they can't be loaded) and re-checks all the links
+func (s *Scanner) Scan() error {
+	list, err := Load(s.domainFiles, s.hashFiles)
+	if err != nil {
+		metrics.BlocklistScans.WithLabelValues("failure").Inc()
+		return err
+	}
+	s.list.Store(list)
+
+	flagged := make(map[string]flag)
+	for page := 1; ; page++ {
+		links, total, err := s.storage.PaginatedGetAll(page, scanPageSize)
+		if err != nil {
+			metrics.BlocklistScans.WithLabelValues("failure").Inc()
+			return err
+		}
+		for _, link := range links {
+			if reason, blocked := list.Check(link.OriginalURL); blocked {
+				flagged[link.ID] = flag{originalURL: link.OriginalURL, reason: reason}
+			}
+		}
+		if len(links) == 0 || page*scanPageSize >= total {
+			break
+		}
+	}
+	s.flagged.Store(flagged)
+	metrics.BlocklistScans.WithLabelValues("success").Inc()
+	metrics.BlocklistFlaggedLinks.Set(float64(len(flagged)))
+
+	return nil
+}
+
+// Start scans the links right away and then every interval in the background until Close is called,
+// the scan errors are passed to onError
+func (s *Scanner) Start(interval time.Duration, onError func(error)) {
+	atomic.StoreInt32(&s.started, 1)
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := s.Scan(); err != nil {
+				onError(err)
+			}
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the background scans started by Start and waits for the current one to finish
+func (s *Scanner) Close() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	if atomic.LoadInt32(&s.started) == 1 {
+		<-s.done
+	}
+
+	return nil
+}
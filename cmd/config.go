@@ -0,0 +1,342 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-extras/errors"
+	"github.com/jessevdk/go-flags"
+	"gopkg.in/yaml.v2"
+)
+
+// configFileEnv is the env var that can be used instead of --config
+const configFileEnv = "CONFIG_FILE"
+
+// Options defines the options shared by all the commands
+type Options struct {
+	Config string `long:"config" description:"YAML config file, its values are overridden by the env vars and the flags" env:"CONFIG_FILE"`
+}
+
+// NewParser creates the command line parser with all the commands registered
+func NewParser(options *Options) *flags.Parser {
+	parser := flags.NewParser(options, flags.Default)
+	RegisterInitStorageCommand(parser)
+	RegisterRunCommand(parser)
+	RegisterImportCommand(parser)
+	RegisterExportCommand(parser)
+	RegisterLinkCommand(parser)
+	RegisterConfigCommand(parser, options)
+
+	return parser
+}
+
+// ConfigFile holds the values read from a config file by the option long names
+type ConfigFile map[string][]string
+
+// LoadConfig finds the command and the config file in args and uses the config file values as the defaults
+// of the command options, so that the precedence is: flags > env vars > config file > defaults.
+// It must be called before the parser is used, and it returns nil if no config file is given.
+func LoadConfig(parser *flags.Parser, args []string) (ConfigFile, error) {
+	// the arguments are parsed by a separate parser (go-flags doesn't apply the defaults
+	// twice) without executing anything to find out the command and the config file
+	probeOptions := &Options{}
+	probe := NewParser(probeOptions)
+	probe.CommandHandler = func(flags.Commander, []string) error {
+		return nil
+	}
+	probe.Options &^= flags.PrintErrors
+	_, _ = probe.ParseArgs(args) // the errors are reported by the real parsing
+
+	path := probeOptions.Config
+	if path == "" {
+		path = os.Getenv(configFileEnv)
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't read config file")
+	}
+	config, err := parseConfigFile(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't parse config file %s", path)
+	}
+
+	known := make(map[string]bool)
+	eachCommand(parser.Command, func(c *flags.Command) {
+		for _, option := range commandOptions(c) {
+			known[option.LongName] = true
+		}
+	})
+	for name := range config {
+		if !known[name] {
+			return nil, errors.Errorf("unknown option %s in config file %s", name, path)
+		}
+	}
+
+	// the same command is activated in the parser
+	active := parser.Command
+	for c := probe.Command.Active; c != nil; c = c.Active {
+		active.Active = active.Find(c.Name)
+		active = active.Active
+	}
+	for _, option := range activeOptions(parser) {
+		values, ok := config[option.LongName]
+		if !ok {
+			continue
+		}
+		if err = validateOptionValues(option, values); err != nil {
+			return nil, errors.Wrapf(err, "invalid value of %s in config file %s", option.LongName, path)
+		}
+		option.Default = values
+	}
+
+	return config, nil
+}
+
+// parseConfigFile flattens the YAML document, the nested keys are joined with a dash
+// (i.e. `mysql: {host: localhost}` is the same as `mysql-host: localhost`)
+func parseConfigFile(data []byte) (ConfigFile, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	config := make(ConfigFile)
+	if err := flattenConfig(config, "", doc); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func flattenConfig(config ConfigFile, name string, value interface{}) error {
+	var items map[string]interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		items = v
+	case map[interface{}]interface{}:
+		items = make(map[string]interface{}, len(v))
+		for key, item := range v {
+			items[fmt.Sprint(key)] = item
+		}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case nil, map[interface{}]interface{}, []interface{}:
+				return errors.Errorf("%s: only scalar list items are allowed", name)
+			}
+			values = append(values, fmt.Sprint(item))
+		}
+		config[name] = values
+		return nil
+	case nil:
+		// an empty value means the default one
+		return nil
+	default:
+		config[name] = []string{fmt.Sprint(v)}
+		return nil
+	}
+
+	for key, item := range items {
+		if name != "" {
+			key = name + "-" + key
+		}
+		if err := flattenConfig(config, key, item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateOptionValues makes sure that the option accepts the values (go-flags doesn't check the defaults)
+func validateOptionValues(option *flags.Option, values []string) error {
+	tp := option.Field().Type
+	if tp.Kind() == reflect.Ptr {
+		tp = tp.Elem()
+	}
+	if tp.Kind() == reflect.Slice {
+		tp = tp.Elem()
+	} else if len(values) > 1 {
+		return errors.New("a single value expected")
+	}
+
+	for _, value := range values {
+		if len(option.Choices) > 0 && !containsString(option.Choices, value) {
+			return errors.Errorf("%s is not one of %s", value, strings.Join(option.Choices, ", "))
+		}
+
+		var err error
+		switch {
+		case tp == reflect.TypeOf(time.Duration(0)):
+			_, err = time.ParseDuration(value)
+		case tp.Kind() == reflect.Bool:
+			_, err = strconv.ParseBool(value)
+		case tp.Kind() >= reflect.Int && tp.Kind() <= reflect.Int64:
+			_, err = strconv.ParseInt(value, 10, tp.Bits())
+		case tp.Kind() >= reflect.Uint && tp.Kind() <= reflect.Uint64:
+			_, err = strconv.ParseUint(value, 10, tp.Bits())
+		case tp.Kind() == reflect.Float32 || tp.Kind() == reflect.Float64:
+			_, err = strconv.ParseFloat(value, tp.Bits())
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func eachCommand(c *flags.Command, f func(*flags.Command)) {
+	f(c)
+	for _, sub := range c.Commands() {
+		eachCommand(sub, f)
+	}
+}
+
+func groupOptions(g *flags.Group) []*flags.Option {
+	options := append([]*flags.Option{}, g.Options()...)
+	for _, sub := range g.Groups() {
+		options = append(options, groupOptions(sub)...)
+	}
+
+	return options
+}
+
+// commandOptions returns the options of the command without the options of its subcommands
+func commandOptions(c *flags.Command) []*flags.Option {
+	var options []*flags.Option
+	for _, option := range groupOptions(c.Group) {
+		if option.LongName != "" && option.LongName != "help" {
+			options = append(options, option)
+		}
+	}
+
+	return options
+}
+
+// activeOptions returns the options of the active command and its parents
+func activeOptions(parser *flags.Parser) []*flags.Option {
+	var options []*flags.Option
+	for c := parser.Command; c != nil; c = c.Active {
+		options = append(options, commandOptions(c)...)
+	}
+
+	return options
+}
+
+// RegisterConfigCommand registers `config` command with its subcommands
+func RegisterConfigCommand(parser *flags.Parser, options *Options) *ConfigCommand {
+	cmd := &ConfigCommand{}
+	configCmd, err := parser.AddCommand("config", "configuration helpers", "", cmd)
+	if err != nil {
+		panic(err)
+	}
+	_, err = configCmd.AddCommand(
+		"print",
+		"prints the effective configuration of a command",
+		"Prints the effective configuration of the given command (the flags of the command must follow a double dash, e.g. `config print -- run --bind-address=:8080`), the secrets are redacted.",
+		&ConfigPrintCommand{options: options},
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+// ConfigCommand defines `config` command
+type ConfigCommand struct{}
+
+// ConfigPrintCommand defines `config print` command
+type ConfigPrintCommand struct {
+	options *Options
+}
+
+// redacted replaces the values of the options marked with `secret:"yes"`
+const redacted = "******"
+
+// Execute implements `config print` command
+func (cmd *ConfigPrintCommand) Execute(args []string) error {
+	if len(args) == 0 {
+		return errors.New("command name expected, e.g. `config print run`")
+	}
+	if cmd.options.Config != "" {
+		args = append([]string{"--config", cmd.options.Config}, args...)
+	}
+
+	parser := NewParser(&Options{})
+	config, err := LoadConfig(parser, args)
+	if err != nil {
+		return err
+	}
+	parser.CommandHandler = func(flags.Commander, []string) error {
+		return nil
+	}
+	if _, err = parser.ParseArgs(args); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "OPTION\tVALUE\tSOURCE")
+	for _, option := range activeOptions(parser) {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", option.LongName, optionValue(option), optionSource(option, config))
+	}
+
+	return w.Flush()
+}
+
+func optionValue(option *flags.Option) string {
+	value := reflect.ValueOf(option.Value())
+	var values []string
+	if value.Kind() == reflect.Slice {
+		for i := 0; i < value.Len(); i++ {
+			values = append(values, fmt.Sprint(value.Index(i).Interface()))
+		}
+	} else {
+		values = []string{fmt.Sprint(option.Value())}
+	}
+
+	result := strings.Join(values, ",")
+	if result != "" && option.Field().Tag.Get("secret") == "yes" {
+		return redacted
+	}
+
+	return result
+}
+
+func optionSource(option *flags.Option, config ConfigFile) string {
+	if option.IsSet() && !option.IsSetDefault() {
+		return "flag"
+	}
+	if option.EnvDefaultKey != "" {
+		if _, ok := os.LookupEnv(option.EnvDefaultKey); ok {
+			return "env"
+		}
+	}
+	if _, ok := config[option.LongName]; ok {
+		return "file"
+	}
+
+	return "default"
+}
@@ -0,0 +1,135 @@
+package cmd_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/denisvmedia/urlshortener/cmd"
+	"github.com/jessevdk/go-flags"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config file", func() {
+	var dir string
+	var configPath string
+	// the env vars of the tested options are cleared not to interfere with the tests
+	envVars := []string{"CONFIG_FILE", "BIND_ADDRESS", "STORAGE", "MYSQL_HOST", "MYSQL_PASSWORD", "MYSQL_USER", "LOG_LEVEL", "RESERVED_SHORT_NAMES", "RESERVED_SHORT_NAME_PATTERNS"}
+	savedEnv := make(map[string]string)
+
+	BeforeEach(func() {
+		for _, name := range envVars {
+			if value, ok := os.LookupEnv(name); ok {
+				savedEnv[name] = value
+			}
+			Expect(os.Unsetenv(name)).To(Succeed())
+		}
+
+		var err error
+		dir, err = ioutil.TempDir("", "urlshortener-config")
+		Expect(err).ToNot(HaveOccurred())
+		configPath = filepath.Join(dir, "config.yaml")
+		err = ioutil.WriteFile(configPath, []byte(
+			"bind-address: \":8080\"\n"+
+				"storage: mysql\n"+
+				"mysql:\n"+
+				"  host: db:3306\n"+
+				"  password: secret\n",
+		), 0600)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+		for _, name := range envVars {
+			Expect(os.Unsetenv(name)).To(Succeed())
+		}
+		for name, value := range savedEnv {
+			Expect(os.Setenv(name, value)).To(Succeed())
+		}
+	})
+
+	// parse parses the arguments without executing the command and returns the values of the run command options
+	var parse = func(args ...string) (map[string]interface{}, error) {
+		parser := cmd.NewParser(&cmd.Options{})
+		parser.Options &^= flags.PrintErrors
+		if _, err := cmd.LoadConfig(parser, args); err != nil {
+			return nil, err
+		}
+		parser.CommandHandler = func(flags.Commander, []string) error {
+			return nil
+		}
+		if _, err := parser.ParseArgs(args); err != nil {
+			return nil, err
+		}
+
+		values := make(map[string]interface{})
+		for _, name := range []string{"bind-address", "storage", "mysql-host", "mysql-password", "mysql-user"} {
+			values[name] = parser.Find("run").FindOptionByLongName(name).Value()
+		}
+		return values, nil
+	}
+
+	It("uses the config file values instead of the defaults", func() {
+		values, err := parse("--config", configPath, "run")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(values).To(Equal(map[string]interface{}{
+			"bind-address":   ":8080",
+			"storage":        "mysql",
+			"mysql-host":     "db:3306",
+			"mysql-password": "secret",
+			"mysql-user":     "",
+		}))
+	})
+
+	It("prefers the env vars and the flags to the config file", func() {
+		Expect(os.Setenv("BIND_ADDRESS", ":9090")).To(Succeed())
+		values, err := parse("run", "--config", configPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(values["bind-address"]).To(Equal(":9090"))
+
+		values, err = parse("run", "--config", configPath, "--bind-address", ":7070")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(values["bind-address"]).To(Equal(":7070"))
+	})
+
+	It("uses the defaults without a config file", func() {
+		values, err := parse("run")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(values["bind-address"]).To(Equal(":31456"))
+		Expect(values["storage"]).To(Equal("inmemory"))
+	})
+
+	It("rejects unknown options and invalid values", func() {
+		Expect(ioutil.WriteFile(configPath, []byte("bind-adress: \":8080\"\n"), 0600)).To(Succeed())
+		_, err := parse("--config", configPath, "run")
+		Expect(err).To(MatchError(ContainSubstring("unknown option bind-adress")))
+
+		Expect(ioutil.WriteFile(configPath, []byte("storage: redis\n"), 0600)).To(Succeed())
+		_, err = parse("--config", configPath, "run")
+		Expect(err).To(MatchError(ContainSubstring("invalid value of storage")))
+	})
+
+	It("re-reads the run command settings", func() {
+		run, err := cmd.ParseRunCommand([]string{"--config", configPath, "run"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(run.LogLevel).To(Equal("info"))
+		Expect(run.ReservedShortNames.Names).To(BeEmpty())
+
+		Expect(ioutil.WriteFile(configPath, []byte(
+			"log-level: warn\n"+
+				"reserved-short-name: [admin, login]\n"+
+				"reserved-short-name-pattern: [\"team-.+\"]\n",
+		), 0600)).To(Succeed())
+		run, err = cmd.ParseRunCommand([]string{"--config", configPath, "run"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(run.LogLevel).To(Equal("warn"))
+		Expect(run.ReservedShortNames.Names).To(Equal([]string{"admin", "login"}))
+		Expect(run.ReservedShortNames.Patterns).To(Equal([]string{"team-.+"}))
+
+		Expect(ioutil.WriteFile(configPath, []byte("log-level: verbose\n"), 0600)).To(Succeed())
+		_, err = cmd.ParseRunCommand([]string{"--config", configPath, "run"})
+		Expect(err).To(MatchError(ContainSubstring("invalid value of log-level")))
+	})
+})
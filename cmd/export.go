@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/denisvmedia/urlshortener/linkio"
+	"github.com/jessevdk/go-flags"
+)
+
+// RegisterExportCommand registers `export` command
+func RegisterExportCommand(parser *flags.Parser) *ExportCommand {
+	cmd := &ExportCommand{}
+	_, err := parser.AddCommand("export", "exports links to a file", "", cmd)
+	if err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+// ExportCommand defines `export` command
+type ExportCommand struct {
+	Storage string `long:"storage" description:"storage to use" choice:"mysql" default:"mysql" env:"STORAGE"`
+	File    string `long:"file" description:"file to export the links to, - for stdout" default:"-"`
+	Format  string `long:"format" description:"file format" choice:"csv" choice:"jsonl" default:"csv"`
+	Mysql
+}
+
+// Execute implements `export` command
+func (cmd *ExportCommand) Execute(_ []string) error {
+	linkStorage, _, closer, err := openStorages(cmd.Storage, cmd.Mysql)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	var out io.Writer = os.Stdout
+	if cmd.File != "-" {
+		f, err := os.Create(cmd.File)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	writer, err := linkio.NewWriter(cmd.Format, out)
+	if err != nil {
+		return err
+	}
+	count, err := linkio.Export(linkStorage, writer)
+	if err != nil {
+		return err
+	}
+	if cmd.File != "-" {
+		fmt.Printf("Exported %d links to %s\n", count, cmd.File)
+	}
+
+	return nil
+}
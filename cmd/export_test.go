@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"io"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/server"
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/denisvmedia/urlshortener/storage/tagstorage"
+	"github.com/labstack/echo/v4"
+)
+
+// SetStorages makes the link subcommands use the given storages instead of opening them
+func (cmd *LinkCommand) SetStorages(linkStorage linkstorage.Storage, tagStorage tagstorage.Storage) {
+	cmd.linkStorage = linkStorage
+	cmd.tagStorage = tagStorage
+}
+
+// SetOutput makes the link subcommands print the results to the given writer
+func (cmd *LinkCommand) SetOutput(out io.Writer) {
+	cmd.out = out
+}
+
+// SetStorages makes the import command use the given storages instead of opening them
+func (cmd *ImportCommand) SetStorages(linkStorage linkstorage.Storage, tagStorage tagstorage.Storage) {
+	cmd.linkStorage = linkStorage
+	cmd.tagStorage = tagStorage
+}
+
+// SetOutput makes the import command print the stats to the given writer
+func (cmd *ImportCommand) SetOutput(out io.Writer) {
+	cmd.out = out
+}
+
+// Generator exposes ShortNames.generator to the tests
+func (s ShortNames) Generator(start uint64) (model.ShortNameGenerator, error) {
+	return s.generator(start)
+}
+
+// ReloadableSettings exposes reloadableSettings to the tests
+var ReloadableSettings = reloadableSettings
+
+// Apply exposes Reloadable.apply to the tests
+func (r Reloadable) Apply(e *echo.Echo, limits *server.RateLimits) error {
+	return r.apply(e, limits)
+}
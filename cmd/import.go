@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/denisvmedia/urlshortener/linkio"
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/denisvmedia/urlshortener/storage/tagstorage"
+	"github.com/denisvmedia/urlshortener/validator"
+	"github.com/go-extras/errors"
+	"github.com/jessevdk/go-flags"
+)
+
+// RegisterImportCommand registers `import` command
+func RegisterImportCommand(parser *flags.Parser) *ImportCommand {
+	cmd := &ImportCommand{}
+	_, err := parser.AddCommand("import", "imports links from a file", "", cmd)
+	if err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+// ImportCommand defines `import` command
+type ImportCommand struct {
+	Storage    string `long:"storage" description:"storage to use" choice:"mysql" default:"mysql" env:"STORAGE"`
+	File       string `long:"file" description:"file to import the links from, - for stdin" default:"-"`
+	Format     string `long:"format" description:"file format (yourls accepts both SQL dumps and CSV files, bitly accepts CSV exports)" choice:"csv" choice:"jsonl" choice:"yourls" choice:"bitly" default:"csv"`
+	OnConflict string `long:"on-conflict" description:"what to do when a short name is already used" choice:"skip" choice:"overwrite" choice:"fail" default:"fail"`
+	DryRun     bool   `long:"dry-run" description:"validate the links and check the conflicts without storing anything"`
+	// AdminBindAddress tells whether the admin routes reserve their short names (see RunCommand)
+	AdminBindAddress string `long:"admin-bind-address" description:"admin bind address of run command, the admin routes don't reserve short names if it's set" env:"ADMIN_BIND_ADDRESS"`
+	Mysql
+	ShortNameCase
+	ReservedShortNames
+	ShortNameScripts
+	DestinationDomains
+	RedirectChains
+	BlocklistFiles
+
+	linkStorage linkstorage.Storage
+	tagStorage  tagstorage.Storage
+	// out is where the stats are printed, os.Stdout if nil
+	out io.Writer
+}
+
+// Execute implements `import` command
+func (cmd *ImportCommand) Execute(_ []string) error {
+	cmd.ShortNameCase.apply()
+	// the storages are opened unless they are already set (e.g. by the tests)
+	if cmd.linkStorage == nil {
+		var closer io.Closer
+		var err error
+		cmd.linkStorage, cmd.tagStorage, closer, err = openStorages(cmd.Storage, cmd.Mysql)
+		if err != nil {
+			return err
+		}
+		defer closer.Close()
+	}
+
+	// the links are validated the same way as by `run` command, the dry run included
+	setRouteShortNames(cmd.AdminBindAddress, cmd.linkStorage, cmd.tagStorage)
+	if err := setPrepared(cmd.ReservedShortNames.prepare()); err != nil {
+		return err
+	}
+	if err := cmd.ShortNameScripts.apply(); err != nil {
+		return err
+	}
+	if err := cmd.DestinationDomains.apply(); err != nil {
+		return err
+	}
+	if err := cmd.RedirectChains.apply(); err != nil {
+		return err
+	}
+	if err := cmd.BlocklistFiles.apply(); err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if cmd.File != "-" {
+		f, err := os.Open(cmd.File)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	reader, err := linkio.NewReader(cmd.Format, in)
+	if err != nil {
+		return err
+	}
+
+	importer := &linkio.Importer{
+		Storage:    cmd.linkStorage,
+		Validator:  validator.New(),
+		OnConflict: linkio.ConflictPolicy(cmd.OnConflict),
+		DryRun:     cmd.DryRun,
+		OnInvalid: func(record int, err error) {
+			fmt.Fprintf(os.Stderr, "Skipping invalid record %d: %s\n", record, err)
+		},
+	}
+	stats, err := importer.Import(reader)
+	prefix := ""
+	if cmd.DryRun {
+		prefix = "[dry run] "
+	}
+	fmt.Fprintf(cmd.stdout(), "%sCreated: %d, updated: %d, skipped: %d, invalid: %d\n", prefix, stats.Created, stats.Updated, stats.Skipped, stats.Invalid)
+	if err != nil {
+		return err
+	}
+	if stats.Invalid > 0 {
+		return errors.Errorf("%d invalid records were skipped", stats.Invalid)
+	}
+
+	return nil
+}
+
+func (cmd *ImportCommand) stdout() io.Writer {
+	if cmd.out == nil {
+		return os.Stdout
+	}
+
+	return cmd.out
+}
@@ -0,0 +1,74 @@
+package cmd_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+
+	"github.com/denisvmedia/urlshortener/cmd"
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/denisvmedia/urlshortener/storage/tagstorage"
+	"github.com/denisvmedia/urlshortener/validator"
+	"github.com/jessevdk/go-flags"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Import command", func() {
+	var linkStorage linkstorage.Storage
+	var out *bytes.Buffer
+	var file string
+
+	BeforeEach(func() {
+		linkStorage = linkstorage.NewInMemoryStorage()
+		out = &bytes.Buffer{}
+
+		f, err := ioutil.TempFile("", "import-*.csv")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = f.WriteString("shortName,originalUrl\n" +
+			"admin,https://example.com/admin\n" +
+			"team-sales,https://example.com/sales\n" +
+			"api,https://example.com/api\n" +
+			"sale,https://example.com/sale\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.Close()).To(Succeed())
+		file = f.Name()
+	})
+
+	AfterEach(func() {
+		Expect(os.Remove(file)).To(Succeed())
+		set, err := validator.PrepareReservedShortNames(nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		set()
+	})
+
+	// run executes the import command with the in-memory storages, its output is in out
+	var run = func(args ...string) error {
+		parser := flags.NewParser(&struct{}{}, flags.Default&^flags.PrintErrors)
+		importCmd := cmd.RegisterImportCommand(parser)
+		importCmd.SetStorages(linkStorage, tagstorage.NewInMemoryStorage())
+		importCmd.SetOutput(out)
+		out.Reset()
+		_, err := parser.ParseArgs(append([]string{"import", "--file", file}, args...))
+		return err
+	}
+
+	It("rejects the reserved short names and the ones of the routes", func() {
+		for _, dryRun := range []bool{true, false} {
+			args := []string{"--reserved-short-name", "admin", "--reserved-short-name-pattern", "team-.+"}
+			if dryRun {
+				args = append(args, "--dry-run")
+			}
+			err := run(args...)
+			Expect(err).To(MatchError("3 invalid records were skipped"))
+			Expect(out.String()).To(ContainSubstring("Created: 1, updated: 0, skipped: 0, invalid: 3"))
+		}
+
+		for _, shortName := range []string{"admin", "team-sales", "api"} {
+			_, err := linkStorage.GetOneByShortName(shortName)
+			Expect(err).To(HaveOccurred(), shortName)
+		}
+		_, err := linkStorage.GetOneByShortName("sale")
+		Expect(err).ToNot(HaveOccurred())
+	})
+})
@@ -0,0 +1,573 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/server"
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/denisvmedia/urlshortener/storage/tagstorage"
+	"github.com/denisvmedia/urlshortener/validator"
+	"github.com/go-extras/errors"
+	"github.com/jessevdk/go-flags"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterLinkCommand registers `link` command with its subcommands
+func RegisterLinkCommand(parser *flags.Parser) *LinkCommand {
+	cmd := &LinkCommand{}
+	linkCmd, err := parser.AddCommand("link", "manages links directly in the storage (bypassing the http api)", "", cmd)
+	if err != nil {
+		panic(err)
+	}
+
+	subcommands := []struct {
+		name        string
+		description string
+		data        interface{}
+	}{
+		{"create", "creates a link", &LinkCreateCommand{parent: cmd}},
+		{"get", "shows a link", &LinkGetCommand{parent: cmd}},
+		{"list", "lists links", &LinkListCommand{parent: cmd}},
+		{"update", "updates a link", &LinkUpdateCommand{parent: cmd}},
+		{"delete", "deletes a link", &LinkDeleteCommand{parent: cmd}},
+		{"check-reserved", "lists the links whose short names are reserved (e.g. after a route or a reserved name is added)", &LinkCheckReservedCommand{parent: cmd}},
+		{"check-case", "lists the links whose short names collide after case folding (run it before enabling case-insensitive short names)", &LinkCheckCaseCommand{parent: cmd}},
+	}
+	for _, sub := range subcommands {
+		if _, err = linkCmd.AddCommand(sub.name, sub.description, "", sub.data); err != nil {
+			panic(err)
+		}
+	}
+
+	return cmd
+}
+
+// LinkCommand defines `link` command, its options are shared by the subcommands
+type LinkCommand struct {
+	Storage string `long:"storage" description:"storage to use" choice:"mysql" default:"mysql" env:"STORAGE"`
+	Output  string `long:"output" short:"o" description:"output format" choice:"table" choice:"json" default:"table"`
+	// AdminBindAddress tells whether the admin routes reserve their short names (see RunCommand)
+	AdminBindAddress string `long:"admin-bind-address" description:"admin bind address of run command, the admin routes don't reserve short names if it's set" env:"ADMIN_BIND_ADDRESS"`
+	Mysql
+	ShortNameCase
+	ReservedShortNames
+	ShortNameScripts
+	DestinationDomains
+	RedirectChains
+	BlocklistFiles
+
+	linkStorage linkstorage.Storage
+	tagStorage  tagstorage.Storage
+	// out is where the results are printed, os.Stdout if nil
+	out io.Writer
+}
+
+// open opens the storages, unless they are already set (e.g. by the tests), and applies the validation settings,
+// the returned closer releases the opened storages
+func (cmd *LinkCommand) open() (io.Closer, error) {
+	cmd.ShortNameCase.apply()
+	var closer io.Closer = nopCloser{}
+	if cmd.linkStorage == nil {
+		var err error
+		cmd.linkStorage, cmd.tagStorage, closer, err = openStorages(cmd.Storage, cmd.Mysql)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cmd.applyValidation(); err != nil {
+		_ = closer.Close()
+		return nil, err
+	}
+
+	return closer, nil
+}
+
+// applyValidation makes the validator check the links the same way as `run` command does
+func (cmd *LinkCommand) applyValidation() error {
+	setRouteShortNames(cmd.AdminBindAddress, cmd.linkStorage, cmd.tagStorage)
+	if err := setPrepared(cmd.ReservedShortNames.prepare()); err != nil {
+		return err
+	}
+
+	if err := cmd.ShortNameScripts.apply(); err != nil {
+		return err
+	}
+	if err := cmd.DestinationDomains.apply(); err != nil {
+		return err
+	}
+	if err := cmd.RedirectChains.apply(); err != nil {
+		return err
+	}
+
+	return cmd.BlocklistFiles.apply()
+}
+
+// setRouteShortNames reserves the short names taken by the routes of `run` command,
+// the admin routes don't take any if they are served on the admin bind address
+func setRouteShortNames(adminBindAddress string, linkStorage linkstorage.Storage, tagStorage tagstorage.Storage) {
+	var admin *echo.Echo
+	if adminBindAddress != "" {
+		admin = server.NewAdminEcho()
+	}
+	e := server.NewEcho(linkStorage, tagStorage, &server.Status{}, admin, nil, nil)
+	validator.SetRouteShortNames(server.ReservedShortNames(e))
+}
+
+// validate runs the same checks as resource.LinkResource does
+func (cmd *LinkCommand) validate(link model.Link) error {
+	if err := validator.New().Struct(link); err != nil {
+		return err
+	}
+	if _, err := cmd.tagStorage.GetByIDs(link.TagIDs); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// LinkRef identifies a link either by its id or by its short name
+type LinkRef struct {
+	ShortName string `long:"short-name" description:"short name of the link (instead of the id)"`
+	Args      struct {
+		ID string `positional-arg-name:"id" description:"link id"`
+	} `positional-args:"yes"`
+}
+
+func (ref LinkRef) find(s linkstorage.Storage) (*model.Link, error) {
+	switch {
+	case ref.ShortName != "" && ref.Args.ID != "":
+		return nil, errors.New("either the link id or the short name must be given, not both")
+	case ref.ShortName != "":
+		return s.GetOneByShortName(ref.ShortName)
+	case ref.Args.ID != "":
+		return s.GetOne(ref.Args.ID)
+	default:
+		return nil, errors.New("the link id or the short name must be given")
+	}
+}
+
+// linkOutput is the json representation of a link in the command output
+type linkOutput struct {
+	ID          string    `json:"id"`
+	ShortName   string    `json:"shortName"`
+	OriginalURL string    `json:"originalUrl"`
+	Comment     string    `json:"comment"`
+	TagIDs      []string  `json:"tagIds"`
+	Aliases     []string  `json:"aliases"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func newLinkOutput(link *model.Link) linkOutput {
+	tagIDs := link.TagIDs
+	if tagIDs == nil {
+		tagIDs = []string{}
+	}
+	aliases := link.Aliases
+	if aliases == nil {
+		aliases = []string{}
+	}
+
+	return linkOutput{
+		ID:          link.ID,
+		ShortName:   link.ShortName,
+		OriginalURL: link.OriginalURL,
+		Comment:     link.Comment,
+		TagIDs:      tagIDs,
+		Aliases:     aliases,
+		CreatedAt:   link.CreatedAt,
+	}
+}
+
+// stdout returns where the results are printed
+func (cmd *LinkCommand) stdout() io.Writer {
+	if cmd.out == nil {
+		return os.Stdout
+	}
+
+	return cmd.out
+}
+
+func (cmd *LinkCommand) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(cmd.stdout())
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
+
+func (cmd *LinkCommand) printTable(links []*model.Link) error {
+	w := tabwriter.NewWriter(cmd.stdout(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSHORT NAME\tALIASES\tORIGINAL URL\tCOMMENT")
+	for _, link := range links {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", link.ID, link.ShortName, strings.Join(link.Aliases, ","), link.OriginalURL, link.Comment)
+	}
+
+	return w.Flush()
+}
+
+// printOne prints a link as a json object or as a single row table
+func (cmd *LinkCommand) printOne(link *model.Link) error {
+	if cmd.Output == "json" {
+		return cmd.printJSON(newLinkOutput(link))
+	}
+
+	return cmd.printTable([]*model.Link{link})
+}
+
+// LinkCreateCommand defines `link create` command
+type LinkCreateCommand struct {
+	ShortName   string   `long:"short-name" description:"link short name, generated if empty"`
+	Aliases     []string `long:"alias" description:"alias short name leading to the link too (can be repeated)"`
+	OriginalURL string   `long:"url" description:"original url" required:"yes"`
+	Comment     string   `long:"comment" description:"free text comment"`
+
+	parent *LinkCommand
+}
+
+// Execute implements `link create` command
+func (cmd *LinkCreateCommand) Execute(_ []string) error {
+	closer, err := cmd.parent.open()
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	link := model.Link{
+		ShortName:   cmd.ShortName,
+		Aliases:     cmd.Aliases,
+		OriginalURL: cmd.OriginalURL,
+		Comment:     cmd.Comment,
+	}
+	if err := cmd.parent.validate(link); err != nil {
+		return err
+	}
+
+	// the short name is generated unless given
+	created, err := linkstorage.NewAllocator(cmd.parent.linkStorage).Insert(link)
+	if err != nil {
+		return err
+	}
+
+	return cmd.parent.printOne(created)
+}
+
+// LinkGetCommand defines `link get` command
+type LinkGetCommand struct {
+	LinkRef
+
+	parent *LinkCommand
+}
+
+// Execute implements `link get` command
+func (cmd *LinkGetCommand) Execute(_ []string) error {
+	closer, err := cmd.parent.open()
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	link, err := cmd.find(cmd.parent.linkStorage)
+	if err != nil {
+		return err
+	}
+
+	return cmd.parent.printOne(link)
+}
+
+// LinkListCommand defines `link list` command
+type LinkListCommand struct {
+	Query    string `long:"query" description:"full-text search in the original urls and comments"`
+	Page     int    `long:"page" description:"page number" default:"1"`
+	PageSize int    `long:"page-size" description:"page size" default:"50"`
+
+	parent *LinkCommand
+}
+
+// Execute implements `link list` command
+func (cmd *LinkListCommand) Execute(_ []string) error {
+	if cmd.Page < 1 || cmd.PageSize < 1 {
+		return errors.New("page and page size must be positive")
+	}
+	closer, err := cmd.parent.open()
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	var links []*model.Link
+	var total int
+	if cmd.Query != "" {
+		links, total, err = cmd.parent.linkStorage.PaginatedFind(linkstorage.Filter{Query: cmd.Query}, cmd.Page, cmd.PageSize)
+	} else {
+		links, total, err = cmd.parent.linkStorage.PaginatedGetAll(cmd.Page, cmd.PageSize)
+	}
+	if err != nil {
+		return err
+	}
+
+	if cmd.parent.Output == "json" {
+		outputs := make([]linkOutput, 0, len(links))
+		for _, link := range links {
+			outputs = append(outputs, newLinkOutput(link))
+		}
+		return cmd.parent.printJSON(outputs)
+	}
+	if err = cmd.parent.printTable(links); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(cmd.parent.stdout(), "\nShowing %d of %d links (page %d)\n", len(links), total, cmd.Page)
+
+	return err
+}
+
+// LinkUpdateCommand defines `link update` command, only the given fields are changed
+type LinkUpdateCommand struct {
+	LinkRef
+	NewShortName  *string  `long:"set-short-name" description:"new short name"`
+	AddAliases    []string `long:"add-alias" description:"alias short name to add (can be repeated)"`
+	RemoveAliases []string `long:"remove-alias" description:"alias short name to remove (can be repeated)"`
+	OriginalURL   *string  `long:"url" description:"new original url"`
+	Comment       *string  `long:"comment" description:"new comment"`
+
+	parent *LinkCommand
+}
+
+// Execute implements `link update` command
+func (cmd *LinkUpdateCommand) Execute(_ []string) error {
+	closer, err := cmd.parent.open()
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	existing, err := cmd.find(cmd.parent.linkStorage)
+	if err != nil {
+		return err
+	}
+
+	// the storage instance must not be modified
+	link := *existing
+	if cmd.NewShortName != nil {
+		link.ShortName = *cmd.NewShortName
+	}
+	if cmd.OriginalURL != nil {
+		link.OriginalURL = *cmd.OriginalURL
+	}
+	if cmd.Comment != nil {
+		link.Comment = *cmd.Comment
+	}
+	if err = link.DeleteToManyIDs(model.AliasesRelationship, cmd.RemoveAliases); err != nil {
+		return err
+	}
+	if err = link.AddToManyIDs(model.AliasesRelationship, cmd.AddAliases); err != nil {
+		return err
+	}
+	if err = cmd.parent.validate(link); err != nil {
+		return err
+	}
+
+	updated, err := linkstorage.NewAllocator(cmd.parent.linkStorage).Update(link)
+	if err != nil {
+		return err
+	}
+
+	return cmd.parent.printOne(updated)
+}
+
+// LinkDeleteCommand defines `link delete` command
+type LinkDeleteCommand struct {
+	LinkRef
+
+	parent *LinkCommand
+}
+
+// Execute implements `link delete` command
+func (cmd *LinkDeleteCommand) Execute(_ []string) error {
+	closer, err := cmd.parent.open()
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	link, err := cmd.find(cmd.parent.linkStorage)
+	if err != nil {
+		return err
+	}
+	if err = cmd.parent.linkStorage.Delete(link.ID); err != nil {
+		return err
+	}
+	if cmd.parent.Output == "json" {
+		return cmd.parent.printJSON(newLinkOutput(link))
+	}
+	_, err = fmt.Fprintf(cmd.parent.stdout(), "Deleted link %s (%s)\n", link.ID, link.ShortName)
+
+	return err
+}
+
+// LinkCheckReservedCommand defines `link check-reserved` command
+type LinkCheckReservedCommand struct {
+	parent *LinkCommand
+}
+
+// Execute implements `link check-reserved` command, it fails if any link conflicts with the reserved short names
+func (cmd *LinkCheckReservedCommand) Execute(_ []string) error {
+	closer, err := cmd.parent.open()
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	var conflicts []*model.Link
+	const pageSize = 1000
+	for page := 1; ; page++ {
+		links, total, err := cmd.parent.linkStorage.PaginatedGetAll(page, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, link := range links {
+			for _, name := range append([]string{link.ShortName}, link.Aliases...) {
+				if validator.IsReservedShortName(name) {
+					conflicts = append(conflicts, link)
+					break
+				}
+			}
+		}
+		if len(links) == 0 || page*pageSize >= total {
+			break
+		}
+	}
+
+	if cmd.parent.Output == "json" {
+		outputs := make([]linkOutput, 0, len(conflicts))
+		for _, link := range conflicts {
+			outputs = append(outputs, newLinkOutput(link))
+		}
+		if err := cmd.parent.printJSON(outputs); err != nil {
+			return err
+		}
+	} else if len(conflicts) > 0 {
+		if err := cmd.parent.printTable(conflicts); err != nil {
+			return err
+		}
+	}
+	if len(conflicts) > 0 {
+		return errors.Errorf("%d links conflict with the reserved short names", len(conflicts))
+	}
+	if cmd.parent.Output != "json" {
+		fmt.Fprintln(cmd.parent.stdout(), "No links conflict with the reserved short names.")
+	}
+
+	return nil
+}
+
+// LinkCheckCaseCommand defines `link check-case` command
+type LinkCheckCaseCommand struct {
+	Fix bool `long:"fix" description:"store the short names case-folded if none of them collide"`
+
+	parent *LinkCommand
+}
+
+// Execute implements `link check-case` command, it fails if the short names of any links differ in case only
+// or if any short names are not stored case-folded (unless they are fixed), as such links can't be found
+// once the short names are case-insensitive
+func (cmd *LinkCheckCaseCommand) Execute(_ []string) error {
+	closer, err := cmd.parent.open()
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	// the links by the folded forms of their short names and aliases
+	var folded []string
+	groups := make(map[string][]*model.Link)
+	var unfolded []*model.Link
+	const pageSize = 1000
+	for page := 1; ; page++ {
+		links, total, err := cmd.parent.linkStorage.PaginatedGetAll(page, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, link := range links {
+			isFolded := true
+			for _, name := range append([]string{link.ShortName}, link.Aliases...) {
+				foldedName := validator.FoldShortName(name)
+				isFolded = isFolded && foldedName == name
+				group := groups[foldedName]
+				if len(group) == 0 {
+					folded = append(folded, foldedName)
+				}
+				// a name of the link may collide with another one of the same link, it's dropped on folding
+				if len(group) == 0 || group[len(group)-1].ID != link.ID {
+					groups[foldedName] = append(group, link)
+				}
+			}
+			if !isFolded {
+				unfolded = append(unfolded, link)
+			}
+		}
+		if len(links) == 0 || page*pageSize >= total {
+			break
+		}
+	}
+
+	var conflicts []*model.Link
+	seen := make(map[string]bool)
+	for _, name := range folded {
+		if len(groups[name]) < 2 {
+			continue
+		}
+		for _, link := range groups[name] {
+			if !seen[link.ID] {
+				seen[link.ID] = true
+				conflicts = append(conflicts, link)
+			}
+		}
+	}
+
+	if cmd.parent.Output == "json" {
+		outputs := make([]linkOutput, 0, len(conflicts))
+		for _, link := range conflicts {
+			outputs = append(outputs, newLinkOutput(link))
+		}
+		if err := cmd.parent.printJSON(outputs); err != nil {
+			return err
+		}
+	} else if len(conflicts) > 0 {
+		if err := cmd.parent.printTable(conflicts); err != nil {
+			return err
+		}
+	}
+	if len(conflicts) > 0 {
+		return errors.Errorf("%d links collide after case folding, rename them before making the short names case-insensitive", len(conflicts))
+	}
+	if len(unfolded) > 0 && !cmd.Fix {
+		return errors.Errorf("%d short names are not stored case-folded, run the command with --fix to fold them", len(unfolded))
+	}
+	for _, link := range unfolded {
+		updated := *link
+		updated.ShortName = validator.FoldShortName(link.ShortName)
+		updated.Aliases = make([]string, 0, len(link.Aliases))
+		for _, alias := range link.Aliases {
+			updated.Aliases = append(updated.Aliases, validator.FoldShortName(alias))
+		}
+		if err := cmd.parent.linkStorage.Update(updated); err != nil {
+			return errors.Wrapf(err, "can't fold short name %s of link id %s", link.ShortName, link.ID)
+		}
+	}
+	if cmd.parent.Output != "json" {
+		if len(unfolded) > 0 {
+			fmt.Fprintf(cmd.parent.stdout(), "Folded %d short names.\n", len(unfolded))
+		}
+		fmt.Fprintln(cmd.parent.stdout(), "No links collide after case folding.")
+	}
+
+	return nil
+}
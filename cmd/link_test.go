@@ -0,0 +1,245 @@
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/denisvmedia/urlshortener/cmd"
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/storage"
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/denisvmedia/urlshortener/storage/tagstorage"
+	"github.com/go-extras/errors"
+	"github.com/jessevdk/go-flags"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Link command", func() {
+	var linkStorage linkstorage.Storage
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		linkStorage = linkstorage.NewInMemoryStorage()
+		out = &bytes.Buffer{}
+	})
+
+	// run executes a link subcommand with the in-memory storages, its output is in out
+	var run = func(args ...string) error {
+		parser := flags.NewParser(&struct{}{}, flags.Default&^flags.PrintErrors)
+		linkCmd := cmd.RegisterLinkCommand(parser)
+		linkCmd.SetStorages(linkStorage, tagstorage.NewInMemoryStorage())
+		linkCmd.SetOutput(out)
+		out.Reset()
+		_, err := parser.ParseArgs(append([]string{"link"}, args...))
+		return err
+	}
+
+	// runJSON executes a link subcommand with the json output and decodes the output
+	var runJSON = func(v interface{}, args ...string) {
+		Expect(run(append([]string{"--output", "json"}, args...)...)).To(Succeed())
+		Expect(json.Unmarshal(out.Bytes(), v)).To(Succeed(), out.String())
+	}
+
+	// insert stores a link bypassing the validation, e.g. to make a conflict
+	var insert = func(shortName string) *model.Link {
+		link, err := linkStorage.Insert(model.Link{ShortName: shortName, OriginalURL: "https://example.com/" + shortName})
+		Expect(err).ToNot(HaveOccurred())
+		return link
+	}
+
+	Describe("create", func() {
+		It("creates a link", func() {
+			Expect(run("create", "--short-name", "sale", "--alias", "promo", "--url", "https://example.com/sale", "--comment", "Spring sale")).To(Succeed())
+			Expect(out.String()).To(MatchRegexp(`ID +SHORT NAME +ALIASES +ORIGINAL URL +COMMENT\n1 +sale +promo +https://example.com/sale +Spring sale\n`))
+
+			var created map[string]interface{}
+			runJSON(&created, "create", "--url", "https://example.com/other")
+			Expect(created["id"]).To(Equal("2"))
+			Expect(created["shortName"]).ToNot(BeEmpty(), "the short name must be generated")
+			Expect(created["originalUrl"]).To(Equal("https://example.com/other"))
+			Expect(created["aliases"]).To(BeEmpty())
+
+			link, err := linkStorage.GetOneByShortName("promo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(link.ID).To(Equal("1"))
+		})
+
+		It("rejects the invalid links", func() {
+			err := run("create", "--short-name", "sale", "--url", "ftp://example.com/sale")
+			Expect(err).To(MatchError(ContainSubstring("'urlscheme' tag")))
+			err = run("create", "--short-name", "no spaces", "--url", "https://example.com/sale")
+			Expect(err).To(MatchError(ContainSubstring("'shortname' tag")))
+			err = run("create", "--short-name", "sale")
+			Expect(err).To(MatchError(ContainSubstring("`--url' was not specified")))
+			Expect(out.String()).To(BeEmpty())
+
+			insert("sale")
+			err = run("create", "--short-name", "other", "--alias", "sale", "--url", "https://example.com/other")
+			Expect(errors.Cause(err)).To(Equal(storage.ErrShortNameAlreadyExists))
+
+			_, total, err := linkStorage.PaginatedGetAll(1, 10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(total).To(Equal(1))
+		})
+	})
+
+	Describe("get", func() {
+		It("shows a link by its id or short name", func() {
+			insert("sale")
+			Expect(run("get", "1")).To(Succeed())
+			Expect(out.String()).To(MatchRegexp(`\n1 +sale +https://example.com/sale`))
+
+			var link map[string]interface{}
+			runJSON(&link, "get", "--short-name", "sale")
+			Expect(link["id"]).To(Equal("1"))
+			Expect(link["tagIds"]).To(BeEmpty())
+		})
+
+		It("requires exactly one existing link reference", func() {
+			insert("sale")
+			Expect(run("get")).To(MatchError("the link id or the short name must be given"))
+			Expect(run("get", "--short-name", "sale", "1")).To(MatchError("either the link id or the short name must be given, not both"))
+			Expect(errors.Cause(run("get", "2"))).To(Equal(storage.ErrNotFound))
+			Expect(errors.Cause(run("get", "--short-name", "other"))).To(Equal(storage.ErrNotFound))
+		})
+	})
+
+	Describe("list", func() {
+		It("lists the links", func() {
+			insert("sale")
+			insert("promo")
+			Expect(run("list")).To(Succeed())
+			Expect(out.String()).To(MatchRegexp(`\n1 +sale +.*\n2 +promo +.*\n\nShowing 2 of 2 links \(page 1\)\n$`))
+
+			Expect(run("list", "--page", "2", "--page-size", "1")).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("promo"))
+			Expect(out.String()).ToNot(ContainSubstring("sale"))
+			Expect(out.String()).To(ContainSubstring("Showing 1 of 2 links (page 2)"))
+
+			var links []map[string]interface{}
+			runJSON(&links, "list", "--query", "promo")
+			Expect(links).To(HaveLen(1))
+			Expect(links[0]["shortName"]).To(Equal("promo"))
+		})
+
+		It("rejects invalid pages", func() {
+			Expect(run("list", "--page", "0")).To(MatchError("page and page size must be positive"))
+			Expect(run("list", "--page-size", "0")).To(MatchError("page and page size must be positive"))
+		})
+	})
+
+	Describe("update", func() {
+		It("changes the given fields only", func() {
+			Expect(run("create", "--short-name", "sale", "--alias", "promo", "--url", "https://example.com/sale", "--comment", "Spring sale")).To(Succeed())
+
+			var link map[string]interface{}
+			runJSON(&link, "update", "--short-name", "sale", "--comment", "Summer sale", "--add-alias", "deal", "--remove-alias", "promo")
+			Expect(link["shortName"]).To(Equal("sale"))
+			Expect(link["originalUrl"]).To(Equal("https://example.com/sale"))
+			Expect(link["comment"]).To(Equal("Summer sale"))
+			Expect(link["aliases"]).To(Equal([]interface{}{"deal"}))
+
+			Expect(run("update", "1", "--set-short-name", "summer", "--url", "https://example.com/summer")).To(Succeed())
+			Expect(out.String()).To(MatchRegexp(`\n1 +summer +deal +https://example.com/summer +Summer sale\n`))
+			_, err := linkStorage.GetOneByShortName("promo")
+			Expect(errors.Cause(err)).To(Equal(storage.ErrNotFound))
+		})
+
+		It("rejects the invalid changes", func() {
+			insert("sale")
+			insert("promo")
+			Expect(run("update", "1", "--url", "not a url")).To(MatchError(ContainSubstring("'url' tag")))
+			Expect(run("update", "1", "--add-alias", "no spaces")).To(MatchError(ContainSubstring("'shortname' tag")))
+			Expect(errors.Cause(run("update", "1", "--set-short-name", "promo"))).To(Equal(storage.ErrShortNameAlreadyExists))
+			Expect(errors.Cause(run("update", "3", "--comment", "missing"))).To(Equal(storage.ErrNotFound))
+			Expect(out.String()).To(BeEmpty())
+
+			link, err := linkStorage.GetOne("1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(link.ShortName).To(Equal("sale"))
+			Expect(link.OriginalURL).To(Equal("https://example.com/sale"))
+			Expect(link.Aliases).To(BeEmpty())
+		})
+	})
+
+	Describe("delete", func() {
+		It("deletes a link", func() {
+			insert("sale")
+			insert("promo")
+			Expect(run("delete", "--short-name", "sale")).To(Succeed())
+			Expect(out.String()).To(Equal("Deleted link 1 (sale)\n"))
+
+			var link map[string]interface{}
+			runJSON(&link, "delete", "2")
+			Expect(link["shortName"]).To(Equal("promo"))
+
+			_, total, err := linkStorage.PaginatedGetAll(1, 10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(total).To(BeZero())
+		})
+
+		It("fails for the missing links", func() {
+			Expect(errors.Cause(run("delete", "1"))).To(Equal(storage.ErrNotFound))
+			Expect(run("delete")).To(MatchError("the link id or the short name must be given"))
+		})
+	})
+
+	Describe("check-reserved", func() {
+		It("lists the links with the reserved short names", func() {
+			insert("sale")
+			Expect(run("check-reserved", "--reserved-short-name", "admin")).To(Succeed())
+			Expect(out.String()).To(Equal("No links conflict with the reserved short names.\n"))
+
+			insert("admin")
+			link := insert("other")
+			updated := *link
+			updated.Aliases = []string{"team-blue"}
+			Expect(linkStorage.Update(updated)).To(Succeed())
+
+			err := run("check-reserved", "--reserved-short-name", "admin", "--reserved-short-name-pattern", "team-.+")
+			Expect(err).To(MatchError("2 links conflict with the reserved short names"))
+			Expect(out.String()).To(MatchRegexp(`\n2 +admin +.*\n3 +other +team-blue +.*\n$`))
+
+			var links []map[string]interface{}
+			Expect(run("--output", "json", "check-reserved", "--reserved-short-name", "admin")).To(HaveOccurred())
+			Expect(json.Unmarshal(out.Bytes(), &links)).To(Succeed())
+			Expect(links).To(HaveLen(1))
+			Expect(links[0]["shortName"]).To(Equal("admin"))
+		})
+
+		It("rejects invalid patterns", func() {
+			Expect(run("check-reserved", "--reserved-short-name-pattern", "(")).To(HaveOccurred())
+		})
+	})
+
+	Describe("check-case", func() {
+		It("fails if the short names collide after case folding", func() {
+			insert("Hello")
+			insert("hello")
+			insert("other")
+			err := run("check-case", "--fix")
+			Expect(err).To(MatchError(ContainSubstring("2 links collide after case folding")))
+			Expect(out.String()).To(MatchRegexp(`\n1 +Hello +.*\n2 +hello +.*\n$`))
+
+			link, err := linkStorage.GetOne("1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(link.ShortName).To(Equal("Hello"), "nothing is folded when the short names collide")
+		})
+
+		It("folds the short names with --fix", func() {
+			insert("Hello")
+			insert("other")
+			Expect(run("check-case")).To(MatchError(ContainSubstring("1 short names are not stored case-folded")))
+
+			Expect(run("check-case", "--fix")).To(Succeed())
+			Expect(out.String()).To(Equal("Folded 1 short names.\nNo links collide after case folding.\n"))
+			link, err := linkStorage.GetOne("1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(link.ShortName).To(Equal("hello"))
+
+			Expect(run("check-case")).To(Succeed())
+			Expect(out.String()).To(Equal("No links collide after case folding.\n"))
+		})
+	})
+})
@@ -1,15 +1,28 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"github.com/denisvmedia/urlshortener/blocklist"
 	"github.com/denisvmedia/urlshortener/metrics"
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/ratelimit"
 	"github.com/denisvmedia/urlshortener/server"
-	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/denisvmedia/urlshortener/shortener"
+	"github.com/denisvmedia/urlshortener/validator"
+	"github.com/go-extras/errors"
 	"github.com/jessevdk/go-flags"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
+	"regexp"
+	"strings"
 	"syscall"
+	"time"
 )
 
 // RegisterRunCommand registers `run` command
@@ -24,44 +37,308 @@ func RegisterRunCommand(parser *flags.Parser) *RunCommand {
 
 // RunCommand defines `run` command
 type RunCommand struct {
-	BindAddress string `long:"bind-address" description:"http bind address" default:":31456" env:"BIND_ADDRESS"`
-	Storage     string `long:"storage" description:"storage to use" choice:"mysql" choice:"inmemory" default:"inmemory" env:"STORAGE"`
+	BindAddress      string `long:"bind-address" description:"http bind address" default:":31456" env:"BIND_ADDRESS"`
+	AdminBindAddress string `long:"admin-bind-address" description:"http bind address of the metrics, swagger, health and pprof endpoints (served with the api if empty, without pprof)" env:"ADMIN_BIND_ADDRESS"`
+	Storage          string `long:"storage" description:"storage to use" choice:"mysql" choice:"inmemory" default:"inmemory" env:"STORAGE"`
+	// ShutdownDelay gives the load balancers time to notice that the server is draining
+	ShutdownDelay   time.Duration `long:"shutdown-delay" description:"how long the server keeps accepting requests after it starts draining on shutdown" default:"5s" env:"SHUTDOWN_DELAY"`
+	ShutdownTimeout time.Duration `long:"shutdown-timeout" description:"how long the in-flight requests are waited for on shutdown before the connections are closed" default:"30s" env:"SHUTDOWN_TIMEOUT"`
+	// TrustProxyHeaders must only be enabled behind a proxy, as the clients can set the headers to anything
+	TrustProxyHeaders bool `long:"trust-proxy-headers" description:"take the client ip address from X-Forwarded-For and X-Real-IP headers for the rate limits" env:"TRUST_PROXY_HEADERS"`
+	// the blocklist files are reloaded by the rescans, not by SIGHUP
+	BlocklistRescanInterval time.Duration `long:"blocklist-rescan-interval" description:"how often the blocklist files are reloaded and all the links are checked against them" default:"1h" env:"BLOCKLIST_RESCAN_INTERVAL"`
 	Mysql
+	ShortNameCase
+	ShortNames
+	BlocklistFiles
+	Reloadable
 }
 
-func setUpGracefulExit(server *http.Server) {
-	c := make(chan os.Signal)
+// Reloadable describes the `run` command arguments that are reloaded on SIGHUP
+type Reloadable struct {
+	LogLevel string `long:"log-level" description:"log level (the access log is written on info and debug levels)" choice:"debug" choice:"info" choice:"warn" choice:"error" choice:"off" default:"info" env:"LOG_LEVEL"`
+	ReservedShortNames
+	ShortNameScripts
+	DestinationDomains
+	RedirectChains
+	RateLimits
+}
+
+// RateLimits describes command-line arguments related to the rate limits, the limits apply
+// to each client ip address and to each api key (X-API-Key header) separately
+type RateLimits struct {
+	APIWrites      float64 `long:"rate-limit-api-writes" description:"api requests changing the data allowed per second, 0 disables the limit" default:"2" env:"RATE_LIMIT_API_WRITES"`
+	APIWritesBurst int     `long:"rate-limit-api-writes-burst" description:"api requests changing the data allowed at once" default:"60" env:"RATE_LIMIT_API_WRITES_BURST"`
+	Redirects      float64 `long:"rate-limit-redirects" description:"redirects allowed per second, 0 disables the limit" default:"50" env:"RATE_LIMIT_REDIRECTS"`
+	RedirectsBurst int     `long:"rate-limit-redirects-burst" description:"redirects allowed at once" default:"200" env:"RATE_LIMIT_REDIRECTS_BURST"`
+}
+
+func (r RateLimits) policies() (apiWrites, redirects ratelimit.Policy, err error) {
+	if r.APIWrites < 0 || r.APIWritesBurst < 0 || r.Redirects < 0 || r.RedirectsBurst < 0 {
+		return apiWrites, redirects, errors.New("rate limits must not be negative")
+	}
+
+	return ratelimit.Policy{Rate: r.APIWrites, Burst: r.APIWritesBurst},
+		ratelimit.Policy{Rate: r.Redirects, Burst: r.RedirectsBurst}, nil
+}
+
+var logLevels = map[string]log.Lvl{
+	"debug": log.DEBUG,
+	"info":  log.INFO,
+	"warn":  log.WARN,
+	"error": log.ERROR,
+	"off":   log.OFF,
+}
+
+// apply validates the settings and then applies them all (nothing is applied if any of them is invalid),
+// each setting is swapped atomically so that the requests being processed are not affected
+func (r Reloadable) apply(e *echo.Echo, limits *server.RateLimits) error {
+	level, ok := logLevels[r.LogLevel]
+	if !ok {
+		return errors.Errorf("unknown log level %s", r.LogLevel)
+	}
+	apiWrites, redirects, err := r.RateLimits.policies()
+	if err != nil {
+		return err
+	}
+
+	var setters []func()
+	for _, prepare := range []func() (func(), error){
+		r.ReservedShortNames.prepare,
+		r.ShortNameScripts.prepare,
+		r.DestinationDomains.prepare,
+		r.RedirectChains.prepare,
+	} {
+		set, err := prepare()
+		if err != nil {
+			return err
+		}
+		setters = append(setters, set)
+	}
+	for _, set := range setters {
+		set()
+	}
+	e.Logger.SetLevel(level)
+	limits.APIWrites.SetPolicy(apiWrites)
+	limits.Redirects.SetPolicy(redirects)
+
+	return nil
+}
+
+// reloadableSettings lists the settings reloaded on SIGHUP in words, e.g. "log level, ... and rate limits",
+// they are taken from the fields of Reloadable
+func reloadableSettings() string {
+	t := reflect.TypeOf(Reloadable{})
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		names = append(names, strings.ToLower(camelCaseWords.ReplaceAllString(t.Field(i).Name, "$1 $2")))
+	}
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// camelCaseWords matches the boundaries of the words of a camel case name
+var camelCaseWords = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+
+// ParseRunCommand parses the arguments of `run` command (taking the config file into account) without executing it
+func ParseRunCommand(args []string) (*RunCommand, error) {
+	parser := NewParser(&Options{})
+	parser.Options &^= flags.PrintErrors
+	if _, err := LoadConfig(parser, args); err != nil {
+		return nil, err
+	}
+
+	var cmd *RunCommand
+	parser.CommandHandler = func(command flags.Commander, _ []string) error {
+		cmd, _ = command.(*RunCommand)
+		return nil
+	}
+	if _, err := parser.ParseArgs(args); err != nil {
+		return nil, err
+	}
+	if cmd == nil {
+		return nil, errors.New("arguments of run command expected")
+	}
+
+	return cmd, nil
+}
+
+// reload re-reads the arguments and the config file and applies the reloadable settings,
+// the changes of the other settings are reported as they require a restart
+func (cmd *RunCommand) reload(e *echo.Echo, limits *server.RateLimits) {
+	next, err := ParseRunCommand(os.Args[1:])
+	if err == nil {
+		err = next.Reloadable.apply(e, limits)
+	}
+	if err != nil {
+		metrics.ConfigReloads.WithLabelValues("failure").Inc()
+		metrics.ConfigLastReloadSuccessful.Set(0)
+		e.Logger.Errorf("configuration reload failed, keeping the current settings: %s", err)
+		return
+	}
+
+	current, changed := *cmd, *next
+	current.Reloadable, changed.Reloadable = Reloadable{}, Reloadable{}
+	if !reflect.DeepEqual(current, changed) {
+		e.Logger.Warnf("only %s are reloaded, the other changes require a restart", reloadableSettings())
+	}
+	metrics.ConfigReloads.WithLabelValues("success").Inc()
+	metrics.ConfigLastReloadSuccessful.Set(1)
+	e.Logger.Infof("configuration reloaded")
+}
+
+func setUpReload(cmd *RunCommand, e *echo.Echo, limits *server.RateLimits) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	go func() {
+		for range c {
+			cmd.reload(e, limits)
+		}
+	}()
+}
+
+// setUpGracefulExit shuts the server down on SIGINT/SIGTERM, the returned channel is closed once it's done
+func (cmd *RunCommand) setUpGracefulExit(servers []*echo.Echo, status *server.Status, closers ...io.Closer) <-chan struct{} {
+	done := make(chan struct{})
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		// graceful exit on ctrl-c
+		defer close(done)
 		<-c
-		_ = server.Close()
+		cmd.shutdown(servers, status, c, closers)
+	}()
+
+	return done
+}
+
+// shutdown marks the server as draining, waits for the in-flight requests of the servers (a repeated signal or
+// the shutdown timeout cuts this short) and then releases the resources in the given order
+func (cmd *RunCommand) shutdown(servers []*echo.Echo, status *server.Status, signals <-chan os.Signal, closers []io.Closer) {
+	e := servers[0]
+	e.Logger.Infof("shutting down, draining the connections for up to %s", cmd.ShutdownDelay+cmd.ShutdownTimeout)
+	status.StartDraining()
+	select {
+	case <-time.After(cmd.ShutdownDelay):
+	case <-signals:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cmd.ShutdownTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
 	}()
+	for _, s := range servers {
+		if err := s.Shutdown(ctx); err != nil {
+			e.Logger.Warnf("the connections weren't drained in time, closing them: %s", err)
+			_ = s.Close()
+		}
+	}
+
+	for _, closer := range closers {
+		if err := closer.Close(); err != nil {
+			e.Logger.Errorf("shutdown: %s", err)
+		}
+	}
 }
 
 // Execute implements `run` command
 func (cmd *RunCommand) Execute(_ []string) error {
-	var linkStorage linkstorage.Storage
-	if cmd.Storage == "mysql" {
-		if err := cmd.Mysql.Validate(); err != nil {
-			return err
+	cmd.ShortNameCase.apply()
+	linkStorage, tagStorage, storageCloser, err := openStorages(cmd.Storage, cmd.Mysql)
+	if err != nil {
+		return err
+	}
+	// the storage is closed last, after the in-flight requests are done. The graceful exit releases
+	// the resources once it's set up, they are released here if the command fails before that.
+	closers := []io.Closer{storageCloser}
+	handedOver := false
+	defer func() {
+		if handedOver {
+			return
 		}
+		for _, closer := range closers {
+			_ = closer.Close()
+		}
+	}()
+	if cmd.Storage == "mysql" {
 		fmt.Println("Storing all data in MySQL.")
-		dbh, err := linkstorage.MysqlConnect(cmd.Mysql.User, cmd.Mysql.Password, cmd.Mysql.Host, cmd.Mysql.Name)
+	} else {
+		fmt.Println("Storing all data in memory. All your activity will be lost after you stop the application.")
+	}
+
+	lastID, err := linkStorage.LastID()
+	if err != nil {
+		return err
+	}
+	generator, err := cmd.ShortNames.generator(uint64(lastID))
+	if err != nil {
+		return errors.Wrapf(err, "invalid short name generation settings")
+	}
+	model.SetShortNameGenerator(generator)
+
+	var flagged shortener.FlaggedLinks
+	var scanner *blocklist.Scanner
+	if cmd.BlocklistFiles.enabled() {
+		if cmd.BlocklistRescanInterval <= 0 {
+			return errors.New("blocklist rescan interval must be positive")
+		}
+		scanner, err = blocklist.NewScanner(linkStorage, cmd.BlocklistFiles.DomainFiles, cmd.BlocklistFiles.HashFiles)
 		if err != nil {
 			return err
 		}
-		linkStorage = linkstorage.NewMysqlStorage(dbh)
-	} else {
-		fmt.Println("Storing all data in memory. All your activity will be lost after you stop the application.")
-		linkStorage = linkstorage.NewInMemoryStorage()
+		validator.SetBlocklist(scanner)
+		flagged = scanner
+		closers = append([]io.Closer{scanner}, closers...)
 	}
 
 	metrics.RegisterAll()
-	e := server.NewEcho(linkStorage)
+	status := &server.Status{}
+	var admin *echo.Echo
+	if cmd.AdminBindAddress != "" {
+		admin = server.NewAdminEcho()
+	}
+	// the policies are set by the reloadable settings
+	limits := server.NewRateLimits(ratelimit.Policy{}, ratelimit.Policy{}, cmd.TrustProxyHeaders)
+	e := server.NewEcho(linkStorage, tagStorage, status, admin, limits, flagged)
+	servers := []*echo.Echo{e}
+	if admin != nil {
+		// the admin listener follows the log level of the api one
+		admin.Logger = e.Logger
+		servers = append(servers, admin)
+	}
+	validator.SetRouteShortNames(server.ReservedShortNames(e))
+	if err = cmd.Reloadable.apply(e, limits); err != nil {
+		return err
+	}
+	metrics.ConfigLastReloadSuccessful.Set(1)
 	fmt.Printf("Listening on %s\n", cmd.BindAddress)
-	setUpGracefulExit(e.Server)
-	e.Logger.Fatal(e.Start(cmd.BindAddress))
+	setUpReload(cmd, e, limits)
+	if scanner != nil {
+		scanner.Start(cmd.BlocklistRescanInterval, func(err error) {
+			e.Logger.Errorf("blocklist scan failed: %s", err)
+		})
+	}
+	done := cmd.setUpGracefulExit(servers, status, closers...)
+	handedOver = true
+	if admin != nil {
+		fmt.Printf("Admin endpoints listening on %s\n", cmd.AdminBindAddress)
+		go func() {
+			if err := admin.Start(cmd.AdminBindAddress); err != http.ErrServerClosed {
+				e.Logger.Fatalf("admin listener failed: %s", err)
+			}
+		}()
+	}
+	if err = e.Start(cmd.BindAddress); err != http.ErrServerClosed {
+		return err
+	}
+	<-done
 
 	return nil
 }
@@ -0,0 +1,78 @@
+package cmd_test
+
+import (
+	"time"
+
+	"github.com/denisvmedia/urlshortener/cmd"
+	"github.com/denisvmedia/urlshortener/ratelimit"
+	"github.com/denisvmedia/urlshortener/server"
+	"github.com/denisvmedia/urlshortener/shortname"
+	"github.com/denisvmedia/urlshortener/validator"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reloadable settings", func() {
+	var e *echo.Echo
+	var limits *server.RateLimits
+
+	BeforeEach(func() {
+		e = echo.New()
+		limits = server.NewRateLimits(ratelimit.Policy{}, ratelimit.Policy{}, false)
+	})
+
+	AfterEach(func() {
+		Expect(cmd.Reloadable{RedirectChains: cmd.RedirectChains{MaxDepth: validator.DefaultRedirectChainDepth}, LogLevel: "info"}.Apply(e, limits)).To(Succeed())
+	})
+
+	It("names all the reloaded settings", func() {
+		Expect(cmd.ReloadableSettings()).To(Equal("log level, reserved short names, short name scripts, destination domains, redirect chains and rate limits"))
+	})
+
+	It("applies nothing if any of the settings is invalid", func() {
+		settings := cmd.Reloadable{
+			LogLevel:           "info",
+			ReservedShortNames: cmd.ReservedShortNames{Names: []string{"admin"}},
+			ShortNameScripts:   cmd.ShortNameScripts{Scripts: []string{"Latin"}},
+			DestinationDomains: cmd.DestinationDomains{Deny: []string{"example.com"}},
+			RedirectChains:     cmd.RedirectChains{MaxDepth: -1},
+		}
+		Expect(settings.Apply(e, limits)).To(MatchError(ContainSubstring("redirect chain depth must not be negative")))
+		Expect(validator.IsReservedShortName("admin")).To(BeFalse())
+		Expect(validator.IsAllowedDestination("example.com")).To(BeTrue())
+		Expect(validator.CurrentRedirectChains().MaxDepth).To(Equal(validator.DefaultRedirectChainDepth))
+
+		settings.RedirectChains.MaxDepth = 1
+		Expect(settings.Apply(e, limits)).To(Succeed())
+		Expect(validator.IsReservedShortName("admin")).To(BeTrue())
+		Expect(validator.IsAllowedDestination("example.com")).To(BeFalse())
+		Expect(validator.CurrentRedirectChains().MaxDepth).To(Equal(1))
+	})
+})
+
+var _ = Describe("Run command", func() {
+	It("keeps serving for a while after it starts draining by default", func() {
+		run, err := cmd.ParseRunCommand([]string{"run"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(run.ShutdownDelay).To(Equal(5 * time.Second))
+	})
+
+	It("generates lowercase short names if they are case-insensitive", func() {
+		validator.SetCaseInsensitiveShortNames(true)
+		defer validator.SetCaseInsensitiveShortNames(false)
+
+		for _, strategy := range []string{"random", "hashids"} {
+			g, err := cmd.ShortNames{Strategy: strategy, Length: 8, Salt: "my salt"}.Generator(0)
+			Expect(err).ToNot(HaveOccurred())
+			for i := 0; i < 100; i++ {
+				name, err := g.Generate()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(name).To(MatchRegexp(`^[a-z0-9]{8,}$`), strategy)
+			}
+		}
+
+		_, err := cmd.ShortNames{Strategy: "counter", Alphabet: shortname.Base62}.Generator(0)
+		Expect(err).To(MatchError(ContainSubstring("must be lowercase")))
+	})
+})
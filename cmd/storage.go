@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"io"
+
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/denisvmedia/urlshortener/storage/tagstorage"
+)
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// openStorages creates the link and tag storages of the given kind, the returned closer
+// releases the resources behind them (i.e. the db connections)
+func openStorages(kind string, mysql Mysql) (linkstorage.Storage, tagstorage.Storage, io.Closer, error) {
+	if kind != "mysql" {
+		return linkstorage.NewInMemoryStorage(), tagstorage.NewInMemoryStorage(), nopCloser{}, nil
+	}
+
+	if err := mysql.Validate(); err != nil {
+		return nil, nil, nil, err
+	}
+	dbh, err := linkstorage.MysqlConnect(mysql.User, mysql.Password, mysql.Host, mysql.Name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return linkstorage.NewMysqlStorage(dbh), tagstorage.NewMysqlStorage(dbh), dbh, nil
+}
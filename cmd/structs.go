@@ -1,13 +1,172 @@
 package cmd
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/denisvmedia/urlshortener/blocklist"
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/shortname"
+	"github.com/denisvmedia/urlshortener/validator"
+)
 
 // Mysql describes command-line arguments related to Mysql storage
 type Mysql struct {
 	Host     string `long:"mysql-host" description:"mysql storage DB host with port" default:"localhost:3306" env:"MYSQL_HOST"`
 	Name     string `long:"mysql-dbname" description:"mysql storage DB name" env:"MYSQL_DBNAME"`
 	User     string `long:"mysql-user" description:"mysql storage DB user" env:"MYSQL_USER"`
-	Password string `long:"mysql-password" description:"mysql storage DB password" env:"MYSQL_PASSWORD"`
+	Password string `long:"mysql-password" description:"mysql storage DB password" env:"MYSQL_PASSWORD" secret:"yes"`
+}
+
+// ReservedShortNames describes command-line arguments related to the short names that can't be used by the links
+// in addition to the ones taken by the server routes
+type ReservedShortNames struct {
+	Names    []string `long:"reserved-short-name" description:"reserved short name (can be repeated)" env:"RESERVED_SHORT_NAMES" env-delim:","`
+	Patterns []string `long:"reserved-short-name-pattern" description:"regular expression of reserved short names, it must match the whole short name (can be repeated)" env:"RESERVED_SHORT_NAME_PATTERNS" env-delim:","`
+}
+
+// prepare checks the reserved short names and returns the function reserving them
+func (r ReservedShortNames) prepare() (func(), error) {
+	return validator.PrepareReservedShortNames(r.Names, r.Patterns)
+}
+
+// setPrepared runs the function returned by a prepare method if the settings are valid
+func setPrepared(set func(), err error) error {
+	if err != nil {
+		return err
+	}
+	set()
+
+	return nil
+}
+
+// ShortNameScripts describes command-line arguments related to the letters of the short names given by the users
+type ShortNameScripts struct {
+	Scripts []string `long:"shortname-script" description:"unicode script the letters of the short names must belong to, e.g. Latin, Cyrillic or Han (can be repeated, all are allowed if none is given)" env:"SHORTNAME_SCRIPTS" env-delim:","`
+}
+
+// apply makes the validator only accept the letters of the scripts
+func (s ShortNameScripts) apply() error {
+	return setPrepared(s.prepare())
+}
+
+// prepare checks the scripts and returns the function applying them
+func (s ShortNameScripts) prepare() (func(), error) {
+	return validator.PrepareShortNameScripts(s.Scripts)
+}
+
+// ShortNameCase describes command-line arguments related to the case of the short names, it changes the form
+// the short names are stored in, so it must be the same for all the commands using the storage
+type ShortNameCase struct {
+	CaseInsensitive bool `long:"case-insensitive-short-names" description:"make the short names case-insensitive, they are stored and looked up case-folded (check the existing links with link check-case first)" env:"CASE_INSENSITIVE_SHORT_NAMES"`
+}
+
+// apply makes the storages fold the short names if they are case-insensitive
+func (c ShortNameCase) apply() {
+	validator.SetCaseInsensitiveShortNames(c.CaseInsensitive)
+}
+
+// DestinationDomains describes command-line arguments related to the domains the links can point to
+type DestinationDomains struct {
+	Allow []string `long:"allow-domain" description:"allowed destination domain, *.domain for its subdomains, ip address or CIDR block (can be repeated, all are allowed if none is given)" env:"ALLOWED_DOMAINS" env-delim:","`
+	Deny  []string `long:"deny-domain" description:"denied destination domain, *.domain for its subdomains, ip address or CIDR block (can be repeated, takes precedence over the allowed ones)" env:"DENIED_DOMAINS" env-delim:","`
+	// DenyPrivate protects the services in the private networks, e.g. from the tools fetching the link previews
+	DenyPrivate bool `long:"deny-private-destinations" description:"reject the destinations that are (or resolve to) loopback, link-local, private or reserved addresses, also checked on redirect" env:"DENY_PRIVATE_DESTINATIONS"`
+}
+
+// apply makes the validator check the destinations
+func (d DestinationDomains) apply() error {
+	return setPrepared(d.prepare())
+}
+
+// prepare checks the domain rules and returns the function applying them
+func (d DestinationDomains) prepare() (func(), error) {
+	set, err := validator.PrepareDestinationDomains(d.Allow, d.Deny)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		set()
+		validator.SetDenyPrivateDestinations(d.DenyPrivate)
+	}, nil
+}
+
+// RedirectChains describes command-line arguments related to the links to the short links of the service itself
+type RedirectChains struct {
+	SelfHosts []string `long:"self-host" description:"host (with an optional port) the short links are served on, the links to it are followed to detect the redirect loops (can be repeated)" env:"SELF_HOSTS" env-delim:","`
+	MaxDepth  int      `long:"redirect-chain-depth" description:"max number of the short links a link may lead through, 0 rejects all the links to the self hosts" default:"3" env:"REDIRECT_CHAIN_DEPTH"`
+	Flatten   bool     `long:"flatten-redirect-chains" description:"make the links to the short links lead to the final destination of the chain" env:"FLATTEN_REDIRECT_CHAINS"`
+}
+
+// apply makes the links to the self hosts be followed before storing them
+func (r RedirectChains) apply() error {
+	return setPrepared(r.prepare())
+}
+
+// prepare checks the settings and returns the function applying them
+func (r RedirectChains) prepare() (func(), error) {
+	return validator.PrepareRedirectChains(validator.RedirectChains{
+		Hosts:    r.SelfHosts,
+		MaxDepth: r.MaxDepth,
+		Flatten:  r.Flatten,
+	})
+}
+
+// BlocklistFiles describes command-line arguments related to the malicious url blocklist
+type BlocklistFiles struct {
+	DomainFiles []string `long:"blocklist-domains-file" description:"file with a malicious domain per line, the links to them and their subdomains are rejected (can be repeated)" env:"BLOCKLIST_DOMAINS_FILES" env-delim:","`
+	HashFiles   []string `long:"blocklist-hashes-file" description:"file with a hex encoded SHA-256 prefix of a malicious url expression per line (can be repeated)" env:"BLOCKLIST_HASHES_FILES" env-delim:","`
+}
+
+func (b BlocklistFiles) enabled() bool {
+	return len(b.DomainFiles) > 0 || len(b.HashFiles) > 0
+}
+
+// apply loads the files and makes the validator reject the links to the listed urls
+func (b BlocklistFiles) apply() error {
+	if !b.enabled() {
+		return nil
+	}
+	list, err := blocklist.Load(b.DomainFiles, b.HashFiles)
+	if err != nil {
+		return err
+	}
+	validator.SetBlocklist(list)
+
+	return nil
+}
+
+// ShortNames describes command-line arguments related to the generation of the short names
+// of the links created without one
+type ShortNames struct {
+	Strategy string `long:"shortname-strategy" description:"how the short names are generated: random base62 chars, a counter encoded with the alphabet, obfuscated counter (hashids) or random words" choice:"random" choice:"counter" choice:"hashids" choice:"words" default:"random" env:"SHORTNAME_STRATEGY"`
+	Length   int    `long:"shortname-length" description:"length of the random short names, min length of the hashids ones" default:"8" env:"SHORTNAME_LENGTH"`
+	Alphabet string `long:"shortname-alphabet" description:"alphabet of the counter short names" default:"0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ" env:"SHORTNAME_ALPHABET"`
+	Salt     string `long:"shortname-salt" description:"salt of the hashids short names, keep it secret and don't change it" env:"SHORTNAME_SALT" secret:"yes"`
+	Words    int    `long:"shortname-words" description:"number of words of the words short names" default:"2" env:"SHORTNAME_WORDS"`
+}
+
+// generator creates the short name generator, the counters start from the given number, which must not be less
+// than the number of the counter values already given (the highest link id is used, as every link takes a value)
+func (s ShortNames) generator(start uint64) (model.ShortNameGenerator, error) {
+	// the short names differing in case only would collide, so the random and hashids ones only use lowercase chars
+	alphabet := shortname.Base62
+	if validator.CaseInsensitiveShortNames() {
+		alphabet = shortname.Base36
+	}
+	switch s.Strategy {
+	case "counter":
+		if validator.CaseInsensitiveShortNames() && validator.FoldShortName(s.Alphabet) != s.Alphabet {
+			return nil, errors.New("the counter alphabet must be lowercase if the short names are case-insensitive")
+		}
+		return shortname.NewCounter(s.Alphabet, start)
+	case "hashids":
+		return shortname.NewHashids(alphabet, s.Salt, s.Length, start)
+	case "words":
+		return shortname.NewWords(s.Words)
+	default:
+		return shortname.NewRandom(alphabet, s.Length)
+	}
 }
 
 // Validate validates Mysql storage arguments
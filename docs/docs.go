@@ -32,6 +32,38 @@ var doc = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/aliases/{id}": {
+            "get": {
+                "description": "get alias by its short name",
+                "consumes": [
+                    "application/vnd.api+json"
+                ],
+                "produces": [
+                    "application/vnd.api+json"
+                ],
+                "tags": [
+                    "links"
+                ],
+                "summary": "Get an alias",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Alias short name",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/jsonapi.Alias"
+                        }
+                    }
+                }
+            }
+        },
         "/links": {
             "get": {
                 "description": "get links",
@@ -60,6 +92,27 @@ var doc = `{
                         "description": "Page size",
                         "name": "page[size]",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Full-text search in the original urls and comments (results are ordered by relevance)",
+                        "name": "filter[q]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tag name to filter the links by",
+                        "name": "filter[tag]",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "tags"
+                        ],
+                        "type": "string",
+                        "description": "Related resources to include into the response",
+                        "name": "include",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -72,7 +125,7 @@ var doc = `{
                 }
             },
             "post": {
-                "description": "add by link json",
+                "description": "add by link json, with dedupe=true the oldest link with the same canonical original url is returned\ninstead of creating a new one if the short name is not given (meta.deduplicated tells which happened)",
                 "consumes": [
                     "application/vnd.api+json"
                 ],
@@ -92,6 +145,12 @@ var doc = `{
                         "schema": {
                             "$ref": "#/definitions/jsonapi.CreateLink"
                         }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return the existing link with the same original url",
+                        "name": "dedupe",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -124,6 +183,15 @@ var doc = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "enum": [
+                            "tags"
+                        ],
+                        "type": "string",
+                        "description": "Related resources to include into the response",
+                        "name": "include",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -131,12 +199,18 @@ var doc = `{
                         "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/jsonapi.Link"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the link, to be sent in If-Match to update or delete it only if it hasn't changed"
+                            }
                         }
                     }
                 }
             },
             "delete": {
-                "description": "Delete by link ID",
+                "description": "Delete by link ID, with If-Match only if the link hasn't changed since its ETag was read",
                 "consumes": [
                     "application/vnd.api+json"
                 ],
@@ -154,6 +228,12 @@ var doc = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the link",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -161,7 +241,7 @@ var doc = `{
                 }
             },
             "patch": {
-                "description": "Update by link json",
+                "description": "Update by link json, with If-Match only if the link hasn't changed since its ETag was read",
                 "consumes": [
                     "application/vnd.api+json"
                 ],
@@ -180,6 +260,12 @@ var doc = `{
                         "in": "path",
                         "required": true
                     },
+                    {
+                        "type": "string",
+                        "description": "ETag of the link",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
                     {
                         "description": "Update link",
                         "name": "account",
@@ -195,6 +281,247 @@ var doc = `{
                         "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/jsonapi.CreatedLink"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "New version of the link"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/links/{id}/aliases": {
+            "get": {
+                "description": "get the aliases of a link, they are changed with the aliases relationship of the link",
+                "consumes": [
+                    "application/vnd.api+json"
+                ],
+                "produces": [
+                    "application/vnd.api+json"
+                ],
+                "tags": [
+                    "links"
+                ],
+                "summary": "List the aliases of a link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Link ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/jsonapi.Aliases"
+                        }
+                    }
+                }
+            }
+        },
+        "/operations": {
+            "post": {
+                "description": "add, update and remove links in a single all-or-nothing request",
+                "consumes": [
+                    "application/vnd.api+json"
+                ],
+                "produces": [
+                    "application/vnd.api+json"
+                ],
+                "tags": [
+                    "links"
+                ],
+                "summary": "Apply atomic operations",
+                "parameters": [
+                    {
+                        "description": "Operations",
+                        "name": "operations",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/jsonapi.Operations"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/jsonapi.OperationResults"
+                        }
+                    },
+                    "204": {}
+                }
+            }
+        },
+        "/tags": {
+            "get": {
+                "description": "get tags",
+                "consumes": [
+                    "application/vnd.api+json"
+                ],
+                "produces": [
+                    "application/vnd.api+json"
+                ],
+                "tags": [
+                    "tags"
+                ],
+                "summary": "List tags",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page[number]",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 1000,
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Page size",
+                        "name": "page[size]",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/jsonapi.Tags"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "add by tag json",
+                "consumes": [
+                    "application/vnd.api+json"
+                ],
+                "produces": [
+                    "application/vnd.api+json"
+                ],
+                "tags": [
+                    "tags"
+                ],
+                "summary": "Create a new tag",
+                "parameters": [
+                    {
+                        "description": "Add tag",
+                        "name": "tag",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/jsonapi.CreateTag"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/jsonapi.CreatedTag"
+                        }
+                    }
+                }
+            }
+        },
+        "/tags/{id}": {
+            "get": {
+                "description": "get tag by ID",
+                "consumes": [
+                    "application/vnd.api+json"
+                ],
+                "produces": [
+                    "application/vnd.api+json"
+                ],
+                "tags": [
+                    "tags"
+                ],
+                "summary": "Get a tag",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tag ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/jsonapi.Tag"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete by tag ID",
+                "consumes": [
+                    "application/vnd.api+json"
+                ],
+                "produces": [
+                    "application/vnd.api+json"
+                ],
+                "tags": [
+                    "tags"
+                ],
+                "summary": "Delete a tag",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Tag ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {}
+                }
+            },
+            "patch": {
+                "description": "Update by tag json",
+                "consumes": [
+                    "application/vnd.api+json"
+                ],
+                "produces": [
+                    "application/vnd.api+json"
+                ],
+                "tags": [
+                    "tags"
+                ],
+                "summary": "Update a tag",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Tag ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Update tag",
+                        "name": "tag",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/jsonapi.CreateTag"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/jsonapi.CreatedTag"
                         }
                     }
                 }
@@ -202,6 +529,51 @@ var doc = `{
         }
     },
     "definitions": {
+        "jsonapi.Alias": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "description": "Object ID - the alias short name",
+                    "type": "string",
+                    "example": "promo"
+                },
+                "relationships": {
+                    "description": "JSON:API relationships",
+                    "type": "object",
+                    "properties": {
+                        "link": {
+                            "$ref": "#/definitions/jsonapi.ToOneRelationship"
+                        }
+                    }
+                },
+                "type": {
+                    "description": "JSON:API type",
+                    "type": "string",
+                    "example": "aliases"
+                }
+            }
+        },
+        "jsonapi.Aliases": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/jsonapi.Alias"
+                    }
+                },
+                "meta": {
+                    "type": "object",
+                    "properties": {
+                        "aliases": {
+                            "type": "integer",
+                            "format": "int64",
+                            "example": 1
+                        }
+                    }
+                }
+            }
+        },
         "jsonapi.CreateLink": {
             "type": "object",
             "properties": {
@@ -210,6 +582,14 @@ var doc = `{
                 }
             }
         },
+        "jsonapi.CreateTag": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/jsonapi.Tag"
+                }
+            }
+        },
         "jsonapi.CreatedLink": {
             "type": "object",
             "properties": {
@@ -221,6 +601,17 @@ var doc = `{
                 }
             }
         },
+        "jsonapi.CreatedTag": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/jsonapi.Tag"
+                },
+                "meta": {
+                    "type": "object"
+                }
+            }
+        },
         "jsonapi.Link": {
             "type": "object",
             "properties": {
@@ -232,6 +623,18 @@ var doc = `{
                     "type": "string",
                     "example": "1"
                 },
+                "relationships": {
+                    "description": "JSON:API relationships",
+                    "type": "object",
+                    "properties": {
+                        "aliases": {
+                            "$ref": "#/definitions/jsonapi.ToManyRelationship"
+                        },
+                        "tags": {
+                            "$ref": "#/definitions/jsonapi.ToManyRelationship"
+                        }
+                    }
+                },
                 "type": {
                     "description": "JSON:API type",
                     "type": "string",
@@ -281,6 +684,164 @@ var doc = `{
                 }
             }
         },
+        "jsonapi.Operation": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "description": "Link data (required for add and update)",
+                    "$ref": "#/definitions/jsonapi.Link"
+                },
+                "op": {
+                    "description": "Operation code",
+                    "type": "string",
+                    "enum": [
+                        "add",
+                        "update",
+                        "remove"
+                    ],
+                    "example": "add"
+                },
+                "ref": {
+                    "description": "Reference to the link (required for remove)",
+                    "$ref": "#/definitions/jsonapi.OperationRef"
+                }
+            }
+        },
+        "jsonapi.OperationRef": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "links"
+                }
+            }
+        },
+        "jsonapi.OperationResult": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/jsonapi.Link"
+                }
+            }
+        },
+        "jsonapi.OperationResults": {
+            "type": "object",
+            "properties": {
+                "atomic:results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/jsonapi.OperationResult"
+                    }
+                }
+            }
+        },
+        "jsonapi.Operations": {
+            "type": "object",
+            "properties": {
+                "atomic:operations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/jsonapi.Operation"
+                    }
+                }
+            }
+        },
+        "jsonapi.ResourceIdentifier": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "tags"
+                }
+            }
+        },
+        "jsonapi.Tag": {
+            "type": "object",
+            "properties": {
+                "attributes": {
+                    "$ref": "#/definitions/model.Tag"
+                },
+                "id": {
+                    "description": "Object ID - this field is ignored for the new objects, and must match the url for the existing objects.",
+                    "type": "string",
+                    "example": "1"
+                },
+                "type": {
+                    "description": "JSON:API type",
+                    "type": "string",
+                    "example": "tags"
+                }
+            }
+        },
+        "jsonapi.Tags": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/jsonapi.Tag"
+                    }
+                },
+                "links": {
+                    "type": "object",
+                    "properties": {
+                        "first": {
+                            "type": "string",
+                            "example": "/api/tags?page[number]=1\u0026page[size]=10"
+                        },
+                        "last": {
+                            "type": "string",
+                            "example": "/api/tags?page[number]=10\u0026page[size]=10"
+                        },
+                        "next": {
+                            "type": "string",
+                            "example": "/api/tags?page[number]=1\u0026page[size]=10"
+                        },
+                        "prev": {
+                            "type": "string",
+                            "example": "/api/tags?page[number]=1\u0026page[size]=10"
+                        }
+                    }
+                },
+                "meta": {
+                    "type": "object",
+                    "properties": {
+                        "tags": {
+                            "type": "integer",
+                            "format": "int64",
+                            "example": 1
+                        }
+                    }
+                }
+            }
+        },
+        "jsonapi.ToManyRelationship": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/jsonapi.ResourceIdentifier"
+                    }
+                }
+            }
+        },
+        "jsonapi.ToOneRelationship": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/jsonapi.ResourceIdentifier"
+                }
+            }
+        },
         "model.Link": {
             "type": "object",
             "required": [
@@ -303,6 +864,19 @@ var doc = `{
                     "example": "link-short-name"
                 }
             }
+        },
+        "model.Tag": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "description": "Tag name, must be unique",
+                    "type": "string",
+                    "example": "marketing"
+                }
+            }
         }
     }
 }`
@@ -0,0 +1,21 @@
+package jsonapi
+
+// Alias is an object that holds alias information
+type Alias struct {
+	// Object ID - the alias short name
+	ID string `json:"id" example:"promo"`
+	// JSON:API type
+	Type string `json:"type" example:"aliases"`
+	// JSON:API relationships
+	Relationships struct {
+		Link ToOneRelationship `json:"link"`
+	} `json:"relationships"`
+}
+
+// Aliases is an object that holds alias list information
+type Aliases struct {
+	Data []Alias `json:"data"`
+	Meta struct {
+		Aliases int `json:"aliases" example:"1" format:"int64"`
+	} `json:"meta"`
+}
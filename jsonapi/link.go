@@ -11,6 +11,27 @@ type Link struct {
 	// JSON:API type
 	Type       string     `json:"type" example:"links"`
 	Attributes model.Link `json:"attributes"`
+	// JSON:API relationships
+	Relationships struct {
+		Tags    ToManyRelationship `json:"tags"`
+		Aliases ToManyRelationship `json:"aliases"`
+	} `json:"relationships"`
+}
+
+// ResourceIdentifier is an object that identifies a related resource
+type ResourceIdentifier struct {
+	ID   string `json:"id" example:"1"`
+	Type string `json:"type" example:"tags"`
+}
+
+// ToManyRelationship is an object that holds to-many relationship information
+type ToManyRelationship struct {
+	Data []ResourceIdentifier `json:"data"`
+}
+
+// ToOneRelationship is an object that holds to-one relationship information
+type ToOneRelationship struct {
+	Data ResourceIdentifier `json:"data"`
 }
 
 // Links is an object that holds link list information
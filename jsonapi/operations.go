@@ -0,0 +1,32 @@
+package jsonapi
+
+// OperationRef identifies the link to update or remove
+type OperationRef struct {
+	ID   string `json:"id" example:"1"`
+	Type string `json:"type" example:"links"`
+}
+
+// Operation is a single atomic operation
+type Operation struct {
+	// Operation code
+	Op string `json:"op" example:"add" enums:"add,update,remove"`
+	// Reference to the link (required for remove)
+	Ref *OperationRef `json:"ref,omitempty"`
+	// Link data (required for add and update)
+	Data *Link `json:"data,omitempty"`
+}
+
+// Operations is an object that holds a list of atomic operations
+type Operations struct {
+	Operations []Operation `json:"atomic:operations"`
+}
+
+// OperationResult is a result of a single atomic operation (empty for remove)
+type OperationResult struct {
+	Data *Link `json:"data,omitempty"`
+}
+
+// OperationResults is an object that holds the results of atomic operations in the order of the operations
+type OperationResults struct {
+	Results []OperationResult `json:"atomic:results"`
+}
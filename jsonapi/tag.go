@@ -0,0 +1,40 @@
+package jsonapi
+
+import (
+	"github.com/denisvmedia/urlshortener/model"
+)
+
+// Tag is an object that holds tag information
+type Tag struct {
+	// Object ID - this field is ignored for the new objects, and must match the url for the existing objects.
+	ID string `json:"id" example:"1"`
+	// JSON:API type
+	Type       string    `json:"type" example:"tags"`
+	Attributes model.Tag `json:"attributes"`
+}
+
+// Tags is an object that holds tag list information
+type Tags struct {
+	Data []Tag `json:"data"`
+	Meta struct {
+		Tags int `json:"tags" example:"1" format:"int64"`
+	} `json:"meta"`
+	Links struct {
+		Next  string `json:"next" example:"/api/tags?page[number]=1&page[size]=10"`
+		Prev  string `json:"prev" example:"/api/tags?page[number]=1&page[size]=10"`
+		First string `json:"first" example:"/api/tags?page[number]=1&page[size]=10"`
+		Last  string `json:"last" example:"/api/tags?page[number]=10&page[size]=10"`
+	}
+}
+
+// CreateTag is an object that holds tag data information
+type CreateTag struct {
+	Data Tag `json:"data"`
+}
+
+// CreatedTag is an object that holds tag data information
+type CreatedTag struct {
+	Data Tag `json:"data"`
+	Meta struct {
+	} `json:"meta"`
+}
@@ -0,0 +1,70 @@
+package linkio
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/go-extras/errors"
+)
+
+// FormatBitly is the format of the Bitly CSV exports
+const FormatBitly = "bitly"
+
+// column names of the Bitly exports (they differ between the API and the web app exports)
+var (
+	bitlyLinkColumns      = []string{"link", "bitlink", "short_url", "short url"}
+	bitlyLongURLColumns   = []string{"long_url", "long url", "longurl", "destination url"}
+	bitlyTitleColumns     = []string{"title"}
+	bitlyCreatedAtColumns = []string{"created_at", "created", "date created", "created at"}
+)
+
+// BitlyReader reads links from the CSV exports of Bitly, the short name
+// is the back-half of the bitlink (e.g. 3abcDEF for https://bit.ly/3abcDEF)
+type BitlyReader struct {
+	csv    *csv.Reader
+	header csvHeader
+}
+
+// NewBitlyReader reads the header of the CSV file and creates a reader
+func NewBitlyReader(r io.Reader) (*BitlyReader, error) {
+	reader := csv.NewReader(r)
+	header, err := readCSVHeader(reader, bitlyLinkColumns, bitlyLongURLColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BitlyReader{
+		csv:    reader,
+		header: header,
+	}, nil
+}
+
+// bitlyBackHalf returns the last path segment of a bitlink
+func bitlyBackHalf(bitlink string) string {
+	bitlink = strings.TrimRight(bitlink, "/")
+
+	return bitlink[strings.LastIndex(bitlink, "/")+1:]
+}
+
+// Read implements Reader
+func (b *BitlyReader) Read() (*model.Link, error) {
+	record, err := b.csv.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	bitlink := b.header.value(record, bitlyLinkColumns...)
+	createdAt, err := parseTimestamp(b.header.value(record, bitlyCreatedAtColumns...))
+	if err != nil {
+		return nil, errors.Wrapf(err, "bitlink %s", bitlink)
+	}
+
+	return &model.Link{
+		ShortName:   bitlyBackHalf(bitlink),
+		OriginalURL: b.header.value(record, bitlyLongURLColumns...),
+		Comment:     b.header.value(record, bitlyTitleColumns...),
+		CreatedAt:   createdAt,
+	}, nil
+}
@@ -0,0 +1,161 @@
+package linkio
+
+import (
+	"io"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/storage"
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	myvalidator "github.com/denisvmedia/urlshortener/validator"
+	"github.com/go-extras/errors"
+	"github.com/go-playground/validator/v10"
+)
+
+// ConflictPolicy defines what to do with the imported links which short names are already used
+type ConflictPolicy string
+
+// Conflict policies
+const (
+	// ConflictSkip keeps the existing link
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictOverwrite replaces the original url and the comment of the existing link
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictFail stops the import
+	ConflictFail ConflictPolicy = "fail"
+)
+
+// ImportStats holds the numbers of the processed links
+type ImportStats struct {
+	Created int
+	Updated int
+	Skipped int
+	Invalid int
+}
+
+// Importer stores the links read from a Reader
+type Importer struct {
+	Storage    linkstorage.Storage
+	Validator  *validator.Validate
+	OnConflict ConflictPolicy
+	// DryRun only validates the links and checks the conflicts without storing anything
+	DryRun bool
+	// OnInvalid is called for every invalid link (1-based record number), such links are skipped
+	OnInvalid func(record int, err error)
+
+	// short names seen during the dry run (they are not stored, so the storage can't tell about them)
+	seen map[string]bool
+}
+
+// Import reads all the links from r and stores them, the stats are returned even if the import fails
+func (im *Importer) Import(r Reader) (stats ImportStats, err error) {
+	im.seen = make(map[string]bool)
+	for record := 1; ; record++ {
+		link, err := r.Read()
+		if err == io.EOF {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, err
+		}
+
+		if err = im.Validator.Struct(link); err != nil {
+			stats.Invalid++
+			if im.OnInvalid != nil {
+				im.OnInvalid(record, err)
+			}
+			continue
+		}
+
+		if im.DryRun {
+			err = im.check(*link, &stats)
+		} else {
+			err = im.store(*link, &stats)
+		}
+		if err != nil {
+			return stats, errors.Wrapf(err, "record %d", record)
+		}
+	}
+}
+
+func (im *Importer) check(link model.Link, stats *ImportStats) error {
+	// the same checks as the ones of store, so that the dry run fails where the import would
+	allocator := linkstorage.NewAllocator(im.Storage)
+	if err := allocator.Check(link); err != nil {
+		return err
+	}
+	if link.ShortName == "" {
+		// a short name is generated that doesn't conflict with anything
+		stats.Created++
+		return nil
+	}
+
+	// the short names that the storage would store the same way conflict with each other
+	// (e.g. the ones differing in case if the short names are case-insensitive)
+	shortName := myvalidator.NormalizeShortName(link.ShortName)
+	seen := im.seen[shortName]
+	im.seen[shortName] = true
+	existing, err := im.Storage.GetOneByShortName(shortName)
+	if err != nil && errors.Cause(err) != storage.ErrNotFound {
+		return err
+	}
+	if existing == nil && !seen {
+		stats.Created++
+		return nil
+	}
+
+	return im.resolveConflict(stats, storage.ErrShortNameAlreadyExists, func() error {
+		if existing == nil {
+			// a link of an earlier record, the stored one would be checked the same way
+			return nil
+		}
+		return allocator.Check(overwritten(existing, link))
+	})
+}
+
+func (im *Importer) store(link model.Link, stats *ImportStats) error {
+	// the short name is generated unless given
+	_, err := linkstorage.NewAllocator(im.Storage).Insert(link)
+	if err == nil {
+		stats.Created++
+		return nil
+	}
+	if errors.Cause(err) != storage.ErrShortNameAlreadyExists {
+		return err
+	}
+
+	return im.resolveConflict(stats, err, func() error {
+		existing, err := im.Storage.GetOneByShortName(link.ShortName)
+		if err != nil {
+			return err
+		}
+
+		_, err = linkstorage.NewAllocator(im.Storage).Update(overwritten(existing, link))
+		return err
+	})
+}
+
+// overwritten returns the existing link with the original url and the comment of the imported one
+func overwritten(existing *model.Link, link model.Link) model.Link {
+	// the storage instance must not be modified
+	updated := *existing
+	updated.OriginalURL = link.OriginalURL
+	updated.Comment = link.Comment
+
+	return updated
+}
+
+func (im *Importer) resolveConflict(stats *ImportStats, err error, overwrite func() error) error {
+	switch im.OnConflict {
+	case ConflictSkip:
+		stats.Skipped++
+		return nil
+	case ConflictOverwrite:
+		if err := overwrite(); err != nil {
+			return err
+		}
+		stats.Updated++
+		return nil
+	default:
+		return err
+	}
+}
@@ -0,0 +1,13 @@
+package linkio_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestLinkIO(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "LinkIO Suite")
+}
@@ -0,0 +1,291 @@
+package linkio_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/denisvmedia/urlshortener/linkio"
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/storage"
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/denisvmedia/urlshortener/validator"
+	"github.com/go-extras/errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("linkio", func() {
+	var readAll = func(r linkio.Reader) []model.Link {
+		var links []model.Link
+		for {
+			link, err := r.Read()
+			if err == io.EOF {
+				return links
+			}
+			Expect(err).ToNot(HaveOccurred())
+			links = append(links, *link)
+		}
+	}
+
+	Context("CSV", func() {
+		It("reads the columns by the header names", func() {
+			r, err := linkio.NewCSVReader(strings.NewReader("\ufeffComment,originalUrl,extra,shortName\n" +
+				"\"Spring, sale\",https://example.com/spring,x,spring\n" +
+				",https://example.com/other,,\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(readAll(r)).To(Equal([]model.Link{
+				{ShortName: "spring", OriginalURL: "https://example.com/spring", Comment: "Spring, sale"},
+				{OriginalURL: "https://example.com/other"},
+			}))
+		})
+
+		It("requires the original url column", func() {
+			_, err := linkio.NewCSVReader(strings.NewReader("shortName,comment\n"))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("writes what it reads", func() {
+			links := []*model.Link{
+				{ShortName: "spring", OriginalURL: "https://example.com/spring?a=1&b=2", Comment: "Spring, \"sale\""},
+				{ShortName: "other", OriginalURL: "https://example.com/other"},
+			}
+			for _, format := range []string{linkio.FormatCSV, linkio.FormatJSONL} {
+				var buf bytes.Buffer
+				w, err := linkio.NewWriter(format, &buf)
+				Expect(err).ToNot(HaveOccurred())
+				for _, link := range links {
+					Expect(w.Write(link)).To(Succeed())
+				}
+				Expect(w.Flush()).To(Succeed())
+
+				r, err := linkio.NewReader(format, &buf)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(readAll(r)).To(Equal([]model.Link{*links[0], *links[1]}), format)
+			}
+		})
+	})
+
+	Context("JSONL", func() {
+		It("skips empty lines and reports the broken ones", func() {
+			r := linkio.NewJSONLReader(strings.NewReader(`{"shortName":"a","originalUrl":"https://example.com/a"}` + "\n\n{broken\n"))
+			link, err := r.Read()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(link.ShortName).To(Equal("a"))
+			_, err = r.Read()
+			Expect(err).To(MatchError(ContainSubstring("line 3")))
+		})
+	})
+
+	Context("YOURLS", func() {
+		It("reads the url table rows from an SQL dump", func() {
+			dump := "-- MySQL dump 10.13\n" +
+				"/*!40101 SET NAMES utf8mb4 */;\n" +
+				"DROP TABLE IF EXISTS `yourls_url`;\n" +
+				"CREATE TABLE `yourls_url` (`keyword` varchar(100) NOT NULL DEFAULT '', `url` text NOT NULL) ENGINE=InnoDB;\n" +
+				"INSERT INTO `yourls_options` VALUES (1,'version','1.7.9'),(2,'db_version','482');\n" +
+				"INSERT INTO `yourls_url` VALUES ('spring','https://example.com/spring?a=1;b=2','It\\'s spring, \\\"sale\\\"','2020-03-01 10:11:12','127.0.0.1',5)," +
+				"('autumn','https://example.com/autumn',NULL,'2020-09-01 00:00:00','127.0.0.1',0);\n" +
+				"INSERT INTO yourls_url (`url`, `keyword`) VALUES ('https://example.com/winter', 'winter');\n"
+			r, err := linkio.NewReader(linkio.FormatYOURLS, strings.NewReader(dump))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(readAll(r)).To(Equal([]model.Link{
+				{
+					ShortName:   "spring",
+					OriginalURL: "https://example.com/spring?a=1;b=2",
+					Comment:     "It's spring, \"sale\"",
+					CreatedAt:   time.Date(2020, 3, 1, 10, 11, 12, 0, time.UTC),
+				},
+				{
+					ShortName:   "autumn",
+					OriginalURL: "https://example.com/autumn",
+					CreatedAt:   time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC),
+				},
+				{
+					ShortName:   "winter",
+					OriginalURL: "https://example.com/winter",
+				},
+			}))
+		})
+
+		It("reads CSV exports", func() {
+			r, err := linkio.NewReader(linkio.FormatYOURLS, strings.NewReader("keyword,url,title,timestamp,ip,clicks\n"+
+				"spring,https://example.com/spring,Spring sale,2020-03-01 10:11:12,127.0.0.1,5\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(readAll(r)).To(Equal([]model.Link{
+				{
+					ShortName:   "spring",
+					OriginalURL: "https://example.com/spring",
+					Comment:     "Spring sale",
+					CreatedAt:   time.Date(2020, 3, 1, 10, 11, 12, 0, time.UTC),
+				},
+			}))
+		})
+
+		It("reports broken dumps", func() {
+			r, err := linkio.NewReader(linkio.FormatYOURLS, strings.NewReader("INSERT INTO `yourls_url` VALUES ('spring','https://example.com"))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = r.Read()
+			Expect(err).To(HaveOccurred())
+			Expect(err).ToNot(Equal(io.EOF))
+		})
+	})
+
+	Context("Bitly", func() {
+		It("reads CSV exports", func() {
+			r, err := linkio.NewReader(linkio.FormatBitly, strings.NewReader("Title,Long URL,Bitlink,Created\n"+
+				"Spring sale,https://example.com/spring,https://bit.ly/3abcDEF,2020-03-01T10:11:12+0000\n"+
+				",https://example.com/autumn,bit.ly/autumn-sale,\n"))
+			Expect(err).ToNot(HaveOccurred())
+			links := readAll(r)
+			Expect(links).To(HaveLen(2))
+			Expect(links[0].ShortName).To(Equal("3abcDEF"))
+			Expect(links[0].OriginalURL).To(Equal("https://example.com/spring"))
+			Expect(links[0].Comment).To(Equal("Spring sale"))
+			Expect(links[0].CreatedAt.Equal(time.Date(2020, 3, 1, 10, 11, 12, 0, time.UTC))).To(BeTrue())
+			Expect(links[1]).To(Equal(model.Link{ShortName: "autumn-sale", OriginalURL: "https://example.com/autumn"}))
+		})
+	})
+
+	Context("Importer", func() {
+		var linkStorage linkstorage.Storage
+		var input = "shortName,originalUrl,comment\n" +
+			"existing,https://example.com/new,new comment\n" +
+			"fresh,https://example.com/fresh,\n" +
+			"api,https://example.com/reserved,\n" +
+			"bad-url,ftp://example.com,\n"
+
+		var importWith = func(policy linkio.ConflictPolicy, dryRun bool) (linkio.ImportStats, []int, error) {
+			r, err := linkio.NewCSVReader(strings.NewReader(input))
+			Expect(err).ToNot(HaveOccurred())
+			var invalid []int
+			importer := &linkio.Importer{
+				Storage:    linkStorage,
+				Validator:  validator.New(),
+				OnConflict: policy,
+				DryRun:     dryRun,
+				OnInvalid: func(record int, _ error) {
+					invalid = append(invalid, record)
+				},
+			}
+			stats, err := importer.Import(r)
+			return stats, invalid, err
+		}
+
+		BeforeEach(func() {
+			linkStorage = linkstorage.NewInMemoryStorage()
+			_, err := linkStorage.Insert(model.Link{ShortName: "existing", OriginalURL: "https://example.com/old", Comment: "old comment"})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("skips the conflicts and the invalid links", func() {
+			stats, invalid, err := importWith(linkio.ConflictSkip, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stats).To(Equal(linkio.ImportStats{Created: 1, Skipped: 1, Invalid: 2}))
+			Expect(invalid).To(Equal([]int{3, 4}))
+
+			existing, err := linkStorage.GetOneByShortName("existing")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(existing.OriginalURL).To(Equal("https://example.com/old"))
+			_, err = linkStorage.GetOneByShortName("fresh")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("overwrites the conflicts", func() {
+			stats, _, err := importWith(linkio.ConflictOverwrite, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stats).To(Equal(linkio.ImportStats{Created: 1, Updated: 1, Invalid: 2}))
+
+			existing, err := linkStorage.GetOneByShortName("existing")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(existing.OriginalURL).To(Equal("https://example.com/new"))
+			Expect(existing.Comment).To(Equal("new comment"))
+		})
+
+		It("fails on the conflicts", func() {
+			_, _, err := importWith(linkio.ConflictFail, false)
+			Expect(err).To(MatchError(ContainSubstring("record 1")))
+			_, total, err := linkStorage.PaginatedGetAll(1, 10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(total).To(Equal(1))
+		})
+
+		It("doesn't store anything in the dry run mode", func() {
+			stats, invalid, err := importWith(linkio.ConflictOverwrite, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stats).To(Equal(linkio.ImportStats{Created: 1, Updated: 1, Invalid: 2}))
+			Expect(invalid).To(Equal([]int{3, 4}))
+
+			existing, err := linkStorage.GetOneByShortName("existing")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(existing.OriginalURL).To(Equal("https://example.com/old"))
+			_, total, err := linkStorage.PaginatedGetAll(1, 10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(total).To(Equal(1))
+		})
+
+		It("reports the conflicts of the normalized short names in the dry run mode", func() {
+			validator.SetCaseInsensitiveShortNames(true)
+			defer validator.SetCaseInsensitiveShortNames(false)
+
+			input := "shortName,originalUrl\n" +
+				"Existing,https://example.com/existing\n" +
+				"café,https://example.com/cafe\n" +
+				"CAFE\u0301,https://example.com/cafe\n"
+			for _, dryRun := range []bool{true, false} {
+				r, err := linkio.NewCSVReader(strings.NewReader(input))
+				Expect(err).ToNot(HaveOccurred())
+				importer := &linkio.Importer{
+					Storage:    linkStorage,
+					Validator:  validator.New(),
+					OnConflict: linkio.ConflictSkip,
+					DryRun:     dryRun,
+				}
+				stats, err := importer.Import(r)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(stats).To(Equal(linkio.ImportStats{Created: 1, Skipped: 2}), "dry run: %v", dryRun)
+			}
+		})
+
+		It("fails the dry run where the import would", func() {
+			Expect(validator.SetRedirectChains(validator.RedirectChains{Hosts: []string{"sho.rt"}, MaxDepth: 1})).To(Succeed())
+			defer func() {
+				Expect(validator.SetRedirectChains(validator.RedirectChains{MaxDepth: validator.DefaultRedirectChainDepth})).To(Succeed())
+			}()
+			_, err := linkStorage.Insert(model.Link{ShortName: "pay", OriginalURL: "https://example.com/pay"})
+			Expect(err).ToNot(HaveOccurred())
+
+			for input, cause := range map[string]error{
+				"рау,https://example.com/phishing\n": storage.ErrShortNameConfusable,
+				"loop,https://sho.rt/loop\n":         storage.ErrRedirectLoop,
+				"existing,https://sho.rt/existing\n": storage.ErrRedirectLoop,
+			} {
+				for _, dryRun := range []bool{true, false} {
+					r, err := linkio.NewCSVReader(strings.NewReader("shortName,originalUrl\n" + input))
+					Expect(err).ToNot(HaveOccurred())
+					importer := &linkio.Importer{
+						Storage:    linkStorage,
+						Validator:  validator.New(),
+						OnConflict: linkio.ConflictOverwrite,
+						DryRun:     dryRun,
+					}
+					_, err = importer.Import(r)
+					Expect(errors.Cause(err)).To(Equal(cause), "%s (dry run: %v)", input, dryRun)
+				}
+			}
+		})
+
+		It("exports all the links", func() {
+			for _, name := range []string{"a", "b", "c"} {
+				_, err := linkStorage.Insert(model.Link{ShortName: name, OriginalURL: "https://example.com/" + name})
+				Expect(err).ToNot(HaveOccurred())
+			}
+			var buf bytes.Buffer
+			count, err := linkio.Export(linkStorage, linkio.NewJSONLWriter(&buf))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(Equal(4))
+			Expect(strings.Count(buf.String(), "\n")).To(Equal(4))
+		})
+	})
+})
@@ -0,0 +1,176 @@
+package linkio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/go-extras/errors"
+)
+
+// Supported file formats
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// CSV column names (they match the json names of the link attributes)
+const (
+	ColumnShortName   = "shortName"
+	ColumnOriginalURL = "originalUrl"
+	ColumnComment     = "comment"
+)
+
+// maxJSONLineSize limits the size of a single JSONL line
+const maxJSONLineSize = 1024 * 1024
+
+// Reader reads links one by one, it returns io.EOF when there are no more links
+type Reader interface {
+	Read() (*model.Link, error)
+}
+
+// NewReader creates a reader for the given format
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVReader(r)
+	case FormatJSONL:
+		return NewJSONLReader(r), nil
+	case FormatYOURLS:
+		return NewYOURLSReader(r)
+	case FormatBitly:
+		return NewBitlyReader(r)
+	default:
+		return nil, errors.Errorf("unsupported format %s", format)
+	}
+}
+
+// csvHeader maps lowercased CSV column names to their indexes
+type csvHeader map[string]int
+
+// readCSVHeader reads the header row and makes sure that the required columns (any of the names) are there
+func readCSVHeader(reader *csv.Reader, required ...[]string) (csvHeader, error) {
+	row, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("csv header is missing")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(csvHeader, len(row))
+	for i, name := range row {
+		if i == 0 {
+			// spreadsheet applications like to start the files with a byte order mark
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		header[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, names := range required {
+		if _, ok := header.index(names...); !ok {
+			return nil, errors.Errorf("csv column %s is missing", names[0])
+		}
+	}
+
+	return header, nil
+}
+
+// index returns the index of the first found column with any of the given names
+func (h csvHeader) index(names ...string) (int, bool) {
+	for _, name := range names {
+		if i, ok := h[strings.ToLower(name)]; ok {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// value returns the value of the first found column with any of the given names
+func (h csvHeader) value(record []string, names ...string) string {
+	i, ok := h.index(names...)
+	if !ok || i >= len(record) {
+		return ""
+	}
+
+	return strings.TrimSpace(record[i])
+}
+
+// CSVReader reads links from a CSV file with a header row, the column order
+// doesn't matter and the unknown columns are ignored
+type CSVReader struct {
+	csv    *csv.Reader
+	header csvHeader
+}
+
+// NewCSVReader reads the header of the CSV file and creates a reader
+func NewCSVReader(r io.Reader) (*CSVReader, error) {
+	reader := csv.NewReader(r)
+	header, err := readCSVHeader(reader, []string{ColumnOriginalURL})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CSVReader{
+		csv:    reader,
+		header: header,
+	}, nil
+}
+
+// Read implements Reader
+func (c *CSVReader) Read() (*model.Link, error) {
+	record, err := c.csv.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Link{
+		ShortName:   c.header.value(record, ColumnShortName),
+		OriginalURL: c.header.value(record, ColumnOriginalURL),
+		Comment:     c.header.value(record, ColumnComment),
+	}, nil
+}
+
+// JSONLReader reads links from a file where every line is a json object
+// with the link attributes (the empty lines are skipped)
+type JSONLReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewJSONLReader creates a new JSONLReader
+func NewJSONLReader(r io.Reader) *JSONLReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxJSONLineSize)
+
+	return &JSONLReader{
+		scanner: scanner,
+	}
+}
+
+// Read implements Reader
+func (j *JSONLReader) Read() (*model.Link, error) {
+	for j.scanner.Scan() {
+		j.line++
+		line := bytes.TrimSpace(j.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		link := &model.Link{}
+		if err := json.Unmarshal(line, link); err != nil {
+			return nil, errors.Wrapf(err, "line %d", j.line)
+		}
+
+		return link, nil
+	}
+	if err := j.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
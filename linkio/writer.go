@@ -0,0 +1,123 @@
+package linkio
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/go-extras/errors"
+)
+
+// Writer writes links one by one, Flush must be called when all the links are written
+type Writer interface {
+	Write(link *model.Link) error
+	Flush() error
+}
+
+// NewWriter creates a writer for the given format
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVWriter(w), nil
+	case FormatJSONL:
+		return NewJSONLWriter(w), nil
+	default:
+		return nil, errors.Errorf("unsupported format %s", format)
+	}
+}
+
+// CSVWriter writes links to a CSV file with a header row
+type CSVWriter struct {
+	csv           *csv.Writer
+	headerWritten bool
+}
+
+// NewCSVWriter creates a new CSVWriter
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{
+		csv: csv.NewWriter(w),
+	}
+}
+
+func (c *CSVWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+
+	return c.csv.Write([]string{ColumnShortName, ColumnOriginalURL, ColumnComment})
+}
+
+// Write implements Writer
+func (c *CSVWriter) Write(link *model.Link) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	return c.csv.Write([]string{link.ShortName, link.OriginalURL, link.Comment})
+}
+
+// Flush implements Writer (the header is written even if there were no links)
+func (c *CSVWriter) Flush() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.csv.Flush()
+
+	return c.csv.Error()
+}
+
+// JSONLWriter writes links as json objects, one per line
+type JSONLWriter struct {
+	w       *bufio.Writer
+	encoder *json.Encoder
+}
+
+// NewJSONLWriter creates a new JSONLWriter
+func NewJSONLWriter(w io.Writer) *JSONLWriter {
+	buffered := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffered)
+	encoder.SetEscapeHTML(false)
+
+	return &JSONLWriter{
+		w:       buffered,
+		encoder: encoder,
+	}
+}
+
+// Write implements Writer
+func (j *JSONLWriter) Write(link *model.Link) error {
+	return j.encoder.Encode(link)
+}
+
+// Flush implements Writer
+func (j *JSONLWriter) Flush() error {
+	return j.w.Flush()
+}
+
+// exportPageSize is the number of links loaded from the storage at once during the export
+const exportPageSize = 1000
+
+// Export writes all the links from the storage, it returns the number of the written links
+func Export(s linkstorage.Storage, w Writer) (count int, err error) {
+	for page := 1; ; page++ {
+		links, total, err := s.PaginatedGetAll(page, exportPageSize)
+		if err != nil {
+			return count, err
+		}
+		for _, link := range links {
+			if err = w.Write(link); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if len(links) == 0 || page*exportPageSize >= total {
+			break
+		}
+	}
+
+	return count, w.Flush()
+}
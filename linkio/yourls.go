@@ -0,0 +1,137 @@
+package linkio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/go-extras/errors"
+)
+
+// FormatYOURLS is the format of the YOURLS exports (either an SQL dump or a CSV file)
+const FormatYOURLS = "yourls"
+
+// yourlsColumns is the column order of the YOURLS url table
+var yourlsColumns = []string{"keyword", "url", "title", "timestamp", "ip", "clicks"}
+
+// timestampLayouts are the time formats used by the supported exports
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700 MST",
+	"2006-01-02",
+}
+
+// parseTimestamp parses the timestamps of the supported exports, the ones without a time zone are treated as UTC
+func parseTimestamp(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.Errorf("unsupported timestamp %s", value)
+}
+
+// yourlsLink maps a YOURLS url table row onto a link
+func yourlsLink(keyword, url, title, timestamp string) (*model.Link, error) {
+	createdAt, err := parseTimestamp(timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Link{
+		ShortName:   keyword,
+		OriginalURL: url,
+		Comment:     title,
+		CreatedAt:   createdAt,
+	}, nil
+}
+
+// NewYOURLSReader creates a reader for a YOURLS export, the SQL dumps are told apart
+// from the CSV files by their first statement or comment
+func NewYOURLSReader(r io.Reader) (Reader, error) {
+	buffered := bufio.NewReader(r)
+	start, err := buffered.Peek(512)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if isSQL(start) {
+		return NewYOURLSSQLReader(buffered), nil
+	}
+
+	return NewYOURLSCSVReader(buffered)
+}
+
+func isSQL(start []byte) bool {
+	start = bytes.TrimLeft(bytes.TrimPrefix(start, []byte("\ufeff")), " \t\r\n")
+	for _, prefix := range []string{"--", "/*", "#"} {
+		if bytes.HasPrefix(start, []byte(prefix)) {
+			return true
+		}
+	}
+
+	end := bytes.IndexFunc(start, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if end < 0 {
+		end = len(start)
+	}
+	switch strings.ToUpper(string(start[:end])) {
+	case "INSERT", "REPLACE", "CREATE", "DROP", "SET", "LOCK", "UNLOCK", "USE":
+		return true
+	}
+
+	return false
+}
+
+// YOURLSCSVReader reads links from the CSV exports of YOURLS (the columns are named after the url table)
+type YOURLSCSVReader struct {
+	csv    *csv.Reader
+	header csvHeader
+}
+
+// NewYOURLSCSVReader reads the header of the CSV file and creates a reader
+func NewYOURLSCSVReader(r io.Reader) (*YOURLSCSVReader, error) {
+	reader := csv.NewReader(r)
+	header, err := readCSVHeader(reader, []string{"keyword"}, []string{"url"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &YOURLSCSVReader{
+		csv:    reader,
+		header: header,
+	}, nil
+}
+
+// Read implements Reader
+func (y *YOURLSCSVReader) Read() (*model.Link, error) {
+	record, err := y.csv.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	link, err := yourlsLink(
+		y.header.value(record, "keyword"),
+		y.header.value(record, "url"),
+		y.header.value(record, "title"),
+		y.header.value(record, "timestamp"),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "keyword %s", y.header.value(record, "keyword"))
+	}
+
+	return link, nil
+}
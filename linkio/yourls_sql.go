@@ -0,0 +1,355 @@
+package linkio
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/go-extras/errors"
+)
+
+type sqlTokenKind int
+
+const (
+	sqlWord       sqlTokenKind = iota // keywords, numbers, NULL and unquoted identifiers
+	sqlIdentifier                     // `quoted identifiers`
+	sqlString                         // 'quoted strings'
+	sqlPunct                          // everything else, one char at a time
+)
+
+type sqlToken struct {
+	kind  sqlTokenKind
+	value string
+}
+
+func (t sqlToken) is(kind sqlTokenKind, value string) bool {
+	return t.kind == kind && strings.EqualFold(t.value, value)
+}
+
+// sqlLexer splits a MySQL dump into tokens skipping the whitespace and the comments
+type sqlLexer struct {
+	r *bufio.Reader
+}
+
+func (l *sqlLexer) skipUntil(end string) error {
+	matched := 0
+	for matched < len(end) {
+		c, err := l.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch {
+		case c == end[matched]:
+			matched++
+		case c == end[0]:
+			matched = 1
+		default:
+			matched = 0
+		}
+	}
+
+	return nil
+}
+
+func (l *sqlLexer) readQuoted(quote byte) (string, error) {
+	var b strings.Builder
+	for {
+		c, err := l.r.ReadByte()
+		if err == io.EOF {
+			return "", io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch c {
+		case quote:
+			// a doubled quote is an escaped quote
+			next, err := l.r.Peek(1)
+			if err == nil && next[0] == quote {
+				_, _ = l.r.ReadByte()
+				b.WriteByte(quote)
+				continue
+			}
+			return b.String(), nil
+		case '\\':
+			if quote == '`' {
+				b.WriteByte(c)
+				continue
+			}
+			c, err = l.r.ReadByte()
+			if err != nil {
+				return "", io.ErrUnexpectedEOF
+			}
+			switch c {
+			case '0':
+				b.WriteByte(0)
+			case 'b':
+				b.WriteByte('\b')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'Z':
+				b.WriteByte(26)
+			case '%', '_':
+				// these are only escaped in LIKE patterns
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			default:
+				b.WriteByte(c)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+func isSQLWordChar(c byte) bool {
+	return c == '_' || c == '.' || c == '-' || c == '+' || c == '$' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
+}
+
+// next returns the next token or io.EOF
+func (l *sqlLexer) next() (sqlToken, error) {
+	for {
+		c, err := l.r.ReadByte()
+		if err != nil {
+			return sqlToken{}, err
+		}
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			continue
+		case c == '#':
+			if err = l.skipUntil("\n"); err != nil {
+				return sqlToken{}, err
+			}
+			continue
+		case c == '-':
+			if next, err := l.r.Peek(1); err == nil && next[0] == '-' {
+				if err = l.skipUntil("\n"); err != nil {
+					return sqlToken{}, err
+				}
+				continue
+			}
+		case c == '/':
+			if next, err := l.r.Peek(1); err == nil && next[0] == '*' {
+				// the conditional comments (/*!40101 ... */) are skipped as well
+				if err = l.skipUntil("*/"); err != nil {
+					return sqlToken{}, err
+				}
+				continue
+			}
+		case c == '\'' || c == '"':
+			value, err := l.readQuoted(c)
+			return sqlToken{kind: sqlString, value: value}, err
+		case c == '`':
+			value, err := l.readQuoted(c)
+			return sqlToken{kind: sqlIdentifier, value: value}, err
+		}
+
+		if !isSQLWordChar(c) {
+			return sqlToken{kind: sqlPunct, value: string(c)}, nil
+		}
+		word := []byte{c}
+		for {
+			next, err := l.r.Peek(1)
+			if err != nil || !isSQLWordChar(next[0]) {
+				break
+			}
+			_, _ = l.r.ReadByte()
+			word = append(word, next[0])
+		}
+
+		return sqlToken{kind: sqlWord, value: string(word)}, nil
+	}
+}
+
+// YOURLSSQLReader reads links from the INSERT statements of the url table (yourls_url
+// by default, the table prefix doesn't matter) in a YOURLS SQL dump, the other statements are skipped
+type YOURLSSQLReader struct {
+	lexer   sqlLexer
+	columns []string
+	// inValues is true while the values of an INSERT statement are being read
+	inValues bool
+}
+
+// NewYOURLSSQLReader creates a new YOURLSSQLReader
+func NewYOURLSSQLReader(r io.Reader) *YOURLSSQLReader {
+	return &YOURLSSQLReader{
+		lexer: sqlLexer{r: bufio.NewReader(r)},
+	}
+}
+
+// expect reads the next token and makes sure it's the expected one
+func (y *YOURLSSQLReader) expect(kind sqlTokenKind, value string) error {
+	token, err := y.lexer.next()
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	if !token.is(kind, value) {
+		return errors.Errorf("unexpected %s, expected %s", token.value, value)
+	}
+
+	return nil
+}
+
+func (y *YOURLSSQLReader) skipStatement() error {
+	for {
+		token, err := y.lexer.next()
+		if err != nil {
+			return err
+		}
+		if token.is(sqlPunct, ";") {
+			return nil
+		}
+	}
+}
+
+// readInsertHeader reads the INSERT statement up to the values, it returns false if the statement
+// is not for the url table
+func (y *YOURLSSQLReader) readInsertHeader() (bool, error) {
+	token, err := y.lexer.next()
+	if err != nil {
+		return false, err
+	}
+	// INSERT [IGNORE] INTO
+	if token.is(sqlWord, "IGNORE") {
+		if token, err = y.lexer.next(); err != nil {
+			return false, err
+		}
+	}
+	if !token.is(sqlWord, "INTO") {
+		return false, nil
+	}
+
+	table, err := y.lexer.next()
+	if err != nil {
+		return false, err
+	}
+	if table.kind != sqlWord && table.kind != sqlIdentifier {
+		return false, nil
+	}
+	// the table can be prefixed with the database name
+	name := table.value[strings.LastIndex(table.value, ".")+1:]
+	if !strings.HasSuffix(strings.ToLower(name), "url") {
+		return false, nil
+	}
+
+	y.columns = yourlsColumns
+	token, err = y.lexer.next()
+	if err != nil {
+		return false, err
+	}
+	if token.is(sqlPunct, "(") {
+		y.columns = nil
+		for {
+			if token, err = y.lexer.next(); err != nil {
+				return false, err
+			}
+			if token.is(sqlPunct, ")") {
+				break
+			}
+			if token.is(sqlPunct, ",") {
+				continue
+			}
+			y.columns = append(y.columns, strings.ToLower(token.value))
+		}
+		if token, err = y.lexer.next(); err != nil {
+			return false, err
+		}
+	}
+	if !token.is(sqlWord, "VALUES") && !token.is(sqlWord, "VALUE") {
+		return false, errors.Errorf("unexpected %s, expected VALUES", token.value)
+	}
+
+	return true, nil
+}
+
+// readRow reads a single parenthesized row of values
+func (y *YOURLSSQLReader) readRow() (map[string]string, error) {
+	if err := y.expect(sqlPunct, "("); err != nil {
+		return nil, err
+	}
+
+	row := make(map[string]string, len(y.columns))
+	for i := 0; ; i++ {
+		token, err := y.lexer.next()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		if token.kind == sqlPunct {
+			return nil, errors.Errorf("unexpected %s, expected a value", token.value)
+		}
+		if i < len(y.columns) && !token.is(sqlWord, "NULL") {
+			row[y.columns[i]] = token.value
+		}
+
+		if token, err = y.lexer.next(); err != nil {
+			return nil, err
+		}
+		if token.is(sqlPunct, ")") {
+			return row, nil
+		}
+		if !token.is(sqlPunct, ",") {
+			return nil, errors.Errorf("unexpected %s, expected , or )", token.value)
+		}
+	}
+}
+
+// Read implements Reader
+func (y *YOURLSSQLReader) Read() (*model.Link, error) {
+	for !y.inValues {
+		token, err := y.lexer.next()
+		if err != nil {
+			return nil, err
+		}
+		if token.is(sqlPunct, ";") {
+			continue
+		}
+		if token.is(sqlWord, "INSERT") || token.is(sqlWord, "REPLACE") {
+			if y.inValues, err = y.readInsertHeader(); err != nil {
+				return nil, err
+			}
+			if y.inValues {
+				break
+			}
+		}
+		if err = y.skipStatement(); err != nil {
+			return nil, err
+		}
+	}
+
+	row, err := y.readRow()
+	if err != nil {
+		return nil, errors.Wrapf(err, "bad INSERT statement")
+	}
+
+	// the rows are separated by commas, and the statement ends with a semicolon
+	token, err := y.lexer.next()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if err == io.EOF || token.is(sqlPunct, ";") {
+		y.inValues = false
+	} else if !token.is(sqlPunct, ",") {
+		return nil, errors.Errorf("bad INSERT statement: unexpected %s, expected , or ;", token.value)
+	}
+
+	link, err := yourlsLink(row["keyword"], row["url"], row["title"], row["timestamp"])
+	if err != nil {
+		return nil, errors.Wrapf(err, "keyword %s", row["keyword"])
+	}
+
+	return link, nil
+}
@@ -1,9 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"github.com/denisvmedia/urlshortener/cmd"
 	_ "github.com/denisvmedia/urlshortener/docs"
-	"github.com/jessevdk/go-flags"
 	"os"
 )
 
@@ -21,9 +21,11 @@ import (
 // @BasePath /api
 
 func main() {
-	parser := flags.NewParser(nil, flags.Default)
-	cmd.RegisterInitStorageCommand(parser)
-	cmd.RegisterRunCommand(parser)
+	parser := cmd.NewParser(&cmd.Options{})
+	if _, err := cmd.LoadConfig(parser, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
 
 	//parser.CommandHandler = func(command flags.Commander, args []string) error {
 	//	err := command.Execute(args)
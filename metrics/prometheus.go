@@ -16,9 +16,79 @@ var (
 		},
 		[]string{"code"},
 	)
+
+	// RequestsThrottled defines a Prometheus counter for a total of requests rejected by the rate limits
+	// (by policy and by the key the limit was exceeded for)
+	RequestsThrottled = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_throttled_total",
+			Help:      "Number of total requests rejected by the rate limits by policy (api_writes or redirects) and key (ip or api_key).",
+		},
+		[]string{"policy", "key"},
+	)
+
+	// ShortNamesGenerated defines a Prometheus counter for a total of short names generated for the links
+	ShortNamesGenerated = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "short_names_generated_total",
+			Help:      "Number of total short names generated for the links created without one.",
+		},
+	)
+
+	// ShortNameCollisions defines a Prometheus counter for a total of generated short names that couldn't be used
+	// (by reason), its ratio to ShortNamesGenerated is the collision rate
+	ShortNameCollisions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "short_name_collisions_total",
+			Help:      "Number of total generated short names that couldn't be used by reason (used or reserved).",
+		},
+		[]string{"reason"},
+	)
+
+	// BlocklistScans defines a Prometheus counter for a total of blocklist scans of the links (by result)
+	BlocklistScans = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "blocklist_scans_total",
+			Help:      "Number of total blocklist scans of the links by result (success or failure).",
+		},
+		[]string{"result"},
+	)
+
+	// BlocklistFlaggedLinks defines a Prometheus gauge for the number of the links flagged by the last blocklist scan
+	BlocklistFlaggedLinks = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "blocklist_flagged_links",
+			Help:      "Number of the links which destinations were found in the blocklist by the last scan.",
+		},
+	)
+
+	// ConfigReloads defines a Prometheus counter for a total of configuration reloads (by result)
+	ConfigReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "config_reloads_total",
+			Help:      "Number of total configuration reloads by result (success or failure).",
+		},
+		[]string{"result"},
+	)
+
+	// ConfigLastReloadSuccessful defines a Prometheus gauge that tells whether the last configuration reload succeeded
+	ConfigLastReloadSuccessful = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "config_last_reload_successful",
+			Help:      "Whether the last configuration reload succeeded (1) or failed (0).",
+		},
+	)
 )
 
 // RegisterAll registers all the app's Prometheus metrics
 func RegisterAll() {
-	prometheus.MustRegister(RequestProcessed)
+	prometheus.MustRegister(RequestProcessed, RequestsThrottled, ShortNamesGenerated, ShortNameCollisions,
+		BlocklistScans, BlocklistFlaggedLinks, ConfigReloads, ConfigLastReloadSuccessful)
 }
@@ -0,0 +1,47 @@
+package model
+
+import "github.com/go-extras/api2go/jsonapi"
+
+// LinkRelationship is the name of the alias relationship to its link
+const LinkRelationship = "link"
+
+// Alias defines an additional short name of a link, the alias is identified by the short name
+type Alias struct {
+	ID string `json:"-" swaggerignore:"true"`
+	// ID of the link the alias leads to
+	LinkID string `json:"-" swaggerignore:"true"`
+}
+
+// GetID to satisfy jsonapi.MarshalIdentifier interface
+func (a Alias) GetID() string {
+	return a.ID
+}
+
+// SetID to satisfy jsonapi.UnmarshalIdentifier interface
+func (a *Alias) SetID(id string) error {
+	a.ID = id
+	return nil
+}
+
+// GetReferences to satisfy jsonapi.MarshalReferences interface
+func (a Alias) GetReferences() []jsonapi.Reference {
+	return []jsonapi.Reference{
+		{
+			Type:         "links",
+			Name:         LinkRelationship,
+			Relationship: jsonapi.ToOneRelationship,
+		},
+	}
+}
+
+// GetReferencedIDs to satisfy jsonapi.MarshalLinkedRelations interface
+func (a Alias) GetReferencedIDs() []jsonapi.ReferenceID {
+	return []jsonapi.ReferenceID{
+		{
+			ID:           a.LinkID,
+			Type:         "links",
+			Name:         LinkRelationship,
+			Relationship: jsonapi.ToOneRelationship,
+		},
+	}
+}
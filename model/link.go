@@ -1,14 +1,43 @@
 package model
 
+import (
+	"time"
+
+	"github.com/denisvmedia/urlshortener/validator"
+	"github.com/go-extras/api2go/jsonapi"
+	"github.com/go-extras/errors"
+)
+
+// Link relationship names
+const (
+	// TagsRelationship is the name of the link relationship to its tags
+	TagsRelationship = "tags"
+	// AliasesRelationship is the name of the link relationship to its aliases
+	AliasesRelationship = "aliases"
+)
+
 // Link defines a link structure that is used for redirects
 type Link struct {
 	ID string `json:"-" swaggerignore:"true"`
 	// Link short name as user requires, if empty will be generated, must be unique
 	ShortName string `json:"shortName" example:"link-short-name" validate:"shortname"`
 	// Original URL where to redirect the visitor
-	OriginalURL string `json:"originalUrl" example:"https://example.com/my-cool-url-path" validate:"required,url,urlscheme"`
+	OriginalURL string `json:"originalUrl" example:"https://example.com/my-cool-url-path" validate:"required,url,urlscheme,idnhost,urldomain,notblocked,publichost"`
+	// Canonical form of the original url used to find the duplicates, set by the storage
+	CanonicalURL string `json:"-" swaggerignore:"true"`
 	// User comment
 	Comment string `json:"comment" example:"Free text comment"`
+	// IDs of the tags attached to the link
+	TagIDs []string `json:"-" swaggerignore:"true"`
+	// Tags attached to the link, only loaded when they have to be included into the response
+	Tags []*Tag `json:"-" swaggerignore:"true"`
+	// Short names leading to the link in addition to ShortName, unique among all the short names and aliases
+	Aliases []string `json:"-" swaggerignore:"true" relationship:"aliases" validate:"dive,shortname"`
+	// Creation time, set by the storage unless given (e.g. by an importer)
+	CreatedAt time.Time `json:"-" swaggerignore:"true"`
+	// Version of the link, set by the storage and incremented on every update; when not zero on update
+	// it must match the stored one
+	Version int `json:"-" swaggerignore:"true"`
 }
 
 // GetID to satisfy jsonapi.MarshalIdentifier interface
@@ -22,10 +51,112 @@ func (c *Link) SetID(id string) error {
 	return nil
 }
 
-// FillDefaults sets defaults values for those that fields are not set
-// Currently sets only Link.ShortName (builds a pseudo-random string up to 8 chars len)
-func (c *Link) FillDefaults() {
-	if c.ShortName == "" {
-		c.ShortName = generateShortName()
+// GetReferences to satisfy jsonapi.MarshalReferences interface
+func (c Link) GetReferences() []jsonapi.Reference {
+	return []jsonapi.Reference{
+		{
+			Type:         TagsRelationship,
+			Name:         TagsRelationship,
+			Relationship: jsonapi.ToManyRelationship,
+		},
+		{
+			Type:         AliasesRelationship,
+			Name:         AliasesRelationship,
+			Relationship: jsonapi.ToManyRelationship,
+		},
+	}
+}
+
+// GetReferencedIDs to satisfy jsonapi.MarshalLinkedRelations interface
+func (c Link) GetReferencedIDs() []jsonapi.ReferenceID {
+	result := make([]jsonapi.ReferenceID, 0, len(c.TagIDs)+len(c.Aliases))
+	for _, id := range c.TagIDs {
+		result = append(result, jsonapi.ReferenceID{
+			ID:           id,
+			Type:         TagsRelationship,
+			Name:         TagsRelationship,
+			Relationship: jsonapi.ToManyRelationship,
+		})
+	}
+	for _, alias := range c.Aliases {
+		result = append(result, jsonapi.ReferenceID{
+			ID:           alias,
+			Type:         AliasesRelationship,
+			Name:         AliasesRelationship,
+			Relationship: jsonapi.ToManyRelationship,
+		})
+	}
+
+	return result
+}
+
+// GetReferencedStructs to satisfy jsonapi.MarshalIncludedRelations interface
+func (c Link) GetReferencedStructs() []jsonapi.MarshalIdentifier {
+	result := make([]jsonapi.MarshalIdentifier, 0, len(c.Tags))
+	for _, tag := range c.Tags {
+		result = append(result, tag)
+	}
+
+	return result
+}
+
+// toManyIDs returns the ids of the to-many relationship with the given name
+func (c *Link) toManyIDs(name string) (*[]string, error) {
+	switch name {
+	case TagsRelationship:
+		return &c.TagIDs, nil
+	case AliasesRelationship:
+		return &c.Aliases, nil
+	default:
+		return nil, errors.Errorf("there is no to-many relationship with the name %s", name)
+	}
+}
+
+// SetToManyReferenceIDs to satisfy jsonapi.UnmarshalToManyRelations interface
+func (c *Link) SetToManyReferenceIDs(name string, IDs []string) error {
+	ids, err := c.toManyIDs(name)
+	if err != nil {
+		return err
+	}
+
+	*ids = uniqueStrings(IDs)
+	return nil
+}
+
+// AddToManyIDs to satisfy jsonapi.EditToManyRelations interface
+func (c *Link) AddToManyIDs(name string, IDs []string) error {
+	ids, err := c.toManyIDs(name)
+	if err != nil {
+		return err
 	}
+
+	*ids = uniqueStrings(append(*ids, IDs...))
+	return nil
+}
+
+// DeleteToManyIDs to satisfy jsonapi.EditToManyRelations interface
+func (c *Link) DeleteToManyIDs(name string, IDs []string) error {
+	ids, err := c.toManyIDs(name)
+	if err != nil {
+		return err
+	}
+
+	// the aliases are stored normalized, so they may be given in another form
+	normalize := func(id string) string { return id }
+	if name == AliasesRelationship {
+		normalize = validator.NormalizeShortName
+	}
+	obsolete := make(map[string]bool, len(IDs))
+	for _, id := range IDs {
+		obsolete[normalize(id)] = true
+	}
+	remaining := make([]string, 0, len(*ids))
+	for _, id := range *ids {
+		if !obsolete[normalize(id)] {
+			remaining = append(remaining, id)
+		}
+	}
+	*ids = remaining
+
+	return nil
 }
@@ -0,0 +1,19 @@
+package model
+
+// Tag defines a tag that can be attached to links to group them
+type Tag struct {
+	ID string `json:"-" swaggerignore:"true"`
+	// Tag name, must be unique
+	Name string `json:"name" example:"marketing" validate:"required,max=64"`
+}
+
+// GetID to satisfy jsonapi.MarshalIdentifier interface
+func (t Tag) GetID() string {
+	return t.ID
+}
+
+// SetID to satisfy jsonapi.UnmarshalIdentifier interface
+func (t *Tag) SetID(id string) error {
+	t.ID = id
+	return nil
+}
@@ -1,23 +1,50 @@
 package model
 
 import (
-	"encoding/base64"
-	"github.com/google/uuid"
-	"strings"
+	"sync/atomic"
+
+	"github.com/denisvmedia/urlshortener/shortname"
 )
 
-func generateShortName() string {
-	hash, _ := uuid.New().MarshalBinary()
-	var b strings.Builder
-	encoder := base64.NewEncoder(base64.URLEncoding, &b)
-	_, _ = encoder.Write(hash)
-	_ = encoder.Close()
-
-	// youtube-like name
-	res := strings.ReplaceAll(strings.Trim(b.String(), "_-=\n"), "_", "")
-	if len(res) > 8 {
-		res = res[:8]
+// ShortNameGenerator generates the short names of the links created without one (see shortname package)
+type ShortNameGenerator interface {
+	Generate() (string, error)
+}
+
+// shortNameGenerator holds the current ShortNameGenerator
+var shortNameGenerator atomic.Value
+
+// defaultShortNameLength is the length of the short names generated by default
+const defaultShortNameLength = 8
+
+func init() {
+	generator, err := shortname.NewRandom(shortname.Base62, defaultShortNameLength)
+	if err != nil {
+		panic(err) // this should never happen
+	}
+	SetShortNameGenerator(generator)
+}
+
+// SetShortNameGenerator replaces the generator of the short names (see linkstorage.Allocator)
+func SetShortNameGenerator(generator ShortNameGenerator) {
+	shortNameGenerator.Store(&generator)
+}
+
+// CurrentShortNameGenerator returns the generator of the short names
+func CurrentShortNameGenerator() ShortNameGenerator {
+	return *shortNameGenerator.Load().(*ShortNameGenerator)
+}
+
+// uniqueStrings returns the given values without duplicates keeping their order
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
 	}
 
-	return res
+	return result
 }
@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sweepInterval defines how often the idle buckets are removed
+const sweepInterval = time.Minute
+
+// Policy defines a token bucket: Rate tokens per second are added to the bucket up to Burst tokens,
+// and each request takes one. A zero rate disables the limit.
+type Policy struct {
+	Rate  float64
+	Burst int
+}
+
+// Enabled tells whether the policy limits anything
+func (p Policy) Enabled() bool {
+	return p.Rate > 0
+}
+
+// capacity returns the bucket size, at least one token must fit in
+func (p Policy) capacity() float64 {
+	return math.Max(1, float64(p.Burst))
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Limiter limits the requests by keys (e.g. client ip addresses), each key gets its own bucket
+type Limiter struct {
+	// policy holds the current Policy, it can be replaced while the requests are limited
+	policy    atomic.Value
+	lock      sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewLimiter creates a limiter with the given policy
+func NewLimiter(policy Policy) *Limiter {
+	l := &Limiter{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+	l.policy.Store(policy)
+
+	return l
+}
+
+// Policy returns the current policy
+func (l *Limiter) Policy() Policy {
+	return l.policy.Load().(Policy)
+}
+
+// SetPolicy replaces the policy, the tokens left in the buckets are kept (up to the new burst)
+func (l *Limiter) SetPolicy(policy Policy) {
+	l.policy.Store(policy)
+}
+
+// refill adds the tokens accumulated since the last update, the lock must be held
+func (b *bucket) refill(policy Policy, now time.Time) {
+	b.tokens = math.Min(policy.capacity(), b.tokens+now.Sub(b.updated).Seconds()*policy.Rate)
+	b.updated = now
+}
+
+// Allow takes a token from the bucket of the key, if there is none it returns false
+// and the time after which the next token is available
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	allowed, _, wait := l.AllowAll(key)
+
+	return allowed, wait
+}
+
+// AllowAll takes a token from the bucket of each key if all of them have one, otherwise nothing is taken
+// and it returns false, the index of the first key without a token and the time after which its next token is available
+func (l *Limiter) AllowAll(keys ...string) (allowed bool, denied int, wait time.Duration) {
+	policy := l.Policy()
+	if !policy.Enabled() {
+		return true, -1, 0
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	l.sweep(policy, now)
+	buckets := make([]*bucket, len(keys))
+	for i, key := range keys {
+		b, ok := l.buckets[key]
+		if !ok {
+			b = &bucket{tokens: policy.capacity(), updated: now}
+			l.buckets[key] = b
+		}
+		b.refill(policy, now)
+		if b.tokens < 1 {
+			return false, i, time.Duration((1 - b.tokens) / policy.Rate * float64(time.Second))
+		}
+		buckets[i] = b
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+
+	return true, -1, 0
+}
+
+// sweep removes the buckets that have been refilled completely, as they are the same as the new ones,
+// the lock must be held
+func (l *Limiter) sweep(policy Policy, now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		b.refill(policy, now)
+		if b.tokens >= policy.capacity() {
+			delete(l.buckets, key)
+		}
+	}
+}
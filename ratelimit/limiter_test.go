@@ -0,0 +1,77 @@
+package ratelimit_test
+
+import (
+	"time"
+
+	"github.com/denisvmedia/urlshortener/ratelimit"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Limiter", func() {
+	It("allows the burst and then limits the rate by keys", func() {
+		limiter := ratelimit.NewLimiter(ratelimit.Policy{Rate: 1, Burst: 2})
+		for i := 0; i < 2; i++ {
+			allowed, _ := limiter.Allow("a")
+			Expect(allowed).To(BeTrue())
+		}
+		allowed, wait := limiter.Allow("a")
+		Expect(allowed).To(BeFalse())
+		Expect(wait).To(BeNumerically(">", 900*time.Millisecond))
+		Expect(wait).To(BeNumerically("<=", time.Second))
+
+		// the other keys have their own buckets
+		allowed, _ = limiter.Allow("b")
+		Expect(allowed).To(BeTrue())
+	})
+
+	It("takes the tokens of all the keys or none", func() {
+		limiter := ratelimit.NewLimiter(ratelimit.Policy{Rate: 1, Burst: 2})
+		allowed, denied, _ := limiter.AllowAll("ip", "key")
+		Expect(allowed).To(BeTrue())
+		Expect(denied).To(Equal(-1))
+		allowed, _ = limiter.Allow("key")
+		Expect(allowed).To(BeTrue())
+
+		allowed, denied, wait := limiter.AllowAll("ip", "key")
+		Expect(allowed).To(BeFalse())
+		Expect(denied).To(Equal(1))
+		Expect(wait).To(BeNumerically(">", 0))
+
+		// the token of the first key is left
+		allowed, _ = limiter.Allow("ip")
+		Expect(allowed).To(BeTrue())
+		allowed, denied, _ = limiter.AllowAll("ip", "other")
+		Expect(allowed).To(BeFalse())
+		Expect(denied).To(Equal(0))
+		allowed, _ = limiter.Allow("other")
+		Expect(allowed).To(BeTrue())
+	})
+
+	It("refills the buckets over time", func() {
+		limiter := ratelimit.NewLimiter(ratelimit.Policy{Rate: 100, Burst: 1})
+		allowed, _ := limiter.Allow("a")
+		Expect(allowed).To(BeTrue())
+		allowed, wait := limiter.Allow("a")
+		Expect(allowed).To(BeFalse())
+
+		time.Sleep(wait)
+		allowed, _ = limiter.Allow("a")
+		Expect(allowed).To(BeTrue())
+	})
+
+	It("applies the replaced policy", func() {
+		limiter := ratelimit.NewLimiter(ratelimit.Policy{})
+		for i := 0; i < 100; i++ {
+			allowed, _ := limiter.Allow("a")
+			Expect(allowed).To(BeTrue(), "a zero rate must disable the limit")
+		}
+
+		limiter.SetPolicy(ratelimit.Policy{Rate: 0.001, Burst: 0})
+		Expect(limiter.Policy().Enabled()).To(BeTrue())
+		allowed, _ := limiter.Allow("a")
+		Expect(allowed).To(BeTrue(), "a single request must fit in the bucket")
+		allowed, _ = limiter.Allow("a")
+		Expect(allowed).To(BeFalse())
+	})
+})
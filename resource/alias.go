@@ -0,0 +1,104 @@
+package resource
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/storage"
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	myvalidator "github.com/denisvmedia/urlshortener/validator"
+	"github.com/go-extras/api2go"
+	"github.com/go-extras/errors"
+)
+
+// errAliasesByLink is returned when the aliases are listed without a link
+var errAliasesByLink = errors.New("the aliases are listed by link, see /links/{id}/aliases")
+
+// AliasResource for api2go routes, the aliases are read-only: they are managed as the relationship of the links
+type AliasResource struct {
+	LinkStorage linkstorage.Storage
+}
+
+// NewAliasResource creates a new AliasResource instance for given link storage
+func NewAliasResource(linkStorage linkstorage.Storage) *AliasResource {
+	return &AliasResource{
+		LinkStorage: linkStorage,
+	}
+}
+
+// FindAll aliases of a link
+// @Summary List the aliases of a link
+// @Description get the aliases of a link, they are changed with the aliases relationship of the link
+// @Tags links
+// @Accept  json-api
+// @Produce  json-api
+// @Param id path string true "Link ID"
+// @Success 200 {object} jsonapi.Aliases
+// @Router /links/{id}/aliases [get]
+func (c *AliasResource) FindAll(r api2go.Request) (api2go.Responder, error) {
+	// api2go calls FindAll with the link id for the related resource route (/links/{id}/aliases)
+	linkIDs := r.QueryParams["linksID"]
+	if len(linkIDs) == 0 {
+		return nil, HTTPErrorPtr(errAliasesByLink, errAliasesByLink.Error(), http.StatusBadRequest)
+	}
+
+	link, err := c.LinkStorage.GetOne(linkIDs[0])
+	if err != nil {
+		return nil, HTTPErrorPtrWithStatus(err, resourceNotFound)
+	}
+
+	aliases := make([]model.Alias, 0, len(link.Aliases))
+	for _, alias := range link.Aliases {
+		aliases = append(aliases, model.Alias{ID: alias, LinkID: link.ID})
+	}
+
+	return &api2go.Response{
+		Res:  aliases,
+		Code: http.StatusOK,
+		Meta: map[string]interface{}{
+			"aliases": len(aliases),
+		},
+	}, nil
+}
+
+// FindOne alias
+// @Summary Get an alias
+// @Description get alias by its short name
+// @Tags links
+// @Accept  json-api
+// @Produce  json-api
+// @Param id path string true "Alias short name"
+// @Success 200 {object} jsonapi.Alias
+// @Router /aliases/{id} [get]
+func (c *AliasResource) FindOne(ID string, _ api2go.Request) (api2go.Responder, error) {
+	shortName, link, err := findAliasLink(c.LinkStorage, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{Res: model.Alias{ID: shortName, LinkID: link.ID}}, nil
+}
+
+// findAliasLink returns the normalized alias short name and the link it leads to
+func findAliasLink(linkStorage linkstorage.Storage, ID string) (string, *model.Link, error) {
+	// the router doesn't decode the percent-encoded unicode names
+	if unescaped, err := url.PathUnescape(ID); err == nil {
+		ID = unescaped
+	}
+	shortName := myvalidator.NormalizeShortName(ID)
+
+	link, err := linkStorage.GetOneByShortName(shortName)
+	if err == nil && link.ShortName == shortName {
+		// the short name of the link is not an alias
+		err = errors.Wrapf(storage.ErrNotFound, "%s is not an alias", shortName)
+	}
+	if err != nil {
+		if errors.Cause(err) == storage.ErrNotFound {
+			return "", nil, HTTPErrorPtrWithStatus(err, resourceNotFound)
+		}
+		return "", nil, HTTPErrorPtrWithStatus(err, internalServerError)
+	}
+
+	return shortName, link, nil
+}
@@ -7,15 +7,67 @@ import (
 	"github.com/go-extras/errors"
 	"github.com/go-playground/validator/v10"
 	"net/http"
+	"regexp"
+	"strings"
 )
 
 const resourceNotFound = "resource not found"
 const validationError = "validation error"
 const internalServerError = "internal server error"
+const preconditionFailed = "precondition failed"
+
+const fieldValidationFailed = "Field validation failed"
+
+// validationCodes are the stable machine-readable codes of the failed validations by their tags
+var validationCodes = map[string]string{
+	"required":   "required",
+	"url":        "invalid_url",
+	"urlscheme":  "unsupported_url_scheme",
+	"idnhost":    "invalid_host",
+	"urldomain":  "domain_not_allowed",
+	"notblocked": "blocked_url",
+	"publichost": "private_destination",
+	"shortname":  "invalid_short_name",
+	"max":        "too_long",
+}
+
+// validationDetails are the human-readable messages of the failed validations,
+// %[1]s is the attribute name and %[2]s is the tag parameter
+var validationDetails = map[string]string{
+	"required":   "%[1]s is required",
+	"url":        "%[1]s must be an absolute url",
+	"urlscheme":  "%[1]s must be an http or https url",
+	"idnhost":    "%[1]s has an invalid internationalized host name",
+	"urldomain":  "%[1]s: the destination domain is not allowed",
+	"notblocked": "%[1]s: the destination is in the malicious url blocklist",
+	"publichost": "%[1]s: the destination is not a public address or can't be resolved",
+	"shortname":  "%[1]s must only contain letters (of the allowed scripts, not mixed), digits and dashes, and must not be (or look like) a reserved name",
+	"max":        "%[1]s must be at most %[2]s characters long",
+}
+
+// fieldError describes a storage error caused by a link attribute, it's reported as a failed validation
+type fieldError struct {
+	id        string
+	code      string
+	attribute string
+}
+
+// fieldErrors are the storage errors caused by the link attributes
+var fieldErrors = map[error]fieldError{
+	storage.ErrShortNameConfusable:  {id: "Link.ShortName:confusable", code: "confusable_short_name", attribute: "shortName"},
+	storage.ErrRedirectLoop:         {id: "Link.OriginalURL:redirectloop", code: "redirect_loop", attribute: "originalUrl"},
+	storage.ErrRedirectChainTooLong: {id: "Link.OriginalURL:redirectchain", code: "redirect_chain_too_long", attribute: "originalUrl"},
+}
 
 var errorCodes = map[interface{}]int{
 	storage.ErrNotFound:               http.StatusNotFound,
 	storage.ErrShortNameAlreadyExists: http.StatusBadRequest,
+	storage.ErrTagNameAlreadyExists:   http.StatusBadRequest,
+	storage.ErrShortNameConfusable:    http.StatusBadRequest,
+	storage.ErrRedirectLoop:           http.StatusBadRequest,
+	storage.ErrRedirectChainTooLong:   http.StatusBadRequest,
+	storage.ErrVersionMismatch:        http.StatusPreconditionFailed,
+	errInvalidOperation:               http.StatusBadRequest,
 }
 
 // StatusByError gives a http error for a particular go error
@@ -42,13 +94,16 @@ func HTTPErrorPtr(err error, msg string, status int) *api2go.HTTPError {
 	tmp := api2go.NewHTTPError(err, msg, status)
 	if errs, ok := err.(validator.ValidationErrors); ok {
 		for _, e := range errs {
-			tmp.Errors = append(tmp.Errors, api2go.Error{
-				ID:     fmt.Sprintf("%s:%s", e.Namespace(), e.ActualTag()),
-				Title:  "Field validation failed",
-				Detail: fmt.Sprintf("Field validation for '%s' failed on the '%s' tag", e.Field(), e.ActualTag()),
-				// Source: nil, // TODO: convert to "/data/attributes/*"
-			})
+			tmp.Errors = append(tmp.Errors, validationErrorEntry(e))
 		}
+	} else if fe, ok := fieldErrors[errors.Cause(err)]; ok {
+		tmp.Errors = append(tmp.Errors, api2go.Error{
+			ID:     fe.id,
+			Code:   fe.code,
+			Title:  fieldValidationFailed,
+			Detail: err.Error(),
+			Source: attributeSource(fe.attribute),
+		})
 	}
 	return &tmp
 }
@@ -58,3 +113,44 @@ func HTTPErrorPtr(err error, msg string, status int) *api2go.HTTPError {
 func HTTPErrorPtrWithStatus(err error, msg string) *api2go.HTTPError {
 	return HTTPErrorPtr(err, msg, StatusByError(err))
 }
+
+// validationErrorEntry converts a failed validation to an error entry, the field names are the json ones
+// (see myvalidator.New), the id keeps the go names so that it doesn't change
+func validationErrorEntry(e validator.FieldError) api2go.Error {
+	code, ok := validationCodes[e.ActualTag()]
+	if !ok {
+		code = "invalid"
+	}
+	detail := fmt.Sprintf("%s is invalid (%s)", e.Field(), e.ActualTag())
+	if format, ok := validationDetails[e.ActualTag()]; ok {
+		detail = fmt.Sprintf(format, e.Field(), e.Param())
+	}
+
+	return api2go.Error{
+		ID:     fmt.Sprintf("%s:%s", e.StructNamespace(), e.ActualTag()),
+		Code:   code,
+		Title:  fieldValidationFailed,
+		Detail: detail,
+		Source: fieldSource(e.Field()),
+	}
+}
+
+// relationshipElement matches the field names of the relationship elements, e.g. aliases[0]
+var relationshipElement = regexp.MustCompile(`^(.+)\[(\d+)\]$`)
+
+// fieldSource points to the attribute or to the relationship element (see myvalidator.New) of the request document
+func fieldSource(field string) *api2go.ErrorSource {
+	if m := relationshipElement.FindStringSubmatch(field); m != nil {
+		return &api2go.ErrorSource{Pointer: "/data/relationships/" + jsonPointerReplacer.Replace(m[1]) + "/data/" + m[2] + "/id"}
+	}
+
+	return attributeSource(field)
+}
+
+// attributeSource points to the attribute of the request document
+func attributeSource(attribute string) *api2go.ErrorSource {
+	return &api2go.ErrorSource{Pointer: "/data/attributes/" + jsonPointerReplacer.Replace(attribute)}
+}
+
+// jsonPointerReplacer escapes a JSON pointer reference token (RFC 6901)
+var jsonPointerReplacer = strings.NewReplacer("~", "~0", "/", "~1")
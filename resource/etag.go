@@ -0,0 +1,82 @@
+package resource
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/routing"
+	"github.com/go-extras/api2go"
+)
+
+// linkETag returns the entity tag of the link, which changes with its version
+func linkETag(link model.Link) string {
+	return `"` + strconv.Itoa(link.Version) + `"`
+}
+
+// ifMatch tells whether the If-Match header of the request matches the entity tag, the requests without
+// the header always match. As RFC 7232 requires the weak entity tags never match.
+func ifMatch(r api2go.Request, etag string) bool {
+	tags := ifMatchTags(r)
+	if len(tags) == 0 {
+		return true
+	}
+	for _, tag := range tags {
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ifMatchAny tells whether the If-Match header of the request matches any version of an existing resource
+func ifMatchAny(r api2go.Request) bool {
+	for _, tag := range ifMatchTags(r) {
+		if tag == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// versioned tells whether the request must only be applied to the versions of the resource listed in If-Match,
+// i.e. the header is given and it doesn't match any version
+func versioned(r api2go.Request) bool {
+	return len(ifMatchTags(r)) > 0 && !ifMatchAny(r)
+}
+
+// ifMatchTags returns the entity tags listed in the If-Match headers of the request
+func ifMatchTags(r api2go.Request) []string {
+	var result []string
+	for _, value := range r.Header.Values("If-Match") {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				result = append(result, tag)
+			}
+		}
+	}
+
+	return result
+}
+
+// setETag sets the ETag header of the response to the link version, unless the version is unknown
+func setETag(r api2go.Request, link model.Link) {
+	if link.Version != 0 {
+		setResponseHeader(r, "ETag", linkETag(link))
+	}
+}
+
+// setResponseHeader sets a header of the response to the request, if the router supports that
+// (see routing.ResponseHeaderKey)
+func setResponseHeader(r api2go.Request, key, value string) {
+	if r.Context == nil {
+		return
+	}
+	v, _ := r.Context.Get(routing.ResponseHeaderKey)
+	if header, ok := v.(http.Header); ok {
+		header.Set(key, value)
+	}
+}
@@ -0,0 +1,39 @@
+package resource
+
+import (
+	"strconv"
+	"strings"
+)
+
+func parseFilterArg(params map[string][]string, name string) string {
+	v := params["filter["+name+"]"]
+	if len(v) == 0 {
+		return ""
+	}
+
+	return strings.TrimSpace(v[0])
+}
+
+func parseIncludes(params map[string][]string) map[string]bool {
+	result := make(map[string]bool)
+	for _, v := range params["include"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				result[name] = true
+			}
+		}
+	}
+
+	return result
+}
+
+// parseBoolArg returns the value of a boolean query parameter, false if it's missing or invalid
+func parseBoolArg(params map[string][]string, name string) bool {
+	v := params[name]
+	if len(v) == 0 {
+		return false
+	}
+	result, _ := strconv.ParseBool(strings.TrimSpace(v[0]))
+
+	return result
+}
@@ -3,6 +3,7 @@ package resource
 import (
 	"github.com/denisvmedia/urlshortener/storage"
 	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/denisvmedia/urlshortener/storage/tagstorage"
 	myvalidator "github.com/denisvmedia/urlshortener/validator"
 	"github.com/go-extras/errors"
 	"github.com/go-playground/validator/v10"
@@ -15,25 +16,19 @@ import (
 // LinkResource for api2go routes
 type LinkResource struct {
 	LinkStorage linkstorage.Storage
+	TagStorage  tagstorage.Storage
 	validator   *validator.Validate
+	allocator   *linkstorage.Allocator
 }
 
-// NewLinkResource creates a new LinkResource instance for a given link storage
-func NewLinkResource(linkStorage linkstorage.Storage) *LinkResource {
+// NewLinkResource creates a new LinkResource instance for given link and tag storages
+func NewLinkResource(linkStorage linkstorage.Storage, tagStorage tagstorage.Storage) *LinkResource {
 	// Validator is not injected as a dependency, because it's actually an integral part of LinkResource
-	validate := validator.New()
-	err := validate.RegisterValidation("shortname", myvalidator.ValidateURLShortName)
-	if err != nil {
-		panic(err) // this should never happen
-	}
-	err = validate.RegisterValidation("urlscheme", myvalidator.ValidateURLScheme)
-	if err != nil {
-		panic(err) // this should never happen
-	}
-
 	return &LinkResource{
 		LinkStorage: linkStorage,
-		validator:   validate,
+		TagStorage:  tagStorage,
+		validator:   myvalidator.New(),
+		allocator:   linkstorage.NewAllocator(linkStorage),
 	}
 }
 
@@ -45,16 +40,62 @@ func NewLinkResource(linkStorage linkstorage.Storage) *LinkResource {
 // @Produce  json-api
 // @Param page[number] query int false "Page number" default(1)
 // @Param page[size] query int false "Page size" default(10) maximum(1000)
+// @Param filter[q] query string false "Full-text search in the original urls and comments (results are ordered by relevance)"
+// @Param filter[tag] query string false "Tag name to filter the links by"
+// @Param include query string false "Related resources to include into the response" Enums(tags)
 // @Success 200 {object} jsonapi.Links
 // @Router /links [get]
 func (c *LinkResource) FindAll(r api2go.Request) (api2go.Responder, error) {
+	// api2go calls FindAll with the alias for the related resource route (/aliases/{id}/link)
+	if aliases := r.QueryParams["aliasesID"]; len(aliases) > 0 {
+		_, link, err := findAliasLink(c.LinkStorage, aliases[0])
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Res: *link}, nil
+	}
+
 	pagination := parsePageArgs(r.QueryParams)
 
-	links, total, err := c.LinkStorage.PaginatedGetAll(pagination.Number, pagination.Size)
+	filter := linkstorage.Filter{
+		Query: parseFilterArg(r.QueryParams, "q"),
+	}
+	if tagName := parseFilterArg(r.QueryParams, "tag"); tagName != "" {
+		tag, err := c.TagStorage.GetOneByName(tagName)
+		if err != nil && errors.Cause(err) != storage.ErrNotFound {
+			return nil, HTTPErrorPtrWithStatus(err, internalServerError)
+		}
+		if tag == nil {
+			// nothing can be tagged with a tag that doesn't exist
+			return &api2go.Response{
+				Res:        []*model.Link{},
+				Code:       http.StatusOK,
+				Meta:       map[string]interface{}{"links": 0},
+				Pagination: getPagination(pagination.Number, pagination.Size, 0),
+			}, nil
+		}
+		filter.TagID = tag.ID
+	}
+
+	var links []*model.Link
+	var total int
+	var err error
+	if filter == (linkstorage.Filter{}) {
+		links, total, err = c.LinkStorage.PaginatedGetAll(pagination.Number, pagination.Size)
+	} else {
+		links, total, err = c.LinkStorage.PaginatedFind(filter, pagination.Number, pagination.Size)
+	}
 	if err != nil {
 		return nil, HTTPErrorPtrWithStatus(err, internalServerError)
 	}
 
+	if parseIncludes(r.QueryParams)[model.TagsRelationship] {
+		links, err = c.includeTags(links)
+		if err != nil {
+			return nil, HTTPErrorPtrWithStatus(err, internalServerError)
+		}
+	}
+
 	result := &api2go.Response{
 		Res:  links,
 		Code: http.StatusOK,
@@ -67,6 +108,50 @@ func (c *LinkResource) FindAll(r api2go.Request) (api2go.Responder, error) {
 	return result, nil
 }
 
+// includeTags returns copies of the given links with their tags loaded
+// (the storages may return shared instances that must not be modified)
+func (c *LinkResource) includeTags(links []*model.Link) ([]*model.Link, error) {
+	result := make([]*model.Link, 0, len(links))
+	for _, link := range links {
+		tags, err := c.TagStorage.GetByIDs(link.TagIDs)
+		if err != nil {
+			return nil, err
+		}
+		withTags := *link
+		withTags.Tags = tags
+		result = append(result, &withTags)
+	}
+
+	return result, nil
+}
+
+// validateTags makes sure that all the tags attached to the link exist
+func (c *LinkResource) validateTags(link model.Link) error {
+	_, err := c.TagStorage.GetByIDs(link.TagIDs)
+	if err != nil && errors.Cause(err) == storage.ErrNotFound {
+		return HTTPErrorPtr(err, errors.Cause(err).Error(), http.StatusBadRequest)
+	}
+	if err != nil {
+		return HTTPErrorPtrWithStatus(err, internalServerError)
+	}
+
+	return nil
+}
+
+// findDuplicate returns the oldest link with the same canonical original url, nil if there is none
+func (c *LinkResource) findDuplicate(link model.Link) (*model.Link, error) {
+	canonicalURL, err := myvalidator.CanonicalURL(link.OriginalURL)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := c.LinkStorage.GetOneByCanonicalURL(canonicalURL)
+	if errors.Cause(err) == storage.ErrNotFound {
+		return nil, nil
+	}
+
+	return existing, err
+}
+
 // FindOne link
 // @Summary Get a link
 // @Description get link by ID
@@ -74,29 +159,43 @@ func (c *LinkResource) FindAll(r api2go.Request) (api2go.Responder, error) {
 // @Accept  json-api
 // @Produce  json-api
 // @Param id path string true "Link ID"
+// @Param include query string false "Related resources to include into the response" Enums(tags)
 // @Success 200 {object} jsonapi.Link
+// @Header 200 {string} ETag "Version of the link, to be sent in If-Match to update or delete it only if it hasn't changed"
 // @Router /links/{id} [get]
-func (c *LinkResource) FindOne(ID string, _ api2go.Request) (api2go.Responder, error) {
+func (c *LinkResource) FindOne(ID string, r api2go.Request) (api2go.Responder, error) {
 	res, err := c.LinkStorage.GetOne(ID)
 	if err != nil {
-		if err == storage.ErrNotFound {
+		if errors.Cause(err) == storage.ErrNotFound {
 			return nil, HTTPErrorPtrWithStatus(err, resourceNotFound)
 		}
 		return nil, HTTPErrorPtrWithStatus(err, internalServerError)
 	}
-	return &Response{Res: res}, nil
+	if parseIncludes(r.QueryParams)[model.TagsRelationship] {
+		links, err := c.includeTags([]*model.Link{res})
+		if err != nil {
+			return nil, HTTPErrorPtrWithStatus(err, internalServerError)
+		}
+		res = links[0]
+	}
+	setETag(r, *res)
+	// a copy, as api2go modifies the result to change the relationships
+	// (the storages may return shared instances that must not be modified)
+	return &Response{Res: *res}, nil
 }
 
 // Create a new link
 // @Summary Create a new link
-// @Description add by link json
+// @Description add by link json, with dedupe=true the oldest link with the same canonical original url is returned
+// @Description instead of creating a new one if the short name is not given (meta.deduplicated tells which happened)
 // @Tags links
 // @Accept  json-api
 // @Produce  json-api
 // @Param link body jsonapi.CreateLink true "Add link"
+// @Param dedupe query bool false "Return the existing link with the same original url"
 // @Success 201 {object} jsonapi.CreatedLink
 // @Router /links [post]
-func (c *LinkResource) Create(obj interface{}, _ api2go.Request) (api2go.Responder, error) {
+func (c *LinkResource) Create(obj interface{}, r api2go.Request) (api2go.Responder, error) {
 	link, ok := obj.(model.Link)
 	if !ok {
 		return nil, HTTPErrorPtrWithStatus(errors.New("Invalid instance given"), "")
@@ -105,43 +204,84 @@ func (c *LinkResource) Create(obj interface{}, _ api2go.Request) (api2go.Respond
 	if err := c.validator.Struct(link); err != nil {
 		return nil, HTTPErrorPtrWithStatus(err, validationError)
 	}
+	if err := c.validateTags(link); err != nil {
+		return nil, err
+	}
 
-	link.FillDefaults()
-	newLink, err := c.LinkStorage.Insert(link)
+	// a link with a given short name (or aliases) is always created, as the client wants that short name
+	if link.ShortName == "" && len(link.Aliases) == 0 && parseBoolArg(r.QueryParams, "dedupe") {
+		existing, err := c.findDuplicate(link)
+		if err != nil {
+			return nil, HTTPErrorPtrWithStatus(err, internalServerError)
+		}
+		if existing != nil {
+			// api2go only allows 201 on create
+			setETag(r, *existing)
+			return &Response{Res: existing, Code: http.StatusCreated, Meta: map[string]interface{}{"deduplicated": true}}, nil
+		}
+	}
+
+	// the short name is generated unless given
+	newLink, err := c.allocator.Insert(link)
 	if err != nil {
 		return nil, HTTPErrorPtrWithStatus(err, errors.Cause(err).Error())
 	}
+	setETag(r, *newLink)
 	return &Response{Res: newLink, Code: http.StatusCreated}, nil
 }
 
 // Delete a link :(
 // @Summary Delete a link
-// @Description Delete by link ID
+// @Description Delete by link ID, with If-Match only if the link hasn't changed since its ETag was read
 // @Tags links
 // @Accept  json-api
 // @Produce  json-api
 // @Param  id path int true "Link ID"
+// @Param  If-Match header string false "ETag of the link"
 // @Success 204
 // @Router /links/{id} [delete]
-func (c *LinkResource) Delete(id string, _ api2go.Request) (api2go.Responder, error) {
-	err := c.LinkStorage.Delete(id)
+func (c *LinkResource) Delete(id string, r api2go.Request) (api2go.Responder, error) {
+	err := c.delete(id, r)
 	if err != nil {
+		if errors.Cause(err) == storage.ErrVersionMismatch {
+			return nil, HTTPErrorPtrWithStatus(err, preconditionFailed)
+		}
 		return nil, HTTPErrorPtrWithStatus(err, resourceNotFound)
 	}
 	return &Response{Code: http.StatusNoContent}, nil
 }
 
+// delete removes the link, with If-Match only if it's still at the matching version
+func (c *LinkResource) delete(id string, r api2go.Request) error {
+	if !versioned(r) {
+		return c.LinkStorage.Delete(id)
+	}
+
+	link, err := c.LinkStorage.GetOne(id)
+	if err != nil {
+		return err
+	}
+	if !ifMatch(r, linkETag(*link)) {
+		return errors.Wrapf(storage.ErrVersionMismatch, "Link id %s is at version %d", id, link.Version)
+	}
+
+	// the link may change after it was read, so the storage checks the version once again
+	return c.LinkStorage.DeleteVersion(id, link.Version)
+}
+
 // Update a link
 // @Summary Update a link
-// @Description Update by link json
+// @Description Update by link json, with If-Match only if the link hasn't changed since its ETag was read
 // @Tags links
 // @Accept  json-api
 // @Produce  json-api
 // @Param  id path int true "Link ID"
+// @Param  If-Match header string false "ETag of the link"
 // @Param  account body jsonapi.CreateLink true "Update link"
 // @Success 200 {object} jsonapi.CreatedLink
+// @Header 200 {string} ETag "New version of the link"
 // @Router /links/{id} [patch]
-func (c *LinkResource) Update(obj interface{}, _ api2go.Request) (api2go.Responder, error) {
+func (c *LinkResource) Update(obj interface{}, r api2go.Request) (api2go.Responder, error) {
 	link, ok := obj.(model.Link)
 	if !ok {
 		var linkPtr *model.Link
@@ -152,15 +292,41 @@ func (c *LinkResource) Update(obj interface{}, _ api2go.Request) (api2go.Respond
 		link = *linkPtr
 	}
 
+	// the link is loaded by api2go with FindOne before the changes are applied, so it has the version
+	// the client has to know, the storage then makes sure that nobody changes the link in between.
+	// Without If-Match the link is updated whatever version it's at by then.
+	if !ifMatch(r, linkETag(link)) {
+		err := errors.Wrapf(storage.ErrVersionMismatch, "Link id %s is at version %d", link.ID, link.Version)
+		return nil, HTTPErrorPtrWithStatus(err, preconditionFailed)
+	}
+	if !versioned(r) {
+		link.Version = 0
+	}
+
 	if err := c.validator.Struct(link); err != nil {
 		return nil, HTTPErrorPtrWithStatus(err, validationError)
 	}
+	if err := c.validateTags(link); err != nil {
+		return nil, err
+	}
 
-	link.FillDefaults()
-	err := c.LinkStorage.Update(link)
+	updated, err := c.allocator.Update(link)
 	if err != nil {
+		if _, ok := fieldErrors[errors.Cause(err)]; ok {
+			return nil, HTTPErrorPtrWithStatus(err, validationError)
+		}
+		if errors.Cause(err) == storage.ErrVersionMismatch {
+			return nil, HTTPErrorPtrWithStatus(err, preconditionFailed)
+		}
 		return nil, HTTPErrorPtrWithStatus(err, resourceNotFound)
 	}
+	if updated.Version == 0 {
+		// only the storage knows the new version then
+		if stored, err := c.LinkStorage.GetOne(updated.ID); err == nil {
+			updated = stored
+		}
+	}
 
-	return &Response{Res: link, Code: http.StatusOK}, nil
+	setETag(r, *updated)
+	return &Response{Res: *updated, Code: http.StatusOK}, nil
 }
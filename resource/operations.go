@@ -0,0 +1,262 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/storage"
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/go-extras/api2go"
+	api2gojsonapi "github.com/go-extras/api2go/jsonapi"
+	"github.com/go-extras/errors"
+	"github.com/go-playground/validator/v10"
+)
+
+// AtomicMediaType is the media type of the JSON:API Atomic Operations extension
+const AtomicMediaType = `application/vnd.api+json;ext="https://jsonapi.org/ext/atomic"`
+
+// MaxAtomicOperations limits the number of operations in a single request
+const MaxAtomicOperations = 10000
+
+const linksType = "links"
+
+var errInvalidOperation = errors.New("invalid operation")
+
+type atomicRef struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type atomicOperation struct {
+	Op   string          `json:"op"`
+	Ref  *atomicRef      `json:"ref"`
+	Data json.RawMessage `json:"data"`
+}
+
+type atomicRequest struct {
+	Operations []atomicOperation `json:"atomic:operations"`
+}
+
+type atomicResult struct {
+	Data *api2gojsonapi.Data `json:"data,omitempty"`
+}
+
+type atomicResponse struct {
+	Results []atomicResult `json:"atomic:results"`
+}
+
+// serverInfo is used to generate the resource links in the atomic results
+type serverInfo struct{}
+
+func (serverInfo) GetBaseURL() string {
+	return ""
+}
+
+func (serverInfo) GetPrefix() string {
+	return "api"
+}
+
+// Operations applies a batch of link operations all-or-nothing
+// (see https://jsonapi.org/ext/atomic/, only the links resource is supported)
+// @Summary Apply atomic operations
+// @Description add, update and remove links in a single all-or-nothing request
+// @Tags links
+// @Accept  json-api
+// @Produce  json-api
+// @Param operations body jsonapi.Operations true "Operations"
+// @Success 200 {object} jsonapi.OperationResults
+// @Success 204
+// @Router /operations [post]
+func (c *LinkResource) Operations(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/vnd.api+json" {
+		writeAtomicError(w, HTTPErrorPtr(err, "unsupported media type", http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeAtomicError(w, HTTPErrorPtr(err, "", http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var req atomicRequest
+	if err = json.Unmarshal(body, &req); err != nil {
+		writeAtomicError(w, HTTPErrorPtr(err, err.Error(), http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if len(req.Operations) == 0 || len(req.Operations) > MaxAtomicOperations {
+		msg := fmt.Sprintf("between 1 and %d operations expected", MaxAtomicOperations)
+		writeAtomicError(w, HTTPErrorPtr(nil, msg, http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	ops := make([]linkstorage.Operation, 0, len(req.Operations))
+	for i, op := range req.Operations {
+		parsed, err := c.parseOperation(op)
+		if err != nil {
+			writeAtomicOperationError(w, i, err)
+			return
+		}
+		ops = append(ops, parsed)
+	}
+
+	links, err := c.allocator.Batch(ops)
+	if err != nil {
+		if batchErr, ok := err.(*linkstorage.BatchError); ok {
+			writeAtomicOperationError(w, batchErr.Index, batchErr.Err)
+			return
+		}
+		writeAtomicError(w, HTTPErrorPtr(err, internalServerError, http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := atomicResponse{Results: make([]atomicResult, len(links))}
+	hasData := false
+	for i, link := range links {
+		if link == nil {
+			continue
+		}
+		doc, err := api2gojsonapi.MarshalToStruct(link, serverInfo{})
+		if err != nil {
+			writeAtomicError(w, HTTPErrorPtr(err, "", http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		resp.Results[i].Data = doc.Data.DataObject
+		hasData = true
+	}
+
+	if !hasData {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeAtomicResult(w, resp, http.StatusOK)
+}
+
+// parseOperation converts an atomic operation into a storage operation and validates it
+func (c *LinkResource) parseOperation(op atomicOperation) (linkstorage.Operation, error) {
+	var result linkstorage.Operation
+
+	var ref atomicRef
+	if op.Ref != nil {
+		ref = *op.Ref
+	}
+	if len(op.Data) > 0 && string(op.Data) != "null" {
+		if err := json.Unmarshal(op.Data, &ref); err != nil {
+			return result, errors.Wrapf(errInvalidOperation, "%s", err)
+		}
+	}
+	if ref.Type != linksType {
+		return result, errors.Wrapf(errInvalidOperation, "unsupported resource type %q", ref.Type)
+	}
+
+	switch linkstorage.OperationKind(op.Op) {
+	case linkstorage.OperationAdd:
+		result.Kind = linkstorage.OperationAdd
+		if err := unmarshalLinkData(op.Data, &result.Link); err != nil {
+			return result, err
+		}
+		result.Link.ID = ""
+	case linkstorage.OperationUpdate:
+		result.Kind = linkstorage.OperationUpdate
+		existing, err := c.LinkStorage.GetOne(ref.ID)
+		if err != nil {
+			return result, err
+		}
+		// the storage instance must not be modified
+		result.Link = *existing
+		if err = unmarshalLinkData(op.Data, &result.Link); err != nil {
+			return result, err
+		}
+		if result.Link.ID != ref.ID {
+			return result, errors.Wrapf(errInvalidOperation, "id in the resource does not match the ref")
+		}
+	case linkstorage.OperationRemove:
+		if ref.ID == "" {
+			return result, errors.Wrapf(errInvalidOperation, "ref id is required to remove a link")
+		}
+		result.Kind = linkstorage.OperationRemove
+		result.Link.ID = ref.ID
+		return result, nil
+	default:
+		return result, errors.Wrapf(errInvalidOperation, "unsupported operation %q", op.Op)
+	}
+
+	if err := c.validator.Struct(result.Link); err != nil {
+		return result, err
+	}
+	if _, err := c.TagStorage.GetByIDs(result.Link.TagIDs); err != nil {
+		if errors.Cause(err) == storage.ErrNotFound {
+			return result, errors.Wrapf(errInvalidOperation, "%s", err)
+		}
+		return result, err
+	}
+
+	return result, nil
+}
+
+func unmarshalLinkData(data json.RawMessage, link *model.Link) error {
+	if len(data) == 0 {
+		return errors.Wrapf(errInvalidOperation, "data is required")
+	}
+	doc, err := json.Marshal(map[string]json.RawMessage{"data": data})
+	if err != nil {
+		return err
+	}
+
+	if err = api2gojsonapi.Unmarshal(doc, link); err != nil {
+		return errors.Wrapf(errInvalidOperation, "%s", err)
+	}
+
+	return nil
+}
+
+// writeAtomicOperationError responds with the error of the operation with the given index
+func writeAtomicOperationError(w http.ResponseWriter, index int, err error) {
+	status := StatusByError(err)
+	msg := err.Error()
+	if _, ok := err.(validator.ValidationErrors); ok {
+		msg = validationError
+	}
+	httpErr := HTTPErrorPtr(err, msg, status)
+	if len(httpErr.Errors) == 0 {
+		if status == http.StatusInternalServerError {
+			msg = internalServerError
+		}
+		httpErr.Errors = []api2go.Error{{Title: msg}}
+	}
+	for i := range httpErr.Errors {
+		httpErr.Errors[i].Status = strconv.Itoa(status)
+		// the attribute pointers are relative to the operation
+		pointer := fmt.Sprintf("/atomic:operations/%d", index)
+		if source := httpErr.Errors[i].Source; source != nil {
+			pointer += source.Pointer
+		}
+		httpErr.Errors[i].Source = &api2go.ErrorSource{Pointer: pointer}
+	}
+
+	writeAtomicError(w, httpErr, status)
+}
+
+func writeAtomicError(w http.ResponseWriter, httpErr *api2go.HTTPError, status int) {
+	if len(httpErr.Errors) == 0 {
+		httpErr.Errors = []api2go.Error{{Title: http.StatusText(status), Status: strconv.Itoa(status)}}
+	}
+	writeAtomicResult(w, httpErr, status)
+}
+
+func writeAtomicResult(w http.ResponseWriter, result interface{}, status int) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		status = http.StatusInternalServerError
+		data = []byte(`{"errors":[{"title":"internal server error","status":"500"}]}`)
+	}
+	w.Header().Set("Content-Type", AtomicMediaType)
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
@@ -4,15 +4,16 @@ package resource
 type Response struct {
 	Res  interface{}
 	Code int
+	Meta map[string]interface{}
 }
 
 // Metadata returns additional meta data
 func (r Response) Metadata() map[string]interface{} {
-	return map[string]interface{}{
-		//"author":      "The api2go examples crew",
-		//"license":     "wtfpl",
-		//"license-url": "http://www.wtfpl.net",
+	if r.Meta == nil {
+		return map[string]interface{}{}
 	}
+
+	return r.Meta
 }
 
 // Result returns the actual payload
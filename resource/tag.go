@@ -0,0 +1,187 @@
+package resource
+
+import (
+	"net/http"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/storage"
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/denisvmedia/urlshortener/storage/tagstorage"
+	myvalidator "github.com/denisvmedia/urlshortener/validator"
+	"github.com/go-extras/api2go"
+	"github.com/go-extras/errors"
+	"github.com/go-playground/validator/v10"
+)
+
+// TagResource for api2go routes
+type TagResource struct {
+	TagStorage  tagstorage.Storage
+	LinkStorage linkstorage.Storage
+	validator   *validator.Validate
+}
+
+// NewTagResource creates a new TagResource instance for given tag and link storages
+func NewTagResource(tagStorage tagstorage.Storage, linkStorage linkstorage.Storage) *TagResource {
+	return &TagResource{
+		TagStorage:  tagStorage,
+		LinkStorage: linkStorage,
+		validator:   myvalidator.New(),
+	}
+}
+
+// FindAll tags
+// @Summary List tags
+// @Description get tags
+// @Tags tags
+// @Accept  json-api
+// @Produce  json-api
+// @Param page[number] query int false "Page number" default(1)
+// @Param page[size] query int false "Page size" default(10) maximum(1000)
+// @Success 200 {object} jsonapi.Tags
+// @Router /tags [get]
+func (c *TagResource) FindAll(r api2go.Request) (api2go.Responder, error) {
+	// api2go calls FindAll with the link id for the related resource route (/links/{id}/tags)
+	if linkIDs := r.QueryParams["linksID"]; len(linkIDs) > 0 {
+		return c.findAllByLink(linkIDs[0])
+	}
+
+	pagination := parsePageArgs(r.QueryParams)
+
+	tags, total, err := c.TagStorage.PaginatedGetAll(pagination.Number, pagination.Size)
+	if err != nil {
+		return nil, HTTPErrorPtrWithStatus(err, internalServerError)
+	}
+
+	result := &api2go.Response{
+		Res:  tags,
+		Code: http.StatusOK,
+		Meta: map[string]interface{}{
+			"tags": total,
+		},
+		Pagination: getPagination(pagination.Number, pagination.Size, total),
+	}
+
+	return result, nil
+}
+
+func (c *TagResource) findAllByLink(linkID string) (api2go.Responder, error) {
+	link, err := c.LinkStorage.GetOne(linkID)
+	if err != nil {
+		return nil, HTTPErrorPtrWithStatus(err, resourceNotFound)
+	}
+
+	tags, err := c.TagStorage.GetByIDs(link.TagIDs)
+	if err != nil {
+		return nil, HTTPErrorPtrWithStatus(err, internalServerError)
+	}
+
+	return &api2go.Response{
+		Res:  tags,
+		Code: http.StatusOK,
+		Meta: map[string]interface{}{
+			"tags": len(tags),
+		},
+	}, nil
+}
+
+// FindOne tag
+// @Summary Get a tag
+// @Description get tag by ID
+// @Tags tags
+// @Accept  json-api
+// @Produce  json-api
+// @Param id path string true "Tag ID"
+// @Success 200 {object} jsonapi.Tag
+// @Router /tags/{id} [get]
+func (c *TagResource) FindOne(ID string, _ api2go.Request) (api2go.Responder, error) {
+	res, err := c.TagStorage.GetOne(ID)
+	if err != nil {
+		if errors.Cause(err) == storage.ErrNotFound {
+			return nil, HTTPErrorPtrWithStatus(err, resourceNotFound)
+		}
+		return nil, HTTPErrorPtrWithStatus(err, internalServerError)
+	}
+	return &Response{Res: res}, nil
+}
+
+// Create a new tag
+// @Summary Create a new tag
+// @Description add by tag json
+// @Tags tags
+// @Accept  json-api
+// @Produce  json-api
+// @Param tag body jsonapi.CreateTag true "Add tag"
+// @Success 201 {object} jsonapi.CreatedTag
+// @Router /tags [post]
+func (c *TagResource) Create(obj interface{}, _ api2go.Request) (api2go.Responder, error) {
+	tag, ok := obj.(model.Tag)
+	if !ok {
+		return nil, HTTPErrorPtrWithStatus(errors.New("Invalid instance given"), "")
+	}
+
+	if err := c.validator.Struct(tag); err != nil {
+		return nil, HTTPErrorPtrWithStatus(err, validationError)
+	}
+
+	newTag, err := c.TagStorage.Insert(tag)
+	if err != nil {
+		return nil, HTTPErrorPtrWithStatus(err, errors.Cause(err).Error())
+	}
+	return &Response{Res: newTag, Code: http.StatusCreated}, nil
+}
+
+// Delete a tag (it's detached from all the links)
+// @Summary Delete a tag
+// @Description Delete by tag ID
+// @Tags tags
+// @Accept  json-api
+// @Produce  json-api
+// @Param  id path int true "Tag ID"
+// @Success 204
+// @Router /tags/{id} [delete]
+func (c *TagResource) Delete(id string, _ api2go.Request) (api2go.Responder, error) {
+	// the tag is detached first, as the MySQL storage removes the tag from the links along with it
+	// (without incrementing the link versions)
+	err := c.LinkStorage.DetachTag(id)
+	if err != nil {
+		return nil, HTTPErrorPtrWithStatus(err, internalServerError)
+	}
+	err = c.TagStorage.Delete(id)
+	if err != nil {
+		return nil, HTTPErrorPtrWithStatus(err, resourceNotFound)
+	}
+	return &Response{Code: http.StatusNoContent}, nil
+}
+
+// Update a tag
+// @Summary Update a tag
+// @Description Update by tag json
+// @Tags tags
+// @Accept  json-api
+// @Produce  json-api
+// @Param  id path int true "Tag ID"
+// @Param  tag body jsonapi.CreateTag true "Update tag"
+// @Success 200 {object} jsonapi.CreatedTag
+// @Router /tags/{id} [patch]
+func (c *TagResource) Update(obj interface{}, _ api2go.Request) (api2go.Responder, error) {
+	tag, ok := obj.(model.Tag)
+	if !ok {
+		var tagPtr *model.Tag
+		tagPtr, ok = obj.(*model.Tag)
+		if !ok {
+			return nil, HTTPErrorPtrWithStatus(errors.New("Invalid instance given"), "")
+		}
+		tag = *tagPtr
+	}
+
+	if err := c.validator.Struct(tag); err != nil {
+		return nil, HTTPErrorPtrWithStatus(err, validationError)
+	}
+
+	err := c.TagStorage.Update(tag)
+	if err != nil {
+		return nil, HTTPErrorPtrWithStatus(err, errors.Cause(err).Error())
+	}
+
+	return &Response{Res: tag, Code: http.StatusOK}, nil
+}
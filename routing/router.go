@@ -7,6 +7,10 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// ResponseHeaderKey is the api2go context key of the http.Header which values are added to the response,
+// it lets the resources set the response headers (the api2go responders only have the status and the body)
+const ResponseHeaderKey = "responseHeader"
+
 type echoRouter struct {
 	echo *echo.Echo
 }
@@ -24,7 +28,14 @@ func (e echoRouter) Handle(protocol, route string, handler routing.HandlerFunc)
 			params[p] = c.ParamValues()[i]
 		}
 
-		handler(c.Response(), c.Request(), params, make(map[string]interface{}))
+		header := http.Header{}
+		c.Response().Before(func() {
+			for key, values := range header {
+				c.Response().Header()[key] = values
+			}
+		})
+
+		handler(c.Response(), c.Request(), params, map[string]interface{}{ResponseHeaderKey: header})
 
 		return nil
 	}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/go-extras/errors"
+	"github.com/labstack/echo/v4"
+)
+
+// healthCheckTimeout limits the time of each readiness check
+const healthCheckTimeout = 2 * time.Second
+
+// Health statuses
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+// HealthCheckResult describes the result of a single check
+type HealthCheckResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latencyMs"`
+}
+
+// HealthResponse is the response of the health endpoints, the status is ok only if all the checks pass
+type HealthResponse struct {
+	Status string              `json:"status"`
+	Checks []HealthCheckResult `json:"checks"`
+}
+
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// healthHandler runs the checks one by one and responds with 200 if all of them pass or 503 otherwise
+func healthHandler(checks ...healthCheck) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		response := HealthResponse{
+			Status: HealthStatusOK,
+			Checks: make([]HealthCheckResult, 0, len(checks)),
+		}
+		for _, check := range checks {
+			ctx, cancel := context.WithTimeout(c.Request().Context(), healthCheckTimeout)
+			start := time.Now()
+			err := check.check(ctx)
+			latency := time.Since(start)
+			cancel()
+
+			result := HealthCheckResult{
+				Name:      check.name,
+				Status:    HealthStatusOK,
+				LatencyMs: float64(latency) / float64(time.Millisecond),
+			}
+			if err != nil {
+				result.Status = HealthStatusFail
+				result.Error = err.Error()
+				response.Status = HealthStatusFail
+			}
+			response.Checks = append(response.Checks, result)
+		}
+
+		code := http.StatusOK
+		if response.Status != HealthStatusOK {
+			code = http.StatusServiceUnavailable
+		}
+		// the probes must never be answered from a cache
+		c.Response().Header().Set("Cache-Control", "no-store")
+
+		return c.JSON(code, response)
+	}
+}
+
+// livenessHandler tells that the process is alive and able to serve the requests
+func livenessHandler() echo.HandlerFunc {
+	return healthHandler()
+}
+
+// readinessHandler tells whether the server can serve the traffic: the storage is reachable,
+// its schema is up to date and the server is not shutting down
+func readinessHandler(linkStorage linkstorage.Storage, status *Status) echo.HandlerFunc {
+	return healthHandler(
+		healthCheck{"storage", linkStorage.Ping},
+		healthCheck{"migrations", linkStorage.CheckMigrations},
+		healthCheck{"draining", func(context.Context) error {
+			if status.Draining() {
+				return errors.New("the server is shutting down")
+			}
+			return nil
+		}},
+	)
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/denisvmedia/urlshortener/metrics"
+	"github.com/denisvmedia/urlshortener/ratelimit"
+	"github.com/go-extras/api2go"
+	"github.com/labstack/echo/v4"
+)
+
+// APIKeyHeader is the header that identifies the api clients for the rate limiting
+const APIKeyHeader = "X-API-Key"
+
+const tooManyRequests = "too many requests"
+
+// RateLimits holds the limiters of the server. The requests are limited both by the client ip address
+// and by the api key (if it's given), so that neither of them can be used to get around the limits.
+type RateLimits struct {
+	// APIWrites limits the api requests that change the data
+	APIWrites *ratelimit.Limiter
+	// Redirects limits the redirect requests
+	Redirects *ratelimit.Limiter
+	// TrustProxyHeaders makes the client ip address to be taken from X-Forwarded-For and X-Real-IP headers,
+	// it must only be enabled behind a proxy that sets them
+	TrustProxyHeaders bool
+}
+
+// NewRateLimits creates the limiters with the given policies
+func NewRateLimits(apiWrites, redirects ratelimit.Policy, trustProxyHeaders bool) *RateLimits {
+	return &RateLimits{
+		APIWrites:         ratelimit.NewLimiter(apiWrites),
+		Redirects:         ratelimit.NewLimiter(redirects),
+		TrustProxyHeaders: trustProxyHeaders,
+	}
+}
+
+func (r *RateLimits) clientIP(c echo.Context) string {
+	if r.TrustProxyHeaders {
+		return c.RealIP()
+	}
+	host, _, err := net.SplitHostPort(c.Request().RemoteAddr)
+	if err != nil {
+		return c.Request().RemoteAddr
+	}
+
+	return host
+}
+
+// allow takes a token from the ip address and the api key buckets if both have one (so that a request over
+// one of the limits doesn't use up the other one), it returns the time to wait if the request is over any of the limits
+func (r *RateLimits) allow(c echo.Context, policy string, limiter *ratelimit.Limiter) (bool, time.Duration) {
+	keys, kinds := []string{"ip:" + r.clientIP(c)}, []string{"ip"}
+	if key := c.Request().Header.Get(APIKeyHeader); key != "" {
+		keys, kinds = append(keys, "key:"+key), append(kinds, "api_key")
+	}
+	if allowed, denied, wait := limiter.AllowAll(keys...); !allowed {
+		metrics.RequestsThrottled.WithLabelValues(policy, kinds[denied]).Inc()
+		return false, wait
+	}
+
+	return true, 0
+}
+
+// retryAfter sets Retry-After header in whole seconds (rounded up) and returns the value
+func retryAfter(c echo.Context, wait time.Duration) int {
+	seconds := int(math.Max(1, math.Ceil(wait.Seconds())))
+	c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+
+	return seconds
+}
+
+func isAPIWrite(r *http.Request) bool {
+	if r.URL.Path != "/api" && !strings.HasPrefix(r.URL.Path, "/api/") {
+		return false
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+
+	return true
+}
+
+// limitAPIWrites responds to the api writes over the limit with 429 and a JSON:API error
+func (r *RateLimits) limitAPIWrites() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !isAPIWrite(c.Request()) {
+				return next(c)
+			}
+			allowed, wait := r.allow(c, "api_writes", r.APIWrites)
+			if allowed {
+				return next(c)
+			}
+
+			seconds := retryAfter(c, wait)
+			httpErr := api2go.NewHTTPError(nil, tooManyRequests, http.StatusTooManyRequests)
+			httpErr.Errors = []api2go.Error{{
+				Status: strconv.Itoa(http.StatusTooManyRequests),
+				Title:  tooManyRequests,
+				Detail: "retry after " + strconv.Itoa(seconds) + " seconds",
+			}}
+			data, err := json.Marshal(httpErr)
+			if err != nil {
+				return err
+			}
+
+			return c.Blob(http.StatusTooManyRequests, "application/vnd.api+json", data)
+		}
+	}
+}
+
+// limitRedirects responds to the redirects over the limit with 429
+func (r *RateLimits) limitRedirects() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			allowed, wait := r.allow(c, "redirects", r.Redirects)
+			if allowed {
+				return next(c)
+			}
+			retryAfter(c, wait)
+
+			return c.String(http.StatusTooManyRequests, tooManyRequests)
+		}
+	}
+}
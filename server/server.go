@@ -1,36 +1,120 @@
 package server
 
 import (
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strings"
+
 	"github.com/denisvmedia/urlshortener/model"
 	"github.com/denisvmedia/urlshortener/resource"
 	"github.com/denisvmedia/urlshortener/routing"
 	"github.com/denisvmedia/urlshortener/shortener"
 	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/denisvmedia/urlshortener/storage/tagstorage"
 	"github.com/go-extras/api2go"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/labstack/gommon/log"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	echoSwagger "github.com/swaggo/echo-swagger"
 )
 
-// NewEcho create a new API router
-func NewEcho(linkStorage linkstorage.Storage) *echo.Echo {
+// newEcho creates a router with the common middleware
+func newEcho() *echo.Echo {
 	e := echo.New()
 	// Middleware
-	e.Use(middleware.Logger())
+	accessLog := middleware.DefaultLoggerConfig
+	accessLog.Skipper = func(c echo.Context) bool {
+		// the access log follows the logger level, which can be changed at runtime
+		return c.Echo().Logger.Level() > log.INFO
+	}
+	e.Use(middleware.LoggerWithConfig(accessLog))
 	e.Use(middleware.Recover())
 
+	return e
+}
+
+// NewEcho create a new API router. The admin routes (metrics, swagger and health) are registered
+// on the admin router if it's given (see NewAdminEcho), or on the API router otherwise.
+// The requests are not rate limited if limits are nil, the links are not flagged as malicious if flagged is nil.
+func NewEcho(linkStorage linkstorage.Storage, tagStorage tagstorage.Storage, status *Status, admin *echo.Echo, limits *RateLimits, flagged shortener.FlaggedLinks) *echo.Echo {
+	e := newEcho()
+	e.Use(closeWhileDraining(status))
+	var redirectMiddleware []echo.MiddlewareFunc
+	if limits != nil {
+		e.Use(limits.limitAPIWrites())
+		redirectMiddleware = append(redirectMiddleware, limits.limitRedirects())
+	}
+
 	api := api2go.NewAPIWithRouting(
 		"api",
 		api2go.NewStaticResolver("/"),
 		routing.Echo(e),
 	)
 
-	api.AddResource(model.Link{}, resource.NewLinkResource(linkStorage))
+	linkResource := resource.NewLinkResource(linkStorage, tagStorage)
+	api.AddResource(model.Link{}, linkResource)
+	api.AddResource(model.Tag{}, resource.NewTagResource(tagStorage, linkStorage))
+	api.AddResource(model.Alias{}, resource.NewAliasResource(linkStorage))
 
-	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
-	e.GET("/swagger/*any", echoSwagger.EchoWrapHandler(echoSwagger.URL("/swagger/doc.json")))
-	e.GET("/*", shortener.Handler(linkStorage))
+	e.POST("/api/operations", echo.WrapHandler(http.HandlerFunc(linkResource.Operations)))
+	if admin == nil {
+		admin = e
+	}
+	admin.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+	admin.GET("/healthz", livenessHandler())
+	admin.GET("/readyz", readinessHandler(linkStorage, status))
+	admin.GET("/swagger/*any", echoSwagger.EchoWrapHandler(echoSwagger.URL("/swagger/doc.json")))
+	e.GET("/*", shortener.Handler(linkStorage, flagged), redirectMiddleware...)
 
 	return e
 }
+
+// NewAdminEcho creates a router for the admin listener, it serves the profiler in addition to the admin
+// routes registered by NewEcho (the profiler is never exposed on the API router)
+func NewAdminEcho() *echo.Echo {
+	e := newEcho()
+	e.HideBanner = true
+
+	e.GET("/debug/pprof/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+	e.GET("/debug/pprof/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)))
+	e.GET("/debug/pprof/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	e.POST("/debug/pprof/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	e.GET("/debug/pprof/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)))
+	// the index serves the named profiles as well (e.g. /debug/pprof/heap)
+	e.GET("/debug/pprof/*", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
+
+	return e
+}
+
+// ReservedShortNames returns the first path segments of the routes, they can't be used as short names
+// as the catch-all redirect route would never get them
+func ReservedShortNames(e *echo.Echo) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, route := range e.Routes() {
+		name := strings.SplitN(strings.TrimPrefix(route.Path, "/"), "/", 2)[0]
+		if name == "" || strings.ContainsAny(name, ":*") || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// closeWhileDraining makes the clients close their keep-alive connections while the server is draining,
+// so that their next requests go to the other instances
+func closeWhileDraining(status *Status) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if status.Draining() {
+				c.Response().Header().Set("Connection", "close")
+			}
+			return next(c)
+		}
+	}
+}
@@ -9,23 +9,45 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"sync"
 
 	"github.com/denisvmedia/urlshortener/cmd"
 	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/ratelimit"
 	"github.com/denisvmedia/urlshortener/server"
 	"github.com/denisvmedia/urlshortener/shortener"
 	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/denisvmedia/urlshortener/storage/tagstorage"
+	"github.com/denisvmedia/urlshortener/validator"
 	"github.com/labstack/echo/v4"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
+// racingLinkStorage runs afterGetOne once after the first GetOne, to simulate a concurrent change
+type racingLinkStorage struct {
+	linkstorage.Storage
+	afterGetOne func()
+}
+
+func (s *racingLinkStorage) GetOne(id string) (*model.Link, error) {
+	link, err := s.Storage.GetOne(id)
+	if s.afterGetOne != nil {
+		fn := s.afterGetOne
+		s.afterGetOne = nil
+		fn()
+	}
+	return link, err
+}
+
 var _ = Describe("Functional Tests", func() {
 	var apiHandler http.Handler
 	var linkStorage linkstorage.Storage
+	var tagStorage tagstorage.Storage
 	var dbData cmd.Mysql // a little bit ugly borrowing this structure from `cmd`, but it works...
+	var status *server.Status
 
 	BeforeEach(func() {
 		log.SetOutput(ioutil.Discard)
@@ -46,10 +68,13 @@ var _ = Describe("Functional Tests", func() {
 			dbh, err := linkstorage.MysqlConnect(dbData.User, dbData.Password, dbData.Host, dbData.Name)
 			Expect(err).ToNot(HaveOccurred())
 			linkStorage = linkstorage.NewMysqlStorage(dbh)
+			tagStorage = tagstorage.NewMysqlStorage(dbh)
 		} else {
 			linkStorage = linkstorage.NewInMemoryStorage()
+			tagStorage = tagstorage.NewInMemoryStorage()
 		}
-		apiHandler = server.NewEcho(linkStorage).Server.Handler
+		status = &server.Status{}
+		apiHandler = server.NewEcho(linkStorage, tagStorage, status, nil, nil, nil).Server.Handler
 	})
 
 	AfterEach(func() {
@@ -122,6 +147,22 @@ var _ = Describe("Functional Tests", func() {
 					  "comment": "And some cool comment",
 					  "originalUrl": "https://example.com/my-super-puper/url?withArgs=val%20with%20space#and-hash",
 					  "shortName": "my-cool-shortName"
+					},
+					"relationships": {
+					  "aliases": {
+					    "data": [],
+					    "links": {
+					      "related": "/api/links/1/aliases",
+					      "self": "/api/links/1/relationships/aliases"
+					    }
+					  },
+					  "tags": {
+					    "data": [],
+					    "links": {
+					      "related": "/api/links/1/tags",
+					      "self": "/api/links/1/relationships/tags"
+					    }
+					  }
 					}
 				}
 			}
@@ -146,6 +187,22 @@ var _ = Describe("Functional Tests", func() {
 					  "comment": "",
 					  "originalUrl": "https://example.com/another-link",
 					  "shortName": "another-link"
+					},
+					"relationships": {
+					  "aliases": {
+					    "data": [],
+					    "links": {
+					      "related": "/api/links/2/aliases",
+					      "self": "/api/links/2/relationships/aliases"
+					    }
+					  },
+					  "tags": {
+					    "data": [],
+					    "links": {
+					      "related": "/api/links/2/tags",
+					      "self": "/api/links/2/relationships/tags"
+					    }
+					  }
 					}
 				}
 			}
@@ -190,6 +247,8 @@ var _ = Describe("Functional Tests", func() {
 				)
 				apiHandler.ServeHTTP(rec, req)
 				Expect(rec.Code).To(Equal(http.StatusBadRequest))
+				Expect(rec.Body.String()).To(ContainSubstring(`"code":"invalid_url"`))
+				Expect(rec.Body.String()).To(ContainSubstring(`"detail":"originalUrl must be an absolute url"`))
 			})
 
 			By("Should fail when giving an invalid short link", func() {
@@ -201,6 +260,63 @@ var _ = Describe("Functional Tests", func() {
 				)
 				apiHandler.ServeHTTP(rec, req)
 				Expect(rec.Code).To(Equal(http.StatusBadRequest))
+
+				var result map[string][]map[string]interface{}
+				Expect(json.Unmarshal(rec.Body.Bytes(), &result)).To(Succeed())
+				Expect(result["errors"]).To(ConsistOf(map[string]interface{}{
+					"id":     "Link.ShortName:shortname",
+					"code":   "invalid_short_name",
+					"title":  "Field validation failed",
+					"detail": "shortName must only contain letters (of the allowed scripts, not mixed), digits and dashes, and must not be (or look like) a reserved name",
+					"source": map[string]interface{}{"pointer": "/data/attributes/shortName"},
+				}))
+			})
+
+			By("Should fail when giving a denied destination domain", func() {
+				Expect(validator.SetDestinationDomains(nil, []string{"*.phish.net"})).To(Succeed())
+				defer func() {
+					Expect(validator.SetDestinationDomains(nil, nil)).To(Succeed())
+				}()
+
+				rec := httptest.NewRecorder()
+				req := newLinkRequest("phishing", "https://login.phish.net/", "")
+				apiHandler.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusBadRequest))
+				Expect(rec.Body.String()).To(ContainSubstring(`"id":"Link.OriginalURL:urldomain"`))
+				Expect(rec.Body.String()).To(ContainSubstring("the destination domain is not allowed"))
+				Expect(rec.Body.String()).To(ContainSubstring(`"code":"domain_not_allowed"`))
+				Expect(rec.Body.String()).To(ContainSubstring(`"source":{"pointer":"/data/attributes/originalUrl"}`))
+			})
+
+			By("Should store the internationalized links in the normalized form", func() {
+				rec := httptest.NewRecorder()
+				apiHandler.ServeHTTP(rec, newLinkRequest("cafe\u0301", "https://Bücher.example/", ""))
+				Expect(rec.Code).To(Equal(http.StatusCreated))
+				Expect(rec.Body.String()).To(ContainSubstring(`"shortName":"café"`))
+				Expect(rec.Body.String()).To(ContainSubstring(`"originalUrl":"https://xn--bcher-kva.example/"`))
+
+				rec = httptest.NewRecorder()
+				apiHandler.ServeHTTP(rec, newLinkRequest("pаypal", "https://example.com/", ""))
+				Expect(rec.Code).To(Equal(http.StatusBadRequest))
+				Expect(rec.Body.String()).To(ContainSubstring(`"code":"invalid_short_name"`))
+			})
+
+			By("Should fail when the link loops back to itself through the short links", func() {
+				Expect(validator.SetRedirectChains(validator.RedirectChains{Hosts: []string{"sho.rt"}, MaxDepth: 3})).To(Succeed())
+				defer func() {
+					Expect(validator.SetRedirectChains(validator.RedirectChains{MaxDepth: validator.DefaultRedirectChainDepth})).To(Succeed())
+				}()
+
+				rec := httptest.NewRecorder()
+				apiHandler.ServeHTTP(rec, newLinkRequest("loop-a", "https://sho.rt/loop-b", ""))
+				Expect(rec.Code).To(Equal(http.StatusCreated))
+
+				rec = httptest.NewRecorder()
+				apiHandler.ServeHTTP(rec, newLinkRequest("loop-b", "https://sho.rt/loop-a", ""))
+				Expect(rec.Code).To(Equal(http.StatusBadRequest))
+				Expect(rec.Body.String()).To(ContainSubstring(`"id":"Link.OriginalURL:redirectloop"`))
+				Expect(rec.Body.String()).To(ContainSubstring(`"code":"redirect_loop"`))
+				Expect(rec.Body.String()).To(ContainSubstring(`loop-b -\u003e loop-a -\u003e loop-b`))
 			})
 
 			By("Should fail when passing an invalid json", func() {
@@ -270,6 +386,22 @@ var _ = Describe("Functional Tests", func() {
 					  "comment": "add a comment",
 					  "originalUrl": "https://example.com/my-updated-cool-link",
 					  "shortName": "my-updated-cool-link"
+					},
+					"relationships": {
+					  "aliases": {
+					    "data": [],
+					    "links": {
+					      "related": "/api/links/1/aliases",
+					      "self": "/api/links/1/relationships/aliases"
+					    }
+					  },
+					  "tags": {
+					    "data": [],
+					    "links": {
+					      "related": "/api/links/1/tags",
+					      "self": "/api/links/1/relationships/tags"
+					    }
+					  }
 					}
 				}
 			}
@@ -376,6 +508,22 @@ var _ = Describe("Functional Tests", func() {
 					  "comment": "",
 					  "originalUrl": "https://example.com/my-cool-link",
 					  "shortName": "my-cool-link"
+					},
+					"relationships": {
+					  "aliases": {
+					    "data": [],
+					    "links": {
+					      "related": "/api/links/1/aliases",
+					      "self": "/api/links/1/relationships/aliases"
+					    }
+					  },
+					  "tags": {
+					    "data": [],
+					    "links": {
+					      "related": "/api/links/1/tags",
+					      "self": "/api/links/1/relationships/tags"
+					    }
+					  }
 					}
 				}
 			}
@@ -447,6 +595,22 @@ var _ = Describe("Functional Tests", func() {
 					"shortName": "my-cool-link",
 					"originalUrl": "https://example.com/my-cool-link",
 					"comment": ""
+				  },
+				  "relationships": {
+				    "aliases": {
+				      "data": [],
+				      "links": {
+				        "related": "/api/links/1/aliases",
+				        "self": "/api/links/1/relationships/aliases"
+				      }
+				    },
+				    "tags": {
+				      "data": [],
+				      "links": {
+				        "related": "/api/links/1/tags",
+				        "self": "/api/links/1/relationships/tags"
+				      }
+				    }
 				  }
 				}
 			  ],
@@ -494,6 +658,724 @@ var _ = Describe("Functional Tests", func() {
 			})
 		})
 
+		It("API Searches links", func() {
+			By("Creating links", func() {
+				for _, v := range [][3]string{
+					{"spring-sale", "https://example.com/shop/spring-sale", "Spring campaign landing page"},
+					{"newsletter", "https://example.com/newsletter/subscribe", "Newsletter subscription"},
+					{"autumn-sale", "https://example.com/shop/autumn-sale", "Autumn campaign landing page"},
+				} {
+					rec := httptest.NewRecorder()
+					req := newLinkRequest(v[0], v[1], v[2])
+					apiHandler.ServeHTTP(rec, req)
+					Expect(rec.Code).To(Equal(http.StatusCreated))
+				}
+			})
+
+			var search = func(query string) (shortNames []string, total float64) {
+				rec := httptest.NewRecorder()
+				req, err := http.NewRequest("GET", "/api/links?filter[q]="+query, nil)
+				Expect(err).ToNot(HaveOccurred())
+				apiHandler.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusOK))
+
+				m := make(map[string]interface{})
+				err = json.Unmarshal(rec.Body.Bytes(), &m)
+				Expect(err).ToNot(HaveOccurred())
+				for _, item := range m["data"].([]interface{}) {
+					attributes := item.(map[string]interface{})["attributes"].(map[string]interface{})
+					shortNames = append(shortNames, attributes["shortName"].(string))
+				}
+				return shortNames, m["meta"].(map[string]interface{})["links"].(float64)
+			}
+
+			By("Should find links by a word in the comment", func() {
+				shortNames, total := search("campaign")
+				Expect(total).To(Equal(float64(2)))
+				Expect(shortNames).To(ConsistOf("spring-sale", "autumn-sale"))
+			})
+
+			By("Should find links by a word in the original url", func() {
+				shortNames, total := search("subscribe")
+				Expect(total).To(Equal(float64(1)))
+				Expect(shortNames).To(ConsistOf("newsletter"))
+			})
+
+			By("Should get an empty list when nothing matches", func() {
+				shortNames, total := search("nonexistentword")
+				Expect(total).To(Equal(float64(0)))
+				Expect(shortNames).To(BeEmpty())
+			})
+
+			By("Should find updated links by their new content", func() {
+				rec := httptest.NewRecorder()
+				req := updateLinkRequest("2", "newsletter", "https://example.com/newsletter/subscribe", "Weekly digest")
+				apiHandler.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusOK))
+
+				shortNames, _ := search("digest")
+				Expect(shortNames).To(ConsistOf("newsletter"))
+				shortNames, _ = search("subscription")
+				Expect(shortNames).To(BeEmpty())
+			})
+		})
+
+		It("API Deduplicates links", func() {
+			var create = func(path, shortName, originalURL string) (id string, meta map[string]interface{}) {
+				rec := httptest.NewRecorder()
+				req := newLinkRequest(shortName, originalURL, "")
+				req.URL.RawQuery = path
+				apiHandler.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusCreated))
+
+				m := make(map[string]interface{})
+				Expect(json.Unmarshal(rec.Body.Bytes(), &m)).To(Succeed())
+				meta, _ = m["meta"].(map[string]interface{})
+				return m["data"].(map[string]interface{})["id"].(string), meta
+			}
+
+			id, _ := create("", "", "https://Example.com:443/page/?b=2&a=1")
+
+			By("Should return the existing link with the same canonical url", func() {
+				for _, originalURL := range []string{
+					"https://example.com/page?a=1&b=2",
+					"https://EXAMPLE.com/page/?b=2&a=1",
+				} {
+					dupID, meta := create("dedupe=true", "", originalURL)
+					Expect(dupID).To(Equal(id), originalURL)
+					Expect(meta).To(HaveKeyWithValue("deduplicated", true))
+				}
+			})
+
+			By("Should create a new link without dedupe, with a short name or for another url", func() {
+				for _, v := range [][3]string{
+					{"", "", "https://example.com/page?a=1&b=2"},
+					{"dedupe=true", "my-page", "https://example.com/page?a=1&b=2"},
+					{"dedupe=true", "", "https://example.com/page?a=2&b=1"},
+					{"dedupe=true", "", "http://example.com/page?a=1&b=2"},
+				} {
+					newID, meta := create(v[0], v[1], v[2])
+					Expect(newID).ToNot(Equal(id), v[2])
+					Expect(meta).ToNot(HaveKey("deduplicated"))
+				}
+			})
+		})
+
+		It("API Manages link tags", func() {
+			var newTagRequest = func(name string) *http.Request {
+				data := jsonMustMarshal(map[string]interface{}{
+					"data": map[string]interface{}{
+						"type": "tags",
+						"attributes": map[string]interface{}{
+							"name": name,
+						},
+					},
+				})
+				req, err := http.NewRequest("POST", "/api/tags", bytes.NewReader(data))
+				Expect(err).ToNot(HaveOccurred())
+				return req
+			}
+
+			var newTaggedLinkRequest = func(shortName, originalUri string, tagIDs ...string) *http.Request {
+				tags := make([]interface{}, 0, len(tagIDs))
+				for _, id := range tagIDs {
+					tags = append(tags, map[string]interface{}{"type": "tags", "id": id})
+				}
+				data := jsonMustMarshal(map[string]interface{}{
+					"data": map[string]interface{}{
+						"type": "links",
+						"attributes": map[string]interface{}{
+							"shortName":   shortName,
+							"originalUrl": originalUri,
+							"comment":     "",
+						},
+						"relationships": map[string]interface{}{
+							"tags": map[string]interface{}{
+								"data": tags,
+							},
+						},
+					},
+				})
+				req, err := http.NewRequest("POST", "/api/links", bytes.NewReader(data))
+				Expect(err).ToNot(HaveOccurred())
+				return req
+			}
+
+			var listShortNames = func(url string) (shortNames []string) {
+				rec := httptest.NewRecorder()
+				req, err := http.NewRequest("GET", url, nil)
+				Expect(err).ToNot(HaveOccurred())
+				apiHandler.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusOK))
+
+				m := make(map[string]interface{})
+				err = json.Unmarshal(rec.Body.Bytes(), &m)
+				Expect(err).ToNot(HaveOccurred())
+				for _, item := range m["data"].([]interface{}) {
+					attributes := item.(map[string]interface{})["attributes"].(map[string]interface{})
+					shortNames = append(shortNames, attributes["shortName"].(string))
+				}
+				return shortNames
+			}
+
+			By("Creating tags", func() {
+				for _, name := range []string{"marketing", "internal"} {
+					rec := httptest.NewRecorder()
+					apiHandler.ServeHTTP(rec, newTagRequest(name))
+					Expect(rec.Code).To(Equal(http.StatusCreated))
+				}
+			})
+
+			By("Should fail when creating a tag with an existing name", func() {
+				rec := httptest.NewRecorder()
+				apiHandler.ServeHTTP(rec, newTagRequest("marketing"))
+				Expect(rec.Code).To(Equal(http.StatusBadRequest))
+			})
+
+			By("Creating tagged links", func() {
+				rec := httptest.NewRecorder()
+				apiHandler.ServeHTTP(rec, newTaggedLinkRequest("promo", "https://example.com/promo", "1"))
+				Expect(rec.Code).To(Equal(http.StatusCreated))
+				Expect(rec.Body.String()).To(MatchJSON(`
+			{
+				"data": {
+					"id": "1",
+					"type": "links",
+					"attributes": {
+					  "comment": "",
+					  "originalUrl": "https://example.com/promo",
+					  "shortName": "promo"
+					},
+					"relationships": {
+					  "aliases": {
+					    "data": [],
+					    "links": {
+					      "related": "/api/links/1/aliases",
+					      "self": "/api/links/1/relationships/aliases"
+					    }
+					  },
+					  "tags": {
+					    "data": [{"type": "tags", "id": "1"}],
+					    "links": {
+					      "related": "/api/links/1/tags",
+					      "self": "/api/links/1/relationships/tags"
+					    }
+					  }
+					}
+				}
+			}
+			`))
+
+				rec = httptest.NewRecorder()
+				apiHandler.ServeHTTP(rec, newTaggedLinkRequest("wiki", "https://example.com/wiki", "2"))
+				Expect(rec.Code).To(Equal(http.StatusCreated))
+
+				rec = httptest.NewRecorder()
+				apiHandler.ServeHTTP(rec, newTaggedLinkRequest("untagged", "https://example.com/untagged"))
+				Expect(rec.Code).To(Equal(http.StatusCreated))
+			})
+
+			By("Should fail when tagging a link with a non-existent tag", func() {
+				rec := httptest.NewRecorder()
+				apiHandler.ServeHTTP(rec, newTaggedLinkRequest("broken", "https://example.com/broken", "100500"))
+				Expect(rec.Code).To(Equal(http.StatusBadRequest))
+			})
+
+			By("Should filter links by tag", func() {
+				Expect(listShortNames("/api/links?filter[tag]=marketing")).To(ConsistOf("promo"))
+				Expect(listShortNames("/api/links?filter[tag]=internal")).To(ConsistOf("wiki"))
+				Expect(listShortNames("/api/links?filter[tag]=nonexistent")).To(BeEmpty())
+			})
+
+			By("Should include tags into the response", func() {
+				rec := httptest.NewRecorder()
+				req, err := http.NewRequest("GET", "/api/links/1?include=tags", nil)
+				Expect(err).ToNot(HaveOccurred())
+				apiHandler.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusOK))
+
+				m := make(map[string]interface{})
+				err = json.Unmarshal(rec.Body.Bytes(), &m)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(m).To(HaveKey("included"))
+				Expect(m["included"]).To(HaveLen(1))
+				included := m["included"].([]interface{})[0].(map[string]interface{})
+				Expect(included["type"]).To(Equal("tags"))
+				Expect(included["id"]).To(Equal("1"))
+				Expect(included["attributes"]).To(Equal(map[string]interface{}{"name": "marketing"}))
+			})
+
+			By("Should add a tag via the relationship endpoint", func() {
+				rec := httptest.NewRecorder()
+				data := []byte(`{"data": [{"type": "tags", "id": "2"}]}`)
+				req, err := http.NewRequest("POST", "/api/links/1/relationships/tags", bytes.NewReader(data))
+				Expect(err).ToNot(HaveOccurred())
+				apiHandler.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusNoContent))
+
+				Expect(listShortNames("/api/links?filter[tag]=internal")).To(ConsistOf("promo", "wiki"))
+			})
+
+			By("Should list the tags of a link", func() {
+				rec := httptest.NewRecorder()
+				req, err := http.NewRequest("GET", "/api/links/1/tags", nil)
+				Expect(err).ToNot(HaveOccurred())
+				apiHandler.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusOK))
+
+				m := make(map[string]interface{})
+				err = json.Unmarshal(rec.Body.Bytes(), &m)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(m["data"]).To(HaveLen(2))
+			})
+
+			By("Should detach a deleted tag from the links", func() {
+				before, err := linkStorage.GetOne("1")
+				Expect(err).ToNot(HaveOccurred())
+
+				rec := httptest.NewRecorder()
+				req, err := http.NewRequest("DELETE", "/api/tags/1", nil)
+				Expect(err).ToNot(HaveOccurred())
+				apiHandler.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusNoContent))
+
+				link, err := linkStorage.GetOne("1")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(link.TagIDs).To(Equal([]string{"2"}))
+				// the link has changed, so its ETag must change too
+				Expect(link.Version).To(Equal(before.Version + 1))
+			})
+
+			By("Should treat the tag names as case-sensitive", func() {
+				for _, name := range []string{"Go", "go"} {
+					rec := httptest.NewRecorder()
+					apiHandler.ServeHTTP(rec, newTagRequest(name))
+					Expect(rec.Code).To(Equal(http.StatusCreated), name)
+				}
+
+				rec := httptest.NewRecorder()
+				apiHandler.ServeHTTP(rec, newTaggedLinkRequest("golang", "https://example.com/golang", "3"))
+				Expect(rec.Code).To(Equal(http.StatusCreated))
+				Expect(listShortNames("/api/links?filter[tag]=Go")).To(ConsistOf("golang"))
+				Expect(listShortNames("/api/links?filter[tag]=go")).To(BeEmpty())
+			})
+		})
+
+		It("API Manages link aliases", func() {
+			var newAliasedLinkRequest = func(shortName string, aliases ...string) *http.Request {
+				data := make([]interface{}, 0, len(aliases))
+				for _, alias := range aliases {
+					data = append(data, map[string]interface{}{"type": "aliases", "id": alias})
+				}
+				body := jsonMustMarshal(map[string]interface{}{
+					"data": map[string]interface{}{
+						"type": "links",
+						"attributes": map[string]interface{}{
+							"shortName":   shortName,
+							"originalUrl": "https://example.com/" + shortName,
+							"comment":     "",
+						},
+						"relationships": map[string]interface{}{
+							"aliases": map[string]interface{}{
+								"data": data,
+							},
+						},
+					},
+				})
+				req, err := http.NewRequest("POST", "/api/links", bytes.NewReader(body))
+				Expect(err).ToNot(HaveOccurred())
+				return req
+			}
+
+			var serve = func(method, url, body string) *httptest.ResponseRecorder {
+				rec := httptest.NewRecorder()
+				req, err := http.NewRequest(method, url, strings.NewReader(body))
+				Expect(err).ToNot(HaveOccurred())
+				apiHandler.ServeHTTP(rec, req)
+				return rec
+			}
+
+			By("Creating a link with aliases", func() {
+				rec := httptest.NewRecorder()
+				apiHandler.ServeHTTP(rec, newAliasedLinkRequest("sale", "Sale2026", "promo", "promo", "sale"))
+				Expect(rec.Code).To(Equal(http.StatusCreated))
+				Expect(rec.Body.String()).To(MatchJSON(`
+			{
+				"data": {
+					"id": "1",
+					"type": "links",
+					"attributes": {
+					  "comment": "",
+					  "originalUrl": "https://example.com/sale",
+					  "shortName": "sale"
+					},
+					"relationships": {
+					  "aliases": {
+					    "data": [{"type": "aliases", "id": "Sale2026"}, {"type": "aliases", "id": "promo"}],
+					    "links": {
+					      "related": "/api/links/1/aliases",
+					      "self": "/api/links/1/relationships/aliases"
+					    }
+					  },
+					  "tags": {
+					    "data": [],
+					    "links": {
+					      "related": "/api/links/1/tags",
+					      "self": "/api/links/1/relationships/tags"
+					    }
+					  }
+					}
+				}
+			}
+			`))
+			})
+
+			By("Should redirect by the aliases", func() {
+				for _, path := range []string{"/sale", "/Sale2026", "/promo"} {
+					rec := serve("GET", path, "")
+					Expect(rec.Code).To(Equal(http.StatusMovedPermanently), path)
+					Expect(rec.Header().Get("location")).To(Equal("https://example.com/sale"))
+				}
+			})
+
+			By("Should keep the short names and the aliases unique", func() {
+				for _, req := range []*http.Request{
+					newAliasedLinkRequest("promo"),
+					newAliasedLinkRequest("other", "sale"),
+					newAliasedLinkRequest("other", "Sale2026"),
+				} {
+					rec := httptest.NewRecorder()
+					apiHandler.ServeHTTP(rec, req)
+					Expect(rec.Code).To(Equal(http.StatusBadRequest))
+					Expect(rec.Body.String()).To(ContainSubstring("already used by another link"))
+				}
+			})
+
+			By("Should validate the aliases", func() {
+				rec := httptest.NewRecorder()
+				apiHandler.ServeHTTP(rec, newAliasedLinkRequest("other", "ok", "api"))
+				Expect(rec.Code).To(Equal(http.StatusBadRequest))
+				Expect(rec.Body.String()).To(MatchJSON(`
+			{
+				"errors": [{
+					"id": "Link.Aliases[1]:shortname",
+					"code": "invalid_short_name",
+					"title": "Field validation failed",
+					"detail": "aliases[1] must only contain letters (of the allowed scripts, not mixed), digits and dashes, and must not be (or look like) a reserved name",
+					"source": {"pointer": "/data/relationships/aliases/data/1/id"}
+				}]
+			}
+			`))
+			})
+
+			By("Should add and remove the aliases via the relationship endpoint", func() {
+				rec := serve("POST", "/api/links/1/relationships/aliases", `{"data": [{"type": "aliases", "id": "deal"}]}`)
+				Expect(rec.Code).To(Equal(http.StatusNoContent))
+				rec = serve("DELETE", "/api/links/1/relationships/aliases", `{"data": [{"type": "aliases", "id": "promo"}]}`)
+				Expect(rec.Code).To(Equal(http.StatusNoContent))
+
+				Expect(serve("GET", "/deal", "").Code).To(Equal(http.StatusMovedPermanently))
+				Expect(serve("GET", "/promo", "").Code).To(Equal(http.StatusNotFound))
+
+				// the invalid aliases are not stored (api2go responds before the update though)
+				serve("POST", "/api/links/1/relationships/aliases", `{"data": [{"type": "aliases", "id": "api"}]}`)
+				link, err := linkStorage.GetOne("1")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(link.Aliases).To(Equal([]string{"Sale2026", "deal"}))
+			})
+
+			By("Should list the aliases of a link", func() {
+				rec := serve("GET", "/api/links/1/aliases", "")
+				Expect(rec.Code).To(Equal(http.StatusOK))
+				m := make(map[string]interface{})
+				Expect(json.Unmarshal(rec.Body.Bytes(), &m)).To(Succeed())
+				var ids []string
+				for _, item := range m["data"].([]interface{}) {
+					ids = append(ids, item.(map[string]interface{})["id"].(string))
+				}
+				Expect(ids).To(Equal([]string{"Sale2026", "deal"}))
+
+				Expect(serve("GET", "/api/aliases", "").Code).To(Equal(http.StatusBadRequest))
+			})
+
+			By("Should get an alias", func() {
+				rec := serve("GET", "/api/aliases/deal", "")
+				Expect(rec.Code).To(Equal(http.StatusOK))
+				Expect(rec.Body.String()).To(MatchJSON(`
+			{
+				"data": {
+					"id": "deal",
+					"type": "aliases",
+					"attributes": {},
+					"relationships": {
+					  "link": {
+					    "data": {"type": "links", "id": "1"},
+					    "links": {
+					      "related": "/api/aliases/deal/link",
+					      "self": "/api/aliases/deal/relationships/link"
+					    }
+					  }
+					}
+				}
+			}
+			`))
+
+				rec = serve("GET", "/api/aliases/deal/link", "")
+				Expect(rec.Code).To(Equal(http.StatusOK))
+				m := make(map[string]interface{})
+				Expect(json.Unmarshal(rec.Body.Bytes(), &m)).To(Succeed())
+				Expect(m["data"]).To(HaveKeyWithValue("id", "1"))
+
+				Expect(serve("GET", "/api/aliases/sale", "").Code).To(Equal(http.StatusNotFound))
+				Expect(serve("GET", "/api/aliases/promo", "").Code).To(Equal(http.StatusNotFound))
+			})
+
+			By("Should release the aliases of a deleted link", func() {
+				Expect(serve("DELETE", "/api/links/1", "").Code).To(Equal(http.StatusNoContent))
+				Expect(serve("GET", "/deal", "").Code).To(Equal(http.StatusNotFound))
+
+				rec := httptest.NewRecorder()
+				apiHandler.ServeHTTP(rec, newAliasedLinkRequest("deal", "sale"))
+				Expect(rec.Code).To(Equal(http.StatusCreated))
+			})
+		})
+
+		It("API Applies atomic operations", func() {
+			var operationsRequest = func(ops ...map[string]interface{}) *http.Request {
+				data := jsonMustMarshal(map[string]interface{}{
+					"atomic:operations": ops,
+				})
+				req, err := http.NewRequest("POST", "/api/operations", bytes.NewReader(data))
+				Expect(err).ToNot(HaveOccurred())
+				req.Header.Set("Content-Type", `application/vnd.api+json;ext="https://jsonapi.org/ext/atomic"`)
+				return req
+			}
+			var addOp = func(shortName, originalUri string) map[string]interface{} {
+				return map[string]interface{}{
+					"op": "add",
+					"data": map[string]interface{}{
+						"type": "links",
+						"attributes": map[string]interface{}{
+							"shortName":   shortName,
+							"originalUrl": originalUri,
+						},
+					},
+				}
+			}
+			var countLinks = func() float64 {
+				rec := httptest.NewRecorder()
+				req, err := http.NewRequest("GET", "/api/links", nil)
+				Expect(err).ToNot(HaveOccurred())
+				apiHandler.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusOK))
+				m := make(map[string]interface{})
+				err = json.Unmarshal(rec.Body.Bytes(), &m)
+				Expect(err).ToNot(HaveOccurred())
+				return m["meta"].(map[string]interface{})["links"].(float64)
+			}
+
+			By("Applying a mixed batch", func() {
+				rec := httptest.NewRecorder()
+				req := newLinkRequest("old", "https://example.com/old", "")
+				apiHandler.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusCreated))
+
+				rec = httptest.NewRecorder()
+				req = operationsRequest(
+					addOp("first", "https://example.com/first"),
+					addOp("second", "https://example.com/second"),
+					map[string]interface{}{
+						"op": "update",
+						"data": map[string]interface{}{
+							"type": "links",
+							"id":   "1",
+							"attributes": map[string]interface{}{
+								"comment": "updated in a batch",
+							},
+						},
+					},
+				)
+				apiHandler.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusOK))
+				Expect(rec.Header().Get("Content-Type")).To(ContainSubstring("https://jsonapi.org/ext/atomic"))
+
+				m := make(map[string]interface{})
+				err := json.Unmarshal(rec.Body.Bytes(), &m)
+				Expect(err).ToNot(HaveOccurred())
+				results := m["atomic:results"].([]interface{})
+				Expect(results).To(HaveLen(3))
+				var attributes = func(i int) map[string]interface{} {
+					data := results[i].(map[string]interface{})["data"].(map[string]interface{})
+					return data["attributes"].(map[string]interface{})
+				}
+				Expect(attributes(0)["shortName"]).To(Equal("first"))
+				Expect(attributes(1)["shortName"]).To(Equal("second"))
+				Expect(attributes(2)["shortName"]).To(Equal("old"))
+				Expect(attributes(2)["comment"]).To(Equal("updated in a batch"))
+				Expect(countLinks()).To(Equal(float64(3)))
+			})
+
+			By("Removing links", func() {
+				rec := httptest.NewRecorder()
+				req := operationsRequest(map[string]interface{}{
+					"op":  "remove",
+					"ref": map[string]interface{}{"type": "links", "id": "1"},
+				})
+				apiHandler.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusNoContent))
+				Expect(countLinks()).To(Equal(float64(2)))
+			})
+
+			By("Rolling back the whole batch on a storage failure", func() {
+				rec := httptest.NewRecorder()
+				req := operationsRequest(
+					addOp("third", "https://example.com/third"),
+					addOp("first", "https://example.com/duplicate"),
+				)
+				apiHandler.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusBadRequest))
+				Expect(rec.Body.String()).To(ContainSubstring(`"pointer":"/atomic:operations/1"`))
+				Expect(countLinks()).To(Equal(float64(2)))
+
+				rec = httptest.NewRecorder()
+				req, err := http.NewRequest("GET", "/third", nil)
+				Expect(err).ToNot(HaveOccurred())
+				apiHandler.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusNotFound))
+			})
+
+			By("Rejecting invalid operations", func() {
+				rec := httptest.NewRecorder()
+				req := operationsRequest(
+					addOp("fourth", "https://example.com/fourth"),
+					addOp("fifth", "ftp://example.com/fifth"),
+				)
+				apiHandler.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusBadRequest))
+				Expect(rec.Body.String()).To(ContainSubstring(`"pointer":"/atomic:operations/1/data/attributes/originalUrl"`))
+				Expect(rec.Body.String()).To(ContainSubstring(`"code":"unsupported_url_scheme"`))
+				Expect(countLinks()).To(Equal(float64(2)))
+			})
+		})
+
+		It("API Rejects the changes of the links changed in between", func() {
+			var serve = func(req *http.Request, ifMatch string) *httptest.ResponseRecorder {
+				if ifMatch != "" {
+					req.Header.Set("If-Match", ifMatch)
+				}
+				rec := httptest.NewRecorder()
+				apiHandler.ServeHTTP(rec, req)
+				return rec
+			}
+			var get = func() *httptest.ResponseRecorder {
+				req, err := http.NewRequest("GET", "/api/links/1", nil)
+				Expect(err).ToNot(HaveOccurred())
+				return serve(req, "")
+			}
+			var deleteRequest = func() *http.Request {
+				req, err := http.NewRequest("DELETE", "/api/links/1", nil)
+				Expect(err).ToNot(HaveOccurred())
+				return req
+			}
+
+			By("Creating a link", func() {
+				rec := serve(newLinkRequest("my-cool-link", "https://example.com/my-cool-link", ""), "")
+				Expect(rec.Code).To(Equal(http.StatusCreated))
+				Expect(rec.Header().Get("ETag")).To(Equal(`"1"`))
+			})
+
+			By("Getting the link version", func() {
+				rec := get()
+				Expect(rec.Code).To(Equal(http.StatusOK))
+				Expect(rec.Header().Get("ETag")).To(Equal(`"1"`))
+			})
+
+			By("Updating the link with the current version", func() {
+				rec := serve(updateLinkRequest("1", "my-cool-link", "https://example.com/my-cool-link", "first"), `"1"`)
+				Expect(rec.Code).To(Equal(http.StatusOK))
+				Expect(rec.Header().Get("ETag")).To(Equal(`"2"`))
+				Expect(get().Header().Get("ETag")).To(Equal(`"2"`))
+			})
+
+			By("Rejecting the update with the outdated version", func() {
+				rec := serve(updateLinkRequest("1", "my-cool-link", "https://example.com/my-cool-link", "second"), `"1"`)
+				Expect(rec.Code).To(Equal(http.StatusPreconditionFailed))
+				Expect(rec.Body.String()).To(MatchJSON(`
+			{
+				"errors": [{"status": "412", "title": "precondition failed"}]
+			}`))
+
+				rec = serve(updateLinkRequest("1", "my-cool-link", "https://example.com/my-cool-link", "second"), `W/"2"`)
+				Expect(rec.Code).To(Equal(http.StatusPreconditionFailed), "the weak entity tags never match")
+
+				rec = get()
+				Expect(rec.Body.String()).To(ContainSubstring(`"comment":"first"`))
+			})
+
+			By("Updating the link with any of the versions or without one", func() {
+				rec := serve(updateLinkRequest("1", "my-cool-link", "https://example.com/my-cool-link", "second"), `"1", "2"`)
+				Expect(rec.Code).To(Equal(http.StatusOK))
+				rec = serve(updateLinkRequest("1", "my-cool-link", "https://example.com/my-cool-link", "third"), `*`)
+				Expect(rec.Code).To(Equal(http.StatusOK))
+				rec = serve(updateLinkRequest("1", "my-cool-link", "https://example.com/my-cool-link", "fourth"), "")
+				Expect(rec.Code).To(Equal(http.StatusOK))
+				Expect(rec.Header().Get("ETag")).To(Equal(`"5"`))
+			})
+
+			By("Updating the link changed after it was read without a version", func() {
+				// the link is updated right after api2go reads it to apply the changes
+				racing := &racingLinkStorage{Storage: linkStorage, afterGetOne: func() {
+					link, err := linkStorage.GetOne("1")
+					Expect(err).ToNot(HaveOccurred())
+					updated := *link
+					updated.Comment = "concurrent"
+					Expect(linkStorage.Update(updated)).To(Succeed())
+				}}
+				racingHandler := server.NewEcho(racing, tagStorage, status, nil, nil, nil).Server.Handler
+				rec := httptest.NewRecorder()
+				racingHandler.ServeHTTP(rec, updateLinkRequest("1", "my-cool-link", "https://example.com/my-cool-link", "fifth"))
+				Expect(rec.Code).To(Equal(http.StatusOK))
+				Expect(rec.Header().Get("ETag")).To(Equal(`"7"`))
+
+				rec = get()
+				Expect(rec.Header().Get("ETag")).To(Equal(`"7"`))
+				Expect(rec.Body.String()).To(ContainSubstring(`"comment":"fifth"`))
+			})
+
+			By("Rejecting the deletion with the outdated version", func() {
+				rec := serve(deleteRequest(), `"4"`)
+				Expect(rec.Code).To(Equal(http.StatusPreconditionFailed))
+				Expect(get().Code).To(Equal(http.StatusOK))
+			})
+
+			By("Rejecting the deletion of the link changed after it was read", func() {
+				// the link is updated right after the resource reads it for the If-Match check
+				racing := &racingLinkStorage{Storage: linkStorage, afterGetOne: func() {
+					link, err := linkStorage.GetOne("1")
+					Expect(err).ToNot(HaveOccurred())
+					updated := *link
+					updated.Comment = "concurrent"
+					Expect(linkStorage.Update(updated)).To(Succeed())
+				}}
+				racingHandler := server.NewEcho(racing, tagStorage, status, nil, nil, nil).Server.Handler
+				rec := httptest.NewRecorder()
+				req := deleteRequest()
+				req.Header.Set("If-Match", `"7"`)
+				racingHandler.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusPreconditionFailed))
+
+				rec = get()
+				Expect(rec.Code).To(Equal(http.StatusOK))
+				Expect(rec.Body.String()).To(ContainSubstring(`"comment":"concurrent"`))
+			})
+
+			By("Deleting the link with the current version", func() {
+				rec := serve(deleteRequest(), `"8"`)
+				Expect(rec.Code).To(Equal(http.StatusNoContent))
+				Expect(get().Code).To(Equal(http.StatusNotFound))
+			})
+		})
+
 		It("API Deletes links", func() {
 			By("Creating a link", func() {
 				rec := httptest.NewRecorder()
@@ -520,6 +1402,7 @@ var _ = Describe("Functional Tests", func() {
 				Expect(err).ToNot(HaveOccurred())
 				apiHandler.ServeHTTP(rec, req)
 				Expect(rec.Code).To(Equal(http.StatusNotFound))
+				Expect(rec.Body.String()).To(MatchJSON(`{"errors": [{"status": "404", "title": "resource not found"}]}`))
 			})
 
 			By("Should fail to delete a deleted link", func() {
@@ -609,7 +1492,7 @@ var _ = Describe("Functional Tests", func() {
 				OriginalURL: "https://example.com/my-cool-link",
 			})
 			Expect(err).ToNot(HaveOccurred())
-			handler = shortener.Handler(linkStorage)
+			handler = shortener.Handler(linkStorage, nil)
 			router = echo.New()
 			router.GET("/*", handler)
 		})
@@ -635,4 +1518,167 @@ var _ = Describe("Functional Tests", func() {
 			})
 		})
 	})
+
+	When("Probing the health", func() {
+		var probe = func(path string) (int, server.HealthResponse) {
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", path, nil)
+			Expect(err).ToNot(HaveOccurred())
+			apiHandler.ServeHTTP(rec, req)
+			var response server.HealthResponse
+			Expect(json.Unmarshal(rec.Body.Bytes(), &response)).To(Succeed())
+			return rec.Code, response
+		}
+
+		It("Should report liveness", func() {
+			code, response := probe("/healthz")
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(response.Status).To(Equal(server.HealthStatusOK))
+			Expect(response.Checks).To(BeEmpty())
+		})
+
+		It("Should report readiness with the individual checks", func() {
+			code, response := probe("/readyz")
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(response.Status).To(Equal(server.HealthStatusOK))
+			Expect(response.Checks).To(HaveLen(3))
+			for i, name := range []string{"storage", "migrations", "draining"} {
+				Expect(response.Checks[i].Name).To(Equal(name))
+				Expect(response.Checks[i].Status).To(Equal(server.HealthStatusOK))
+				Expect(response.Checks[i].Error).To(BeEmpty())
+			}
+
+			status.StartDraining()
+			code, response = probe("/readyz")
+			Expect(code).To(Equal(http.StatusServiceUnavailable))
+			Expect(response.Status).To(Equal(server.HealthStatusFail))
+			Expect(response.Checks[2].Status).To(Equal(server.HealthStatusFail))
+			Expect(response.Checks[2].Error).To(Equal("the server is shutting down"))
+
+			code, _ = probe("/healthz")
+			Expect(code).To(Equal(http.StatusOK))
+		})
+	})
+
+	When("Using a separate admin listener", func() {
+		var public, admin *echo.Echo
+
+		BeforeEach(func() {
+			admin = server.NewAdminEcho()
+			public = server.NewEcho(linkStorage, tagStorage, status, admin, nil, nil)
+		})
+
+		var get = func(e *echo.Echo, path string) int {
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", path, nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.RequestURI = path // swagger handler matches it
+			e.ServeHTTP(rec, req)
+			return rec.Code
+		}
+
+		It("Should serve the admin routes on the admin listener only", func() {
+			for _, path := range []string{"/metrics", "/healthz", "/readyz", "/swagger/index.html", "/debug/pprof/", "/debug/pprof/heap"} {
+				Expect(get(admin, path)).To(Equal(http.StatusOK), path)
+			}
+			// the names are free for the links on the api listener
+			for _, path := range []string{"/metrics", "/healthz", "/readyz", "/swagger/index.html", "/debug/pprof/"} {
+				Expect(get(public, path)).To(Equal(http.StatusNotFound), path)
+			}
+			Expect(get(public, "/api/links")).To(Equal(http.StatusOK))
+			Expect(get(admin, "/api/links")).To(Equal(http.StatusNotFound))
+		})
+
+		It("Should reserve the short names of the routes", func() {
+			Expect(server.ReservedShortNames(public)).To(Equal([]string{"api"}))
+			Expect(server.ReservedShortNames(server.NewEcho(linkStorage, tagStorage, status, nil, nil, nil))).To(
+				Equal([]string{"api", "healthz", "metrics", "readyz", "swagger"}),
+			)
+		})
+	})
+
+	When("Rate limiting", func() {
+		var e *echo.Echo
+
+		BeforeEach(func() {
+			limits := server.NewRateLimits(
+				ratelimit.Policy{Rate: 0.01, Burst: 2},
+				ratelimit.Policy{Rate: 0.01, Burst: 3},
+				false,
+			)
+			e = server.NewEcho(linkStorage, tagStorage, status, nil, limits, nil)
+		})
+
+		var request = func(method, path, ip, apiKey string, body []byte) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequest(method, path, bytes.NewReader(body))
+			Expect(err).ToNot(HaveOccurred())
+			req.RemoteAddr = ip + ":12345"
+			if apiKey != "" {
+				req.Header.Set(server.APIKeyHeader, apiKey)
+			}
+			e.ServeHTTP(rec, req)
+			return rec
+		}
+
+		It("Should limit the api writes by ip address and api key", func() {
+			link := func(shortName string) []byte {
+				return jsonMustMarshal(map[string]interface{}{
+					"data": map[string]interface{}{
+						"type":       "links",
+						"attributes": map[string]interface{}{"shortName": shortName, "originalUrl": "https://example.com/"},
+					},
+				})
+			}
+			Expect(request("POST", "/api/links", "10.0.0.1", "", link("a1")).Code).To(Equal(http.StatusCreated))
+			Expect(request("POST", "/api/links", "10.0.0.1", "key", link("a2")).Code).To(Equal(http.StatusCreated))
+			rec := request("POST", "/api/links", "10.0.0.1", "", link("a3"))
+			Expect(rec.Code).To(Equal(http.StatusTooManyRequests))
+			Expect(rec.Header().Get("Retry-After")).To(MatchRegexp("^[0-9]+$"))
+			Expect(rec.Header().Get("Content-Type")).To(Equal("application/vnd.api+json"))
+			var m map[string]interface{}
+			Expect(json.Unmarshal(rec.Body.Bytes(), &m)).To(Succeed())
+			Expect(m["errors"]).To(HaveLen(1))
+			Expect(m["errors"].([]interface{})[0]).To(HaveKeyWithValue("status", "429"))
+
+			// the reads are not limited
+			Expect(request("GET", "/api/links", "10.0.0.1", "", nil).Code).To(Equal(http.StatusOK))
+			// the other addresses have their own limits, unless they use the same api key
+			Expect(request("POST", "/api/links", "10.0.0.2", "key", link("a4")).Code).To(Equal(http.StatusCreated))
+			Expect(request("POST", "/api/links", "10.0.0.3", "key", link("a5")).Code).To(Equal(http.StatusTooManyRequests))
+			Expect(request("POST", "/api/links", "10.0.0.3", "", link("a5")).Code).To(Equal(http.StatusCreated))
+			// the requests over the api key limit don't use up the ip address limit
+			Expect(request("POST", "/api/links", "10.0.0.3", "", link("a6")).Code).To(Equal(http.StatusCreated))
+			Expect(request("POST", "/api/links", "10.0.0.3", "", link("a7")).Code).To(Equal(http.StatusTooManyRequests))
+		})
+
+		It("Should limit the redirects", func() {
+			_, err := linkStorage.Insert(model.Link{ShortName: "limited", OriginalURL: "https://example.com/"})
+			Expect(err).ToNot(HaveOccurred())
+			for i := 0; i < 3; i++ {
+				Expect(request("GET", "/limited", "10.0.0.1", "", nil).Code).To(Equal(http.StatusMovedPermanently))
+			}
+			rec := request("GET", "/limited", "10.0.0.1", "", nil)
+			Expect(rec.Code).To(Equal(http.StatusTooManyRequests))
+			Expect(rec.Header().Get("Retry-After")).ToNot(BeEmpty())
+			Expect(request("GET", "/limited", "10.0.0.2", "", nil).Code).To(Equal(http.StatusMovedPermanently))
+		})
+	})
+
+	When("Draining", func() {
+		It("Should keep serving and ask the clients to close the connections", func() {
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", "/api/links", nil)
+			Expect(err).ToNot(HaveOccurred())
+			apiHandler.ServeHTTP(rec, req)
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Header().Get("Connection")).To(BeEmpty())
+
+			status.StartDraining()
+			rec = httptest.NewRecorder()
+			apiHandler.ServeHTTP(rec, req)
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Header().Get("Connection")).To(Equal("close"))
+		})
+	})
 })
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"sync/atomic"
+)
+
+// Status holds the state of the server that matters to the load balancers and the orchestrator
+type Status struct {
+	draining int32
+}
+
+// StartDraining marks the server as draining, i.e. it's about to shut down and shouldn't get new requests
+func (s *Status) StartDraining() {
+	atomic.StoreInt32(&s.draining, 1)
+}
+
+// Draining tells whether the server is draining
+func (s *Status) Draining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
@@ -1,9 +1,15 @@
 package shortener
 
 import (
+	"fmt"
+	"html"
+
 	"github.com/denisvmedia/urlshortener/metrics"
+	"github.com/denisvmedia/urlshortener/model"
 	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/denisvmedia/urlshortener/validator"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/labstack/echo/v4"
@@ -52,17 +58,94 @@ const pageError = `
     </body>
 </html>`
 
-// Handler Handle short link redirection
-func Handler(linkStorage linkstorage.Storage) echo.HandlerFunc {
+// pageWarning is shown instead of redirecting to a malicious or private destination, the destination is not a link on purpose
+const pageWarning = `
+<!doctype html>
+<html class="no-js" lang="en">
+    <head>
+        <meta charset="utf-8">
+        <meta http-equiv="x-ua-compatible" content="IE=edge,chrome=1">
+        <meta name="viewport" content="width=device-width, initial-scale=1">
+        <title>Warning - %s</title>
+        <meta name="robots" content="noindex">
+        <link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/twitter-bootstrap/3.3.7/css/bootstrap.min.css">
+        <style>
+          h1.error {
+            margin-top: 1em;
+            font-size: 7em;
+            font-weight: 500;
+          }
+        </style>
+    </head>
+    <body>
+        <div class="container">
+            <div class="row">
+                <div class="col-md-10 col-md-offset-2">
+                    <h1 class="error">Warning!</h1>
+                    <h2>%s</h2>
+                    <p class="lead">%s</p>
+                    <p><code>%s</code></p>
+                </div>
+            </div>
+        </div>
+    </body>
+</html>`
+
+// warning describes why a link is not followed
+type warning struct {
+	title   string
+	heading string
+	lead    string
+}
+
+var linkFlagged = warning{
+	title:   "the destination of the link is reported as malicious",
+	heading: "This link leads to a site reported as malicious",
+	lead:    "The destination of the link was found in a list of phishing and malware sites, so you are not redirected to it.",
+}
+
+var linkPrivate = warning{
+	title:   "the destination of the link is not a public address",
+	heading: "This link leads to a private network",
+	lead:    "The destination of the link is (or currently resolves to) an internal address, so you are not redirected to it.",
+}
+
+var negotiatedContentTypes = []string{"text/plain", "text/html", "application/json", "application/vnd.api+json"}
+
+// FlaggedLinks tells whether the destination of a link was found to be malicious (see blocklist.Scanner)
+type FlaggedLinks interface {
+	Flagged(link *model.Link) (reason string, flagged bool)
+}
+
+// Handler Handle short link redirection, the links flagged as malicious (flagged may be nil) and, if the private
+// destinations are denied, the links whose hosts currently resolve to non-public addresses (see
+// validator.CheckRedirectDestination) get a warning instead
+func Handler(linkStorage linkstorage.Storage, flagged FlaggedLinks) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		shortName := strings.Trim(ctx.Param("*"), "/ ")
+		// echo matches the raw path if it's not the default encoding of the decoded one
+		// (e.g. lowercase percent-encoded non-ascii chars), the storage normalizes the decoded name
+		if ctx.Request().URL.RawPath != "" {
+			if unescaped, err := url.PathUnescape(shortName); err == nil {
+				shortName = unescaped
+			}
+		}
 		link, err := linkStorage.GetOneByShortName(shortName)
 		if err == nil {
+			if flagged != nil {
+				if _, ok := flagged.Flagged(link); ok {
+					return warn(ctx, link, linkFlagged)
+				}
+			}
+			// checked again on redirect, as the dns records may have changed since the link was created
+			if validator.CheckRedirectDestination(ctx.Request().Context(), link.OriginalURL) != nil {
+				return warn(ctx, link, linkPrivate)
+			}
 			metrics.RequestProcessed.WithLabelValues("301").Inc()
 			return ctx.Redirect(http.StatusMovedPermanently, link.OriginalURL)
 		}
 
-		contentType := httputil.NegotiateContentType(ctx.Request(), []string{"text/plain", "text/html", "application/json", "application/vnd.api+json"}, "")
+		contentType := httputil.NegotiateContentType(ctx.Request(), negotiatedContentTypes, "")
 		metrics.RequestProcessed.WithLabelValues("404").Inc()
 
 		switch contentType {
@@ -80,3 +163,20 @@ func Handler(linkStorage linkstorage.Storage) echo.HandlerFunc {
 		return ctx.String(404, resourceNotFound)
 	}
 }
+
+// warn responds with a warning about the destination of the link
+func warn(ctx echo.Context, link *model.Link, w warning) error {
+	metrics.RequestProcessed.WithLabelValues("403").Inc()
+	switch httputil.NegotiateContentType(ctx.Request(), negotiatedContentTypes, "") {
+	case "application/json", "application/vnd.api+json":
+		return ctx.JSON(http.StatusForbidden, map[string]interface{}{
+			"errors": map[string]interface{}{
+				"status": http.StatusForbidden,
+				"title":  w.title,
+			},
+		})
+	case "text/html":
+		return ctx.HTML(http.StatusForbidden, fmt.Sprintf(pageWarning, w.heading, w.heading, w.lead, html.EscapeString(link.OriginalURL)))
+	}
+	return ctx.String(http.StatusForbidden, w.title)
+}
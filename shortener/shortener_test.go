@@ -1,10 +1,13 @@
 package shortener_test
 
 import (
+	"context"
 	"github.com/denisvmedia/urlshortener/model"
 	"github.com/denisvmedia/urlshortener/shortener"
 	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/denisvmedia/urlshortener/validator"
 	"github.com/labstack/echo/v4"
+	"net"
 	"net/http"
 	"net/http/httptest"
 
@@ -12,6 +15,20 @@ import (
 	. "github.com/onsi/gomega"
 )
 
+// flaggedShortNames flags the links by their short names
+type flaggedShortNames map[string]bool
+
+func (f flaggedShortNames) Flagged(link *model.Link) (string, bool) {
+	return "test", f[link.ShortName]
+}
+
+// failingResolver fails all the lookups, like a resolver timing out
+type failingResolver struct{}
+
+func (failingResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	return nil, &net.DNSError{Err: "i/o timeout", Name: host, IsTimeout: true}
+}
+
 var _ = Describe("Functional Tests", func() {
 	var linkStorage linkstorage.Storage
 	var handler echo.HandlerFunc
@@ -24,7 +41,7 @@ var _ = Describe("Functional Tests", func() {
 			OriginalURL: "https://example.com/my-cool-link",
 		})
 		Expect(err).ToNot(HaveOccurred())
-		handler = shortener.Handler(linkStorage)
+		handler = shortener.Handler(linkStorage, nil)
 		router = echo.New()
 		router.GET("/*", handler)
 	})
@@ -40,6 +57,98 @@ var _ = Describe("Functional Tests", func() {
 		})
 	})
 
+	When("Link has a unicode short name", func() {
+		BeforeEach(func() {
+			_, err := linkStorage.Insert(model.Link{
+				ShortName:   "café",
+				OriginalURL: "https://example.com/cafe",
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Should redirect by the percent-encoded short name in any normalization form", func() {
+			for _, path := range []string{"/caf%C3%A9", "/caf%c3%a9", "/cafe%CC%81", "/café"} {
+				rec := httptest.NewRecorder()
+				req, err := http.NewRequest("GET", path, nil)
+				Expect(err).ToNot(HaveOccurred())
+				router.ServeHTTP(rec, req)
+				Expect(rec.Code).To(Equal(http.StatusMovedPermanently), path)
+				Expect(rec.Header().Get("location")).To(Equal("https://example.com/cafe"), path)
+			}
+		})
+	})
+
+	When("Link with given shortname is flagged as malicious", func() {
+		BeforeEach(func() {
+			router = echo.New()
+			router.GET("/*", shortener.Handler(linkStorage, flaggedShortNames{"my-cool-link": true}))
+		})
+
+		It("Should show a warning instead of redirecting", func() {
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", "/my-cool-link", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Accept", "text/html")
+			router.ServeHTTP(rec, req)
+			Expect(rec.Code).To(Equal(http.StatusForbidden))
+			Expect(rec.Header().Get("location")).To(BeEmpty())
+			Expect(rec.Body.String()).To(ContainSubstring("reported as malicious"))
+			Expect(rec.Body.String()).To(ContainSubstring("<code>https://example.com/my-cool-link</code>"))
+		})
+	})
+
+	When("Link destination is not a public address", func() {
+		BeforeEach(func() {
+			_, err := linkStorage.Insert(model.Link{
+				ShortName:   "metadata",
+				OriginalURL: "http://169.254.169.254/latest/meta-data/",
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			validator.SetDenyPrivateDestinations(false)
+		})
+
+		It("Should redirect if the private destinations are allowed", func() {
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", "/metadata", nil)
+			Expect(err).ToNot(HaveOccurred())
+			router.ServeHTTP(rec, req)
+			Expect(rec.Code).To(Equal(http.StatusMovedPermanently))
+		})
+
+		It("Should refuse to redirect if the private destinations are denied", func() {
+			validator.SetDenyPrivateDestinations(true)
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", "/metadata", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Accept", "application/json")
+			router.ServeHTTP(rec, req)
+			Expect(rec.Code).To(Equal(http.StatusForbidden))
+			Expect(rec.Header().Get("location")).To(BeEmpty())
+			Expect(rec.Body.String()).To(ContainSubstring("not a public address"))
+		})
+
+		It("Should redirect if the destination host can't be resolved", func() {
+			validator.SetDenyPrivateDestinations(true)
+			validator.SetResolver(failingResolver{})
+			defer validator.SetResolver(net.DefaultResolver)
+			_, err := linkStorage.Insert(model.Link{
+				ShortName:   "unresolved",
+				OriginalURL: "https://example.com/unresolved",
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", "/unresolved", nil)
+			Expect(err).ToNot(HaveOccurred())
+			router.ServeHTTP(rec, req)
+			Expect(rec.Code).To(Equal(http.StatusMovedPermanently))
+			Expect(rec.Header().Get("location")).To(Equal("https://example.com/unresolved"))
+		})
+	})
+
 	When("Link with given shortname does not exist", func() {
 		It("Should return Not Found", func() {
 			rec := httptest.NewRecorder()
@@ -0,0 +1,150 @@
+package shortname
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-extras/errors"
+)
+
+// Base62 is the default alphabet of the generated short names
+const Base62 = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// Base36 is the alphabet of the generated short names if the case of the short names is ignored
+const Base36 = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// validAlphabetChars are the chars allowed in the short names (see validator.ValidateURLShortName)
+const validAlphabetChars = Base62 + "-"
+
+// Generator generates the short names of the links created without one, it must be safe for concurrent use
+type Generator interface {
+	Generate() (string, error)
+}
+
+// Growable is implemented by the generators whose short names can be made longer, so that they are less likely
+// to be used already (the sequential generators don't need it, their next short names are never used by them)
+type Growable interface {
+	// GenerateLonger generates a short name longer than the usual ones by the given number of chars or words
+	GenerateLonger(extra int) (string, error)
+}
+
+// checkAlphabet makes sure the alphabet only consists of unique chars allowed in the short names
+func checkAlphabet(alphabet string) error {
+	if len(alphabet) < 2 {
+		return errors.New("the alphabet must have at least 2 chars")
+	}
+	seen := make(map[rune]bool, len(alphabet))
+	for _, c := range alphabet {
+		if !strings.ContainsRune(validAlphabetChars, c) {
+			return errors.Errorf("the alphabet char %q is not allowed in the short names", c)
+		}
+		if seen[c] {
+			return errors.Errorf("the alphabet char %q is repeated", c)
+		}
+		seen[c] = true
+	}
+
+	return nil
+}
+
+// randomIndex returns a uniformly distributed random number in [0, n)
+func randomIndex(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+
+	return int(i.Int64()), nil
+}
+
+// Random generates random short names of the given length
+type Random struct {
+	alphabet string
+	length   int
+}
+
+// NewRandom creates a generator of random short names of the alphabet chars (e.g. Base62)
+func NewRandom(alphabet string, length int) (*Random, error) {
+	if err := checkAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	if length < 1 {
+		return nil, errors.New("the length must be positive")
+	}
+
+	return &Random{
+		alphabet: alphabet,
+		length:   length,
+	}, nil
+}
+
+// Generate implements Generator
+func (r *Random) Generate() (string, error) {
+	return r.generate(r.length)
+}
+
+// GenerateLonger implements Growable
+func (r *Random) GenerateLonger(extra int) (string, error) {
+	return r.generate(r.length + extra)
+}
+
+func (r *Random) generate(length int) (string, error) {
+	b := make([]byte, length)
+	for i := range b {
+		j, err := randomIndex(len(r.alphabet))
+		if err != nil {
+			return "", err
+		}
+		b[i] = r.alphabet[j]
+	}
+
+	return string(b), nil
+}
+
+// Counter generates sequential short names, i.e. the numbers of a counter encoded with the alphabet
+type Counter struct {
+	alphabet string
+	next     uint64
+}
+
+// NewCounter creates a generator that starts counting from the given number
+func NewCounter(alphabet string, start uint64) (*Counter, error) {
+	if err := checkAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+
+	return &Counter{
+		alphabet: alphabet,
+		next:     start,
+	}, nil
+}
+
+// encode writes the number in the positional system with the alphabet chars as the digits
+func encode(n uint64, alphabet string) string {
+	base := uint64(len(alphabet))
+	var b []byte
+	for {
+		b = append(b, alphabet[n%base])
+		n /= base
+		if n == 0 {
+			break
+		}
+	}
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+
+	return string(b)
+}
+
+// take returns the next number of the counter
+func (c *Counter) take() uint64 {
+	return atomic.AddUint64(&c.next, 1) - 1
+}
+
+// Generate implements Generator
+func (c *Counter) Generate() (string, error) {
+	return encode(c.take(), c.alphabet), nil
+}
@@ -0,0 +1,89 @@
+package shortname_test
+
+import (
+	"github.com/denisvmedia/urlshortener/shortname"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Generators", func() {
+	// generate returns n short names, all of them must be valid
+	var generate = func(g shortname.Generator, n int) []string {
+		names := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			name, err := g.Generate()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(name).To(MatchRegexp(`^[a-zA-Z0-9\-]+$`))
+			names = append(names, name)
+		}
+		return names
+	}
+
+	It("generates random base62 short names of the given length", func() {
+		g, err := shortname.NewRandom(shortname.Base62, 12)
+		Expect(err).ToNot(HaveOccurred())
+		names := generate(g, 100)
+		for _, name := range names {
+			Expect(name).To(HaveLen(12))
+		}
+		Expect(names[0]).ToNot(Equal(names[1]))
+
+		longer, err := g.GenerateLonger(2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(longer).To(HaveLen(14))
+
+		_, err = shortname.NewRandom(shortname.Base62, 0)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("generates sequential short names with the alphabet", func() {
+		g, err := shortname.NewCounter("01", 5)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(generate(g, 4)).To(Equal([]string{"101", "110", "111", "1000"}))
+
+		g, err = shortname.NewCounter(shortname.Base62, 61)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(generate(g, 2)).To(Equal([]string{"Z", "10"}))
+
+		_, err = shortname.NewCounter("a", 0)
+		Expect(err).To(MatchError(ContainSubstring("at least 2 chars")))
+		_, err = shortname.NewCounter("abca", 0)
+		Expect(err).To(MatchError(ContainSubstring("repeated")))
+		_, err = shortname.NewCounter("ab_", 0)
+		Expect(err).To(MatchError(ContainSubstring("not allowed")))
+	})
+
+	It("generates obfuscated sequential short names", func() {
+		g, err := shortname.NewHashids(shortname.Base62, "my salt", 6, 0)
+		Expect(err).ToNot(HaveOccurred())
+		names := generate(g, 1000)
+		seen := make(map[string]bool)
+		for _, name := range names {
+			Expect(len(name)).To(BeNumerically(">=", 6))
+			Expect(seen).ToNot(HaveKey(name))
+			seen[name] = true
+		}
+
+		// the same salt gives the same names, another one gives different names
+		same, err := shortname.NewHashids(shortname.Base62, "my salt", 6, 0)
+		Expect(err).ToNot(HaveOccurred())
+		other, err := shortname.NewHashids(shortname.Base62, "other salt", 6, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(same.Encode(42)).To(Equal(g.Encode(42)))
+		Expect(other.Encode(42)).ToNot(Equal(g.Encode(42)))
+	})
+
+	It("generates pronounceable short names", func() {
+		g, err := shortname.NewWords(3)
+		Expect(err).ToNot(HaveOccurred())
+		for _, name := range generate(g, 100) {
+			Expect(name).To(MatchRegexp(`^[a-z]+-[a-z]+-[a-z]+$`))
+		}
+		longer, err := g.GenerateLonger(1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(longer).To(MatchRegexp(`^[a-z]+-[a-z]+-[a-z]+-[a-z]+$`))
+
+		_, err = shortname.NewWords(0)
+		Expect(err).To(HaveOccurred())
+	})
+})
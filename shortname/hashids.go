@@ -0,0 +1,78 @@
+package shortname
+
+import (
+	"github.com/go-extras/errors"
+)
+
+// Hashids generates obfuscated sequential short names in the way of Hashids (https://hashids.org):
+// the numbers of a counter are encoded with an alphabet shuffled by a salt, so that the short names
+// don't reveal the number of the links
+type Hashids struct {
+	counter   *Counter
+	alphabet  string
+	salt      string
+	minLength int
+}
+
+// NewHashids creates a generator of the short names of the alphabet chars (e.g. Base62) that starts counting
+// from the given number, the short names are padded up to minLength chars
+func NewHashids(alphabet, salt string, minLength int, start uint64) (*Hashids, error) {
+	if minLength < 0 {
+		return nil, errors.New("the min length must not be negative")
+	}
+	counter, err := NewCounter(alphabet, start)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Hashids{
+		counter:   counter,
+		alphabet:  consistentShuffle(alphabet, salt),
+		salt:      salt,
+		minLength: minLength,
+	}, nil
+}
+
+// consistentShuffle shuffles the alphabet in the same way for the same salt
+func consistentShuffle(alphabet, salt string) string {
+	if salt == "" {
+		return alphabet
+	}
+
+	result := []byte(alphabet)
+	for i, v, p := len(result)-1, 0, 0; i > 0; i-- {
+		v %= len(salt)
+		integer := int(salt[v])
+		p += integer
+		j := (integer + v + p) % i
+		result[i], result[j] = result[j], result[i]
+		v++
+	}
+
+	return string(result)
+}
+
+// Encode returns the short name of the number
+func (h *Hashids) Encode(n uint64) string {
+	alphabet := h.alphabet
+	// the lottery char makes the alphabet differ from number to number
+	lottery := alphabet[n%uint64(len(alphabet))]
+	alphabet = consistentShuffle(alphabet, (string(lottery) + h.salt + alphabet)[:len(alphabet)])
+	result := string(lottery) + encode(n, alphabet)
+
+	for len(result) < h.minLength {
+		alphabet = consistentShuffle(alphabet, alphabet)
+		half := len(alphabet) / 2
+		result = alphabet[half:] + result + alphabet[:half]
+		if excess := len(result) - h.minLength; excess > 0 {
+			result = result[excess/2 : excess/2+h.minLength]
+		}
+	}
+
+	return result
+}
+
+// Generate implements Generator
+func (h *Hashids) Generate() (string, error) {
+	return h.Encode(h.counter.take()), nil
+}
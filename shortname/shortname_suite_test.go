@@ -0,0 +1,13 @@
+package shortname_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestShortName(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ShortName Suite")
+}
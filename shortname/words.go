@@ -0,0 +1,79 @@
+package shortname
+
+import (
+	"strings"
+
+	"github.com/go-extras/errors"
+)
+
+// adjectives and nouns are short, common and easy to spell words
+var adjectives = []string{
+	"able", "bold", "brave", "bright", "brisk", "calm", "clean", "clever",
+	"cool", "cosy", "crisp", "curly", "daring", "dear", "deep", "eager",
+	"early", "easy", "fair", "fancy", "fast", "fine", "first", "fluffy",
+	"fond", "free", "fresh", "friendly", "funny", "gentle", "glad", "golden",
+	"good", "grand", "great", "green", "happy", "hardy", "honest", "humble",
+	"jolly", "keen", "kind", "large", "lively", "lucky", "merry", "mighty",
+	"modest", "neat", "nice", "noble", "olive", "polite", "proud", "quick",
+	"quiet", "rapid", "ready", "rosy", "royal", "shiny", "silent", "silver",
+	"simple", "sleek", "smart", "snowy", "soft", "solid", "sunny", "super",
+	"sweet", "swift", "tall", "tidy", "tiny", "true", "vivid", "warm",
+	"wise", "witty", "young", "zesty",
+}
+
+var nouns = []string{
+	"apple", "badger", "beach", "bear", "bird", "breeze", "brook", "cactus",
+	"canyon", "cedar", "cloud", "comet", "coral", "crane", "daisy", "dolphin",
+	"dune", "eagle", "falcon", "fern", "field", "finch", "forest", "fox",
+	"garden", "glacier", "grove", "harbor", "hawk", "heron", "hill", "island",
+	"koala", "lake", "lemon", "lily", "lion", "lotus", "maple", "meadow",
+	"moon", "moose", "mountain", "ocean", "orchid", "otter", "owl", "panda",
+	"parrot", "peach", "pebble", "pine", "planet", "pond", "rabbit", "raven",
+	"reef", "river", "robin", "rocket", "sparrow", "spruce", "star", "stone",
+	"storm", "sun", "swan", "tiger", "tulip", "turtle", "valley", "wave",
+	"whale", "willow", "wind", "wolf", "zebra",
+}
+
+// Words generates pronounceable short names of random words joined with dashes, the last word
+// is a noun and the others are adjectives (e.g. brave-quiet-otter)
+type Words struct {
+	count int
+}
+
+// NewWords creates a generator of short names of the given number of words
+func NewWords(count int) (*Words, error) {
+	if count < 1 {
+		return nil, errors.New("the number of words must be positive")
+	}
+
+	return &Words{
+		count: count,
+	}, nil
+}
+
+// Generate implements Generator
+func (w *Words) Generate() (string, error) {
+	return w.generate(w.count)
+}
+
+// GenerateLonger implements Growable, the extra words are adjectives
+func (w *Words) GenerateLonger(extra int) (string, error) {
+	return w.generate(w.count + extra)
+}
+
+func (w *Words) generate(count int) (string, error) {
+	words := make([]string, count)
+	for i := range words {
+		list := adjectives
+		if i == len(words)-1 {
+			list = nouns
+		}
+		j, err := randomIndex(len(list))
+		if err != nil {
+			return "", err
+		}
+		words[i] = list[j]
+	}
+
+	return strings.Join(words, "-"), nil
+}
@@ -7,6 +7,18 @@ var (
 	ErrNotFound = errors.New("not found")
 	// ErrShortNameAlreadyExists is returned when a link already exists in the storage
 	ErrShortNameAlreadyExists = errors.New("given short name is already used by another link")
+	// ErrShortNameConfusable is returned when a short name looks like the one of another link
+	ErrShortNameConfusable = errors.New("given short name looks like the one of another link")
+	// ErrShortNameNotAllocated is returned when no unused short name could be generated for a link
+	ErrShortNameNotAllocated = errors.New("no unused short name could be generated")
+	// ErrRedirectLoop is returned when a link leads back to itself through the short links of the service
+	ErrRedirectLoop = errors.New("the link leads back to itself through the short links")
+	// ErrRedirectChainTooLong is returned when a link leads through too many short links of the service
+	ErrRedirectChainTooLong = errors.New("the link leads through too many short links")
+	// ErrVersionMismatch is returned when a link has been changed since the given version was read
+	ErrVersionMismatch = errors.New("the link has been changed by another request")
+	// ErrTagNameAlreadyExists is returned when a tag with the same name already exists in the storage
+	ErrTagNameAlreadyExists = errors.New("given tag name is already used by another tag")
 	// ErrStorageFailure is returned in case of a storage problem
 	ErrStorageFailure = errors.New("storage failure")
 )
@@ -0,0 +1,237 @@
+package linkstorage
+
+import (
+	"github.com/denisvmedia/urlshortener/metrics"
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/shortname"
+	"github.com/denisvmedia/urlshortener/storage"
+	"github.com/denisvmedia/urlshortener/validator"
+	"github.com/go-extras/errors"
+)
+
+// Default Allocator settings
+const (
+	DefaultMaxAttempts = 10
+	DefaultGrowAfter   = 3
+)
+
+// Allocator stores the links given without a short name with a generated one (see model.CurrentShortNameGenerator).
+// A generated short name that is already used or reserved is replaced with another one, and after GrowAfter
+// such collisions the short names get longer if the generator supports it (see shortname.Growable).
+// The links given with a short name are stored with it unless it (or an alias of the link) looks like the one
+// of another link (see validator.ShortNameSkeleton). The redirect chains of all the links are checked (and flattened
+// if configured) before storing them, see FollowChain.
+type Allocator struct {
+	Storage Storage
+	// MaxAttempts limits the number of the short names generated for a single link
+	MaxAttempts int
+	// GrowAfter is the number of the collisions after which each next short name gets one more char (or word)
+	GrowAfter int
+}
+
+// NewAllocator creates an Allocator with the default settings
+func NewAllocator(s Storage) *Allocator {
+	return &Allocator{
+		Storage:     s,
+		MaxAttempts: DefaultMaxAttempts,
+		GrowAfter:   DefaultGrowAfter,
+	}
+}
+
+// allocation generates the short names of a single link
+type allocation struct {
+	allocator  *Allocator
+	generator  model.ShortNameGenerator
+	collisions int
+}
+
+func (a *Allocator) newAllocation() *allocation {
+	return &allocation{
+		allocator: a,
+		generator: model.CurrentShortNameGenerator(),
+	}
+}
+
+// next generates a short name that isn't reserved
+func (al *allocation) next() (string, error) {
+	for {
+		if al.collisions >= al.allocator.MaxAttempts {
+			return "", errors.Wrapf(storage.ErrShortNameNotAllocated, "%d generated short names collided", al.collisions)
+		}
+
+		var shortName string
+		var err error
+		growable, ok := al.generator.(shortname.Growable)
+		if extra := al.collisions - al.allocator.GrowAfter + 1; ok && al.allocator.GrowAfter > 0 && extra > 0 {
+			shortName, err = growable.GenerateLonger(extra)
+		} else {
+			shortName, err = al.generator.Generate()
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "can't generate short name")
+		}
+		metrics.ShortNamesGenerated.Inc()
+
+		// e.g. the counters would sooner or later generate "api"
+		if !validator.IsReservedShortName(shortName) {
+			return shortName, nil
+		}
+		al.collide("reserved")
+	}
+}
+
+func (al *allocation) collide(reason string) {
+	al.collisions++
+	metrics.ShortNameCollisions.WithLabelValues(reason).Inc()
+}
+
+// prepare normalizes the link and checks it before storing
+func (a *Allocator) prepare(link *model.Link) error {
+	normalizeLink(link)
+	if err := a.checkConfusable(*link); err != nil {
+		return err
+	}
+
+	return FollowChain(a.Storage, link)
+}
+
+// checkConfusable fails if the given short name or an alias is a look-alike of the short name (or an alias)
+// of another link, e.g. "pаypal" with the Cyrillic "а" of "paypal" (the other way round is not detected)
+func (a *Allocator) checkConfusable(link model.Link) error {
+	for _, name := range shortNames(&link) {
+		if name == "" {
+			continue
+		}
+		skeleton := validator.ShortNameSkeleton(name)
+		if skeleton == name {
+			continue
+		}
+		existing, err := a.Storage.GetOneByShortName(skeleton)
+		if errors.Cause(err) == storage.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if existing.ID == link.ID {
+			continue
+		}
+
+		return errors.Wrapf(storage.ErrShortNameConfusable, "%s looks like %s of link id %s", name, skeleton, existing.ID)
+	}
+
+	return nil
+}
+
+// Check normalizes and checks the link the same way Insert and Update do without storing it
+func (a *Allocator) Check(link model.Link) error {
+	return a.prepare(&link)
+}
+
+// allocate calls store until it succeeds with a generated short name of the link (if the link has no short name)
+func (a *Allocator) allocate(link *model.Link, store func() error) error {
+	if link.ShortName != "" {
+		return store()
+	}
+
+	al := a.newAllocation()
+	for {
+		shortName, err := al.next()
+		if err != nil {
+			return err
+		}
+		link.ShortName = shortName
+
+		err = store()
+		if errors.Cause(err) != storage.ErrShortNameAlreadyExists {
+			return err
+		}
+		al.collide("used")
+	}
+}
+
+// Insert implements Storage.Insert generating the short name if it's empty
+func (a *Allocator) Insert(link model.Link) (*model.Link, error) {
+	if err := a.prepare(&link); err != nil {
+		return nil, err
+	}
+
+	var result *model.Link
+	err := a.allocate(&link, func() error {
+		var err error
+		result, err = a.Storage.Insert(link)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Update implements Storage.Update generating the short name if it's empty,
+// the updated link is returned as the short name and the version may have changed
+// (the version stays zero if it was not given, as it's not known then)
+func (a *Allocator) Update(link model.Link) (*model.Link, error) {
+	if err := a.prepare(&link); err != nil {
+		return nil, err
+	}
+
+	err := a.allocate(&link, func() error {
+		return a.Storage.Update(link)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if link.Version != 0 {
+		link.Version++
+	}
+
+	return &link, nil
+}
+
+// Batch implements Storage.Batch generating the short names of the added and updated links if they are empty,
+// the batch is retried with another short name if a generated one collides. The redirect chains are checked
+// against the links stored before the batch.
+func (a *Allocator) Batch(ops []Operation) ([]*model.Link, error) {
+	// the caller's operations are not modified
+	ops = append([]Operation(nil), ops...)
+	allocations := make(map[int]*allocation)
+	for i := range ops {
+		if ops[i].Kind == OperationRemove {
+			continue
+		}
+		if err := a.prepare(&ops[i].Link); err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		if ops[i].Link.ShortName != "" {
+			continue
+		}
+		al := a.newAllocation()
+		shortName, err := al.next()
+		if err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		ops[i].Link.ShortName = shortName
+		allocations[i] = al
+	}
+
+	for {
+		results, err := a.Storage.Batch(ops)
+		batchErr, ok := err.(*BatchError)
+		if !ok || errors.Cause(batchErr.Err) != storage.ErrShortNameAlreadyExists {
+			return results, err
+		}
+		al, generated := allocations[batchErr.Index]
+		if !generated {
+			return results, err
+		}
+
+		al.collide("used")
+		shortName, err := al.next()
+		if err != nil {
+			return nil, &BatchError{Index: batchErr.Index, Err: err}
+		}
+		ops[batchErr.Index].Link.ShortName = shortName
+	}
+}
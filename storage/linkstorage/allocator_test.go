@@ -0,0 +1,320 @@
+package linkstorage_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/shortname"
+	"github.com/denisvmedia/urlshortener/storage"
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/denisvmedia/urlshortener/validator"
+	"github.com/go-extras/errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// sequenceGenerator generates the given short names one by one, the longer ones get an "x" suffix per extra char
+type sequenceGenerator struct {
+	names []string
+	extra []int
+}
+
+func (g *sequenceGenerator) Generate() (string, error) {
+	return g.GenerateLonger(0)
+}
+
+func (g *sequenceGenerator) GenerateLonger(extra int) (string, error) {
+	if len(g.names) == 0 {
+		return "", errors.New("no more names")
+	}
+	name := g.names[0]
+	g.names = g.names[1:]
+	g.extra = append(g.extra, extra)
+
+	return name + strings.Repeat("x", extra), nil
+}
+
+var _ = Describe("Allocator", func() {
+	var s linkstorage.Storage
+	var allocator *linkstorage.Allocator
+	var generator *sequenceGenerator
+	defaultGenerator := model.CurrentShortNameGenerator()
+
+	AfterEach(func() {
+		model.SetShortNameGenerator(defaultGenerator)
+	})
+
+	BeforeEach(func() {
+		s = linkstorage.NewInMemoryStorage()
+		allocator = linkstorage.NewAllocator(s)
+		generator = &sequenceGenerator{}
+		model.SetShortNameGenerator(generator)
+		for _, shortName := range []string{"used1", "used2", "used3x", "used4xx"} {
+			_, err := s.Insert(model.Link{ShortName: shortName, OriginalURL: "https://example.com/"})
+			Expect(err).ToNot(HaveOccurred())
+		}
+	})
+
+	It("keeps the given short names", func() {
+		_, err := allocator.Insert(model.Link{ShortName: "used1", OriginalURL: "https://example.com/"})
+		Expect(errors.Cause(err)).To(Equal(storage.ErrShortNameAlreadyExists))
+		Expect(generator.extra).To(BeEmpty())
+	})
+
+	It("replaces the used and reserved generated short names", func() {
+		generator.names = []string{"used1", "api", "free"}
+		link, err := allocator.Insert(model.Link{OriginalURL: "https://example.com/"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(link.ShortName).To(Equal("free"))
+	})
+
+	It("generates longer short names after repeated collisions", func() {
+		allocator.GrowAfter = 2
+		// the grown short names are used as well
+		generator.names = []string{"used1", "used2", "used3", "used4", "free"}
+		link, err := allocator.Insert(model.Link{OriginalURL: "https://example.com/"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(link.ShortName).To(Equal("freexxx"))
+		Expect(generator.extra).To(Equal([]int{0, 0, 1, 2, 3}))
+	})
+
+	It("gives up after the max attempts", func() {
+		allocator.MaxAttempts = 3
+		generator.names = []string{"used1", "used2", "used1", "free"}
+		_, err := allocator.Insert(model.Link{OriginalURL: "https://example.com/"})
+		Expect(errors.Cause(err)).To(Equal(storage.ErrShortNameNotAllocated))
+	})
+
+	It("generates the short names of the updated links", func() {
+		existing, err := s.GetOneByShortName("used1")
+		Expect(err).ToNot(HaveOccurred())
+		link := *existing
+		link.ShortName = ""
+		generator.names = []string{"used2", "free"}
+
+		updated, err := allocator.Update(link)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(updated.ShortName).To(Equal("free"))
+		stored, err := s.GetOne(link.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.ShortName).To(Equal("free"))
+	})
+
+	It("retries the batches with the colliding generated short names", func() {
+		generator.names = []string{"free1", "used2", "free2"}
+		ops := []linkstorage.Operation{
+			{Kind: linkstorage.OperationAdd, Link: model.Link{OriginalURL: "https://example.com/1"}},
+			{Kind: linkstorage.OperationAdd, Link: model.Link{ShortName: "given", OriginalURL: "https://example.com/2"}},
+			{Kind: linkstorage.OperationAdd, Link: model.Link{OriginalURL: "https://example.com/3"}},
+		}
+		links, err := allocator.Batch(ops)
+		Expect(err).ToNot(HaveOccurred())
+		var shortNames []string
+		for _, link := range links {
+			shortNames = append(shortNames, link.ShortName)
+		}
+		Expect(shortNames).To(Equal([]string{"free1", "given", "free2"}))
+		Expect(ops[0].Link.ShortName).To(BeEmpty(), "the operations must not be modified")
+
+		ops = []linkstorage.Operation{
+			{Kind: linkstorage.OperationAdd, Link: model.Link{OriginalURL: "https://example.com/4"}},
+			{Kind: linkstorage.OperationAdd, Link: model.Link{ShortName: "given", OriginalURL: "https://example.com/5"}},
+		}
+		generator.names = []string{"free3"}
+		_, err = allocator.Batch(ops)
+		Expect(err).To(BeAssignableToTypeOf(&linkstorage.BatchError{}))
+		Expect(err.(*linkstorage.BatchError).Index).To(Equal(1))
+		Expect(errors.Cause(err)).To(Equal(storage.ErrShortNameAlreadyExists), fmt.Sprint(err))
+	})
+
+	It("stores the normalized short names and urls", func() {
+		created, err := allocator.Insert(model.Link{ShortName: "cafe\u0301", OriginalURL: "https://Bücher.example/päth"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created.ShortName).To(Equal("café"))
+		Expect(created.OriginalURL).To(Equal("https://xn--bcher-kva.example/p%C3%A4th"))
+
+		stored, err := s.GetOneByShortName("cafe\u0301")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.ID).To(Equal(created.ID))
+
+		_, err = allocator.Insert(model.Link{ShortName: "café", OriginalURL: "https://example.com/"})
+		Expect(errors.Cause(err)).To(Equal(storage.ErrShortNameAlreadyExists))
+
+		updated := *stored
+		updated.OriginalURL = "https://пример.рф/"
+		result, err := allocator.Update(updated)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.OriginalURL).To(Equal("https://xn--e1afmkfd.xn--p1ai/"))
+	})
+
+	It("rejects the look-alikes of the other short names", func() {
+		paypal, err := allocator.Insert(model.Link{ShortName: "paypal", OriginalURL: "https://example.com/1"})
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = allocator.Insert(model.Link{ShortName: "раураl", OriginalURL: "https://example.com/2"})
+		Expect(errors.Cause(err)).To(Equal(storage.ErrShortNameConfusable), fmt.Sprint(err))
+		Expect(err).To(MatchError(ContainSubstring("раураl looks like paypal of link id " + paypal.ID)))
+
+		_, err = allocator.Batch([]linkstorage.Operation{
+			{Kind: linkstorage.OperationAdd, Link: model.Link{ShortName: "рауpal", OriginalURL: "https://example.com/3"}},
+		})
+		Expect(errors.Cause(err)).To(Equal(storage.ErrShortNameConfusable), fmt.Sprint(err))
+
+		_, err = allocator.Insert(model.Link{ShortName: "привет", OriginalURL: "https://example.com/4"})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("keeps the short names and the aliases unique", func() {
+		sale, err := allocator.Insert(model.Link{ShortName: "sale", Aliases: []string{"promo", "cafe\u0301", "promo", "sale"}, OriginalURL: "https://example.com/"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sale.Aliases).To(Equal([]string{"promo", "café"}))
+
+		for _, shortName := range []string{"sale", "promo", "café"} {
+			found, err := s.GetOneByShortName(shortName)
+			Expect(err).ToNot(HaveOccurred(), shortName)
+			Expect(found.ID).To(Equal(sale.ID))
+		}
+
+		for _, link := range []model.Link{
+			{ShortName: "promo"},
+			{ShortName: "other", Aliases: []string{"sale"}},
+			{ShortName: "other", Aliases: []string{"used1"}},
+		} {
+			link.OriginalURL = "https://example.com/"
+			_, err = allocator.Insert(link)
+			Expect(errors.Cause(err)).To(Equal(storage.ErrShortNameAlreadyExists), link.ShortName)
+		}
+		_, err = s.GetOneByShortName("other")
+		Expect(errors.Cause(err)).To(Equal(storage.ErrNotFound))
+
+		_, err = allocator.Insert(model.Link{ShortName: "other", Aliases: []string{"рromo"}, OriginalURL: "https://example.com/"})
+		Expect(errors.Cause(err)).To(Equal(storage.ErrShortNameConfusable), fmt.Sprint(err))
+
+		// the removed aliases are released
+		updated := *sale
+		Expect(updated.DeleteToManyIDs(model.AliasesRelationship, []string{"promo"})).To(Succeed())
+		Expect(updated.AddToManyIDs(model.AliasesRelationship, []string{"deal"})).To(Succeed())
+		_, err = allocator.Update(updated)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = s.GetOneByShortName("promo")
+		Expect(errors.Cause(err)).To(Equal(storage.ErrNotFound))
+		created, err := allocator.Insert(model.Link{ShortName: "promo", OriginalURL: "https://example.com/"})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(s.Delete(sale.ID)).To(Succeed())
+		_, err = s.GetOneByShortName("deal")
+		Expect(errors.Cause(err)).To(Equal(storage.ErrNotFound))
+		_, err = s.Batch([]linkstorage.Operation{
+			{Kind: linkstorage.OperationUpdate, Link: model.Link{ID: created.ID, ShortName: "promo", Aliases: []string{"deal"}, OriginalURL: "https://example.com/"}},
+			{Kind: linkstorage.OperationAdd, Link: model.Link{ShortName: "deal", OriginalURL: "https://example.com/"}},
+		})
+		Expect(errors.Cause(err)).To(Equal(storage.ErrShortNameAlreadyExists))
+		_, err = s.GetOneByShortName("deal")
+		Expect(errors.Cause(err)).To(Equal(storage.ErrNotFound), "the failed batch must be rolled back")
+	})
+
+	It("versions the links and rejects the updates of the changed ones", func() {
+		link, err := allocator.Insert(model.Link{ShortName: "versioned", OriginalURL: "https://example.com/"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(link.Version).To(Equal(1))
+
+		first, second := *link, *link
+		first.Comment = "first"
+		updated, err := allocator.Update(first)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(updated.Version).To(Equal(2))
+
+		second.Comment = "second"
+		_, err = allocator.Update(second)
+		Expect(errors.Cause(err)).To(Equal(storage.ErrVersionMismatch))
+		_, err = s.Batch([]linkstorage.Operation{{Kind: linkstorage.OperationUpdate, Link: second}})
+		Expect(errors.Cause(err)).To(Equal(storage.ErrVersionMismatch))
+
+		stored, err := s.GetOne(link.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.Comment).To(Equal("first"))
+		Expect(stored.Version).To(Equal(2))
+
+		// the links without a version are updated unconditionally
+		second.Version = 0
+		Expect(s.Update(second)).To(Succeed())
+		stored, err = s.GetOne(link.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.Comment).To(Equal("second"))
+		Expect(stored.Version).To(Equal(3))
+
+		err = s.DeleteVersion(link.ID, 2)
+		Expect(errors.Cause(err)).To(Equal(storage.ErrVersionMismatch))
+		_, err = s.GetOne(link.ID)
+		Expect(err).ToNot(HaveOccurred(), "the changed link must not be deleted")
+		Expect(s.DeleteVersion(link.ID, 3)).To(Succeed())
+		err = s.DeleteVersion(link.ID, 3)
+		Expect(errors.Cause(err)).To(Equal(storage.ErrNotFound))
+	})
+
+	It("seeds the counters past the short names of the deleted links", func() {
+		var newCounter = func() model.ShortNameGenerator {
+			lastID, err := s.LastID()
+			Expect(err).ToNot(HaveOccurred())
+			counter, err := shortname.NewCounter(shortname.Base62, uint64(lastID))
+			Expect(err).ToNot(HaveOccurred())
+			return counter
+		}
+
+		model.SetShortNameGenerator(newCounter())
+		for _, expected := range []string{"4", "5", "6"} {
+			link, err := allocator.Insert(model.Link{OriginalURL: "https://example.com/"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(link.ShortName).To(Equal(expected))
+		}
+
+		// the number of the links goes down, but the counter must not start inside the used range
+		Expect(s.Delete("1")).To(Succeed())
+		_, total, err := s.PaginatedGetAll(1, 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(total).To(Equal(6))
+
+		model.SetShortNameGenerator(newCounter())
+		link, err := allocator.Insert(model.Link{OriginalURL: "https://example.com/"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(link.ShortName).To(Equal("7"))
+	})
+
+	Context("with case-insensitive short names", func() {
+		BeforeEach(func() {
+			validator.SetCaseInsensitiveShortNames(true)
+		})
+
+		AfterEach(func() {
+			validator.SetCaseInsensitiveShortNames(false)
+		})
+
+		It("stores and looks up the short names case-folded", func() {
+			created, err := allocator.Insert(model.Link{ShortName: "Hello-World", OriginalURL: "https://example.com/"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(created.ShortName).To(Equal("hello-world"))
+
+			for _, shortName := range []string{"hello-world", "HELLO-WORLD", "Hello-World"} {
+				stored, err := s.GetOneByShortName(shortName)
+				Expect(err).ToNot(HaveOccurred(), shortName)
+				Expect(stored.ID).To(Equal(created.ID))
+			}
+
+			_, err = allocator.Insert(model.Link{ShortName: "HELLO-world", OriginalURL: "https://example.com/"})
+			Expect(errors.Cause(err)).To(Equal(storage.ErrShortNameAlreadyExists))
+
+			_, err = allocator.Insert(model.Link{ShortName: "PayPal", OriginalURL: "https://example.com/"})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = allocator.Insert(model.Link{ShortName: "РАУРАL", OriginalURL: "https://example.com/"})
+			Expect(errors.Cause(err)).To(Equal(storage.ErrShortNameConfusable), fmt.Sprint(err))
+		})
+
+		It("replaces the generated short names colliding after folding", func() {
+			generator.names = []string{"USED1", "Fresh"}
+			created, err := allocator.Insert(model.Link{OriginalURL: "https://example.com/"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(created.ShortName).To(Equal("fresh"))
+		})
+	})
+})
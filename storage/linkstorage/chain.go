@@ -0,0 +1,65 @@
+package linkstorage
+
+import (
+	"strings"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/storage"
+	"github.com/denisvmedia/urlshortener/validator"
+	"github.com/go-extras/errors"
+)
+
+// FollowChain follows the short links of the service itself the link leads to (see validator.RedirectChains),
+// the chain is only checked against the stored links. It fails if the chain loops (back to the link or not)
+// or if it's longer than the configured depth, and it replaces the original url of the link with the final
+// destination if the chains are flattened.
+func FollowChain(s Storage, link *model.Link) error {
+	chains := validator.CurrentRedirectChains()
+	destination := link.OriginalURL
+	// the short name of a new link is generated later, so nothing can lead to it yet
+	var chain []string
+	visited := make(map[string]bool)
+	if link.ShortName != "" {
+		chain = append(chain, link.ShortName)
+		visited[link.ShortName] = true
+	}
+	// the aliases lead to the link as well
+	for _, alias := range link.Aliases {
+		visited[validator.NormalizeShortName(alias)] = true
+	}
+	for depth := 1; ; depth++ {
+		shortName, ok := chains.SelfShortName(destination)
+		if !ok {
+			break
+		}
+		shortName = validator.NormalizeShortName(shortName)
+		chain = append(chain, shortName)
+		if visited[shortName] {
+			return errors.Wrapf(storage.ErrRedirectLoop, "%s", strings.Join(chain, " -> "))
+		}
+		if depth > chains.MaxDepth {
+			return errors.Wrapf(storage.ErrRedirectChainTooLong, "%s (max %d)", strings.Join(chain, " -> "), chains.MaxDepth)
+		}
+		visited[shortName] = true
+
+		next, err := s.GetOneByShortName(shortName)
+		if errors.Cause(err) == storage.ErrNotFound {
+			// e.g. a route of the service, the redirects end there
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if link.ID != "" && next.ID == link.ID {
+			// the old short name (or a removed alias) of the link being renamed, nothing will be found there after the update
+			break
+		}
+		destination = next.OriginalURL
+	}
+
+	if chains.Flatten {
+		link.OriginalURL = destination
+	}
+
+	return nil
+}
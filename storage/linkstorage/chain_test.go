@@ -0,0 +1,150 @@
+package linkstorage_test
+
+import (
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/storage"
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/denisvmedia/urlshortener/validator"
+	"github.com/go-extras/errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FollowChain", func() {
+	var s linkstorage.Storage
+
+	insert := func(shortName, originalURL string) *model.Link {
+		link, err := s.Insert(model.Link{ShortName: shortName, OriginalURL: originalURL})
+		Expect(err).ToNot(HaveOccurred())
+		return link
+	}
+
+	setChains := func(maxDepth int, flatten bool) {
+		Expect(validator.SetRedirectChains(validator.RedirectChains{
+			Hosts:    []string{"sho.rt", "Go.Example.com:8080"},
+			MaxDepth: maxDepth,
+			Flatten:  flatten,
+		})).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		s = linkstorage.NewInMemoryStorage()
+		insert("a", "https://sho.rt/b")
+		insert("b", "http://go.example.com:8080/c/")
+		insert("c", "https://example.com/final")
+		setChains(3, false)
+	})
+
+	AfterEach(func() {
+		Expect(validator.SetRedirectChains(validator.RedirectChains{MaxDepth: validator.DefaultRedirectChainDepth})).To(Succeed())
+	})
+
+	It("Should leave the links to the other hosts as they are", func() {
+		for _, originalURL := range []string{
+			"https://example.com/a",
+			"https://sho.rt.example.com/a",
+			"http://go.example.com:8081/a",
+			"https://sho.rt/",
+			"https://sho.rt/unknown",
+		} {
+			link := model.Link{ShortName: "new", OriginalURL: originalURL}
+			Expect(linkstorage.FollowChain(s, &link)).To(Succeed(), originalURL)
+			Expect(link.OriginalURL).To(Equal(originalURL))
+		}
+	})
+
+	It("Should accept the chains up to the max depth", func() {
+		link := model.Link{OriginalURL: "https://SHO.RT./a?utm=1"}
+		Expect(linkstorage.FollowChain(s, &link)).To(Succeed())
+		Expect(link.OriginalURL).To(Equal("https://SHO.RT./a?utm=1"))
+
+		setChains(2, false)
+		err := linkstorage.FollowChain(s, &link)
+		Expect(errors.Cause(err)).To(Equal(storage.ErrRedirectChainTooLong))
+		Expect(err).To(MatchError(ContainSubstring("a -> b -> c (max 2)")))
+
+		setChains(0, false)
+		link = model.Link{OriginalURL: "https://sho.rt/c"}
+		Expect(errors.Cause(linkstorage.FollowChain(s, &link))).To(Equal(storage.ErrRedirectChainTooLong))
+	})
+
+	It("Should reject the loops", func() {
+		link := model.Link{ShortName: "c", OriginalURL: "https://sho.rt/a"}
+		err := linkstorage.FollowChain(s, &link)
+		Expect(errors.Cause(err)).To(Equal(storage.ErrRedirectLoop))
+		Expect(err).To(MatchError(ContainSubstring("c -> a -> b -> c")))
+
+		link = model.Link{ShortName: "self", OriginalURL: "https://sho.rt/self"}
+		Expect(errors.Cause(linkstorage.FollowChain(s, &link))).To(Equal(storage.ErrRedirectLoop))
+
+		// a loop of the stored links the new link leads to
+		insert("x", "https://sho.rt/y")
+		insert("y", "https://sho.rt/x")
+		link = model.Link{OriginalURL: "https://sho.rt/x"}
+		Expect(errors.Cause(linkstorage.FollowChain(s, &link))).To(Equal(storage.ErrRedirectLoop))
+	})
+
+	It("Should reject the loops through the aliases", func() {
+		link := model.Link{ShortName: "d", Aliases: []string{"e"}, OriginalURL: "https://sho.rt/e"}
+		Expect(errors.Cause(linkstorage.FollowChain(s, &link))).To(Equal(storage.ErrRedirectLoop))
+
+		c, err := s.GetOneByShortName("c")
+		Expect(err).ToNot(HaveOccurred())
+		aliased := *c
+		aliased.Aliases = []string{"final"}
+		_, err = s.Batch([]linkstorage.Operation{{Kind: linkstorage.OperationUpdate, Link: aliased}})
+		Expect(err).ToNot(HaveOccurred())
+		link = model.Link{ShortName: "d", OriginalURL: "https://sho.rt/final"}
+		Expect(linkstorage.FollowChain(s, &link)).To(Succeed())
+
+		aliased.OriginalURL = "https://sho.rt/final"
+		Expect(errors.Cause(linkstorage.FollowChain(s, &aliased))).To(Equal(storage.ErrRedirectLoop))
+	})
+
+	It("Should not follow the old short name of a renamed link", func() {
+		c, err := s.GetOneByShortName("c")
+		Expect(err).ToNot(HaveOccurred())
+		renamed := *c
+		renamed.ShortName = "d"
+		renamed.OriginalURL = "https://sho.rt/a"
+		Expect(linkstorage.FollowChain(s, &renamed)).To(Succeed())
+	})
+
+	It("Should flatten the chains if configured", func() {
+		setChains(3, true)
+		link := model.Link{OriginalURL: "https://sho.rt/a"}
+		Expect(linkstorage.FollowChain(s, &link)).To(Succeed())
+		Expect(link.OriginalURL).To(Equal("https://example.com/final"))
+
+		link = model.Link{OriginalURL: "https://sho.rt/unknown"}
+		Expect(linkstorage.FollowChain(s, &link)).To(Succeed())
+		Expect(link.OriginalURL).To(Equal("https://sho.rt/unknown"))
+	})
+
+	It("Should be applied by the allocator", func() {
+		allocator := linkstorage.NewAllocator(s)
+		_, err := allocator.Insert(model.Link{ShortName: "c2", OriginalURL: "https://sho.rt/c2"})
+		Expect(errors.Cause(err)).To(Equal(storage.ErrRedirectLoop))
+
+		c, err := s.GetOneByShortName("c")
+		Expect(err).ToNot(HaveOccurred())
+		updated := *c
+		updated.OriginalURL = "https://sho.rt/a"
+		_, err = allocator.Update(updated)
+		Expect(errors.Cause(err)).To(Equal(storage.ErrRedirectLoop))
+
+		_, err = allocator.Batch([]linkstorage.Operation{
+			{Kind: linkstorage.OperationAdd, Link: model.Link{ShortName: "ok", OriginalURL: "https://example.com/"}},
+			{Kind: linkstorage.OperationUpdate, Link: updated},
+		})
+		batchErr, ok := err.(*linkstorage.BatchError)
+		Expect(ok).To(BeTrue())
+		Expect(batchErr.Index).To(Equal(1))
+		Expect(errors.Cause(batchErr.Err)).To(Equal(storage.ErrRedirectLoop))
+
+		setChains(3, true)
+		created, err := allocator.Insert(model.Link{ShortName: "flat", OriginalURL: "https://sho.rt/b"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created.OriginalURL).To(Equal("https://example.com/final"))
+	})
+})
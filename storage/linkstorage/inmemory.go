@@ -1,13 +1,16 @@
 package linkstorage
 
 import (
+	"context"
 	"fmt"
 	"github.com/denisvmedia/urlshortener/storage"
 	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/validator"
 	"github.com/go-extras/errors"
 )
 
@@ -33,6 +36,7 @@ func NewInMemoryStorage() Storage {
 		linksByShortName: make(map[string]*model.Link),
 		linksByID:        make([]*model.Link, 0),
 		idCount:          0,
+		index:            NewInvertedIndex(),
 	}
 }
 
@@ -43,6 +47,7 @@ type InMemoryStorage struct {
 	linksByShortName map[string]*model.Link
 	linksByID        []*model.Link
 	idCount          int64
+	index            SearchIndex
 	lock             sync.RWMutex
 }
 
@@ -57,6 +62,47 @@ func (s *InMemoryStorage) PaginatedGetAll(pageNumber, pageSize int) (results []*
 	return results, len(s.linksByID), nil
 }
 
+// PaginatedFind returns a slice of links matching the filter according to desired pagination
+// and total number of matching items
+func (s *InMemoryStorage) PaginatedFind(filter Filter, pageNumber, pageSize int) (results []*model.Link, total int, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var candidates []*model.Link
+	if filter.Query != "" {
+		hits := s.index.Search(filter.Query)
+		candidates = make([]*model.Link, 0, len(hits))
+		for _, hit := range hits {
+			candidates = append(candidates, s.links[hit.ID])
+		}
+	} else {
+		candidates = s.linksByID
+	}
+
+	matches := make([]*model.Link, 0, len(candidates))
+	for _, link := range candidates {
+		if filter.TagID != "" && !hasTag(link, filter.TagID) {
+			continue
+		}
+		matches = append(matches, link)
+	}
+
+	start, end := storage.SlicePaginate(pageNumber-1, pageSize, len(matches))
+	results = matches[start:end]
+
+	return results, len(matches), nil
+}
+
+func hasTag(link *model.Link, tagID string) bool {
+	for _, id := range link.TagIDs {
+		if id == tagID {
+			return true
+		}
+	}
+
+	return false
+}
+
 // GetOne link
 func (s *InMemoryStorage) GetOne(id string) (*model.Link, error) {
 	s.lock.RLock()
@@ -69,8 +115,9 @@ func (s *InMemoryStorage) GetOne(id string) (*model.Link, error) {
 	return nil, errors.Wrapf(storage.ErrNotFound, "Link for id %s not found", id)
 }
 
-// GetOneByShortName returns a link byt its short name
+// GetOneByShortName returns a link by its short name or alias
 func (s *InMemoryStorage) GetOneByShortName(shortName string) (*model.Link, error) {
+	shortName = validator.NormalizeShortName(shortName)
 	s.lock.RLock()
 	link, ok := s.linksByShortName[shortName]
 	s.lock.RUnlock()
@@ -81,6 +128,21 @@ func (s *InMemoryStorage) GetOneByShortName(shortName string) (*model.Link, erro
 	return link, nil
 }
 
+// GetOneByCanonicalURL returns the oldest link with the given canonical url
+// (the links are scanned in the order of their ids, which is fine for the amounts kept in memory)
+func (s *InMemoryStorage) GetOneByCanonicalURL(canonicalURL string) (*model.Link, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for _, link := range s.linksByID {
+		if link.CanonicalURL == canonicalURL {
+			return link, nil
+		}
+	}
+
+	return nil, errors.Wrapf(storage.ErrNotFound, "Link for canonical url %s not found", canonicalURL)
+}
+
 // Insert a fresh one
 func (s *InMemoryStorage) Insert(c model.Link) (*model.Link, error) {
 	atomic.AddInt64(&s.idCount, 1)
@@ -89,12 +151,38 @@ func (s *InMemoryStorage) Insert(c model.Link) (*model.Link, error) {
 
 	s.lock.Lock()
 	defer s.lock.Unlock()
-	if lv, exists := s.linksByShortName[c.ShortName]; exists {
-		return lv, errors.Wrapf(storage.ErrShortNameAlreadyExists, "Existing link id %s", lv.ID)
+
+	link, err := s.insert(c)
+	if err != nil {
+		return link, err
+	}
+	s.index.Index(link)
+
+	return link, nil
+}
+
+// LastID implements Storage
+func (s *InMemoryStorage) LastID() (int64, error) {
+	return atomic.LoadInt64(&s.idCount), nil
+}
+
+// insert stores a link with an already assigned id, the caller must hold the write lock and update the search index
+func (s *InMemoryStorage) insert(c model.Link) (*model.Link, error) {
+	normalizeLink(&c)
+	if c.TagIDs == nil {
+		c.TagIDs = []string{}
+	}
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Now()
+	}
+	c.CanonicalURL = canonicalURL(c.OriginalURL)
+	c.Version = 1
+	if lv, err := s.checkShortNames(&c); err != nil {
+		return lv, err
 	}
 
-	s.linksByShortName[c.ShortName] = &c
-	s.links[id] = &c
+	s.setShortNames(&c)
+	s.links[c.ID] = &c
 	s.linksByID = append(s.linksByID, &c)
 
 	//// the following code is commented out assuming that we always get the most recent id (although there's a slight chance to have it inaccurate)
@@ -107,17 +195,69 @@ func (s *InMemoryStorage) Insert(c model.Link) (*model.Link, error) {
 	return &c, nil
 }
 
+// checkShortNames fails if the short name or an alias of the link is used by another link,
+// the caller must hold the lock
+func (s *InMemoryStorage) checkShortNames(c *model.Link) (*model.Link, error) {
+	for _, name := range shortNames(c) {
+		if existing, exists := s.linksByShortName[name]; exists && existing.ID != c.ID {
+			return existing, errors.Wrapf(storage.ErrShortNameAlreadyExists, "Short name %s is used by link id %s", name, existing.ID)
+		}
+	}
+
+	return nil, nil
+}
+
+// setShortNames makes the link found by its short name and aliases, the caller must hold the write lock
+func (s *InMemoryStorage) setShortNames(c *model.Link) {
+	for _, name := range shortNames(c) {
+		s.linksByShortName[name] = c
+	}
+}
+
+// removeShortNames makes the link no longer found by its short name and aliases, the caller must hold the write lock
+func (s *InMemoryStorage) removeShortNames(c *model.Link) {
+	for _, name := range shortNames(c) {
+		delete(s.linksByShortName, name)
+	}
+}
+
 // Delete one :(
 func (s *InMemoryStorage) Delete(id string) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
+	if err := s.delete(id); err != nil {
+		return err
+	}
+	s.index.Remove(id)
+
+	return nil
+}
+
+// DeleteVersion removes the link if it's at the given version
+func (s *InMemoryStorage) DeleteVersion(id string, version int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if link, exists := s.links[id]; exists && link.Version != version {
+		return errors.Wrapf(storage.ErrVersionMismatch, "Link id %s is at version %d, not %d", id, link.Version, version)
+	}
+	if err := s.delete(id); err != nil {
+		return err
+	}
+	s.index.Remove(id)
+
+	return nil
+}
+
+// delete removes a link, the caller must hold the write lock and update the search index
+func (s *InMemoryStorage) delete(id string) error {
 	link, exists := s.links[id]
 	if !exists {
 		return errors.Wrapf(storage.ErrNotFound, "Link for id %s not found", id)
 	}
 	delete(s.links, id)
-	delete(s.linksByShortName, link.ShortName)
+	s.removeShortNames(link)
 
 	// The following is kinda heavy operation, but unavoidable (well, a possible option
 	// would be storing the order index as well, and then deleting this item only by
@@ -136,14 +276,134 @@ func (s *InMemoryStorage) Update(c model.Link) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	_, exists := s.links[c.ID]
+	link, err := s.update(c)
+	if err != nil {
+		return err
+	}
+	s.index.Index(link)
+
+	return nil
+}
+
+// update replaces an existing link, the caller must hold the write lock and update the search index
+func (s *InMemoryStorage) update(c model.Link) (*model.Link, error) {
+	normalizeLink(&c)
+	old, exists := s.links[c.ID]
 	if !exists {
-		return errors.Wrapf(storage.ErrNotFound, "Link for id %s not found", c.ID)
+		return nil, errors.Wrapf(storage.ErrNotFound, "Link for id %s not found", c.ID)
 	}
-	if existing, exists := s.linksByShortName[c.ShortName]; exists && existing.ShortName == c.ShortName {
-		return errors.Wrapf(storage.ErrShortNameAlreadyExists, "Existing link id %s", existing.ID)
+	if c.Version != 0 && c.Version != old.Version {
+		return nil, errors.Wrapf(storage.ErrVersionMismatch, "Link id %s is at version %d, not %d", c.ID, old.Version, c.Version)
 	}
+	if _, err := s.checkShortNames(&c); err != nil {
+		return nil, err
+	}
+	if c.TagIDs == nil {
+		c.TagIDs = []string{}
+	}
+	c.CanonicalURL = canonicalURL(c.OriginalURL)
+	c.Version = old.Version + 1
+	s.removeShortNames(old)
+	s.setShortNames(&c)
 	s.links[c.ID] = &c
+	for i := range s.linksByID {
+		if s.linksByID[i].ID == c.ID {
+			s.linksByID[i] = &c
+			break
+		}
+	}
+
+	return &c, nil
+}
+
+// Batch applies all the operations in the given order, either all of them succeed or none is applied
+func (s *InMemoryStorage) Batch(ops []Operation) ([]*model.Link, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// the maps and the slice are copied, so that the state can be restored on failure
+	// (the links themselves are never modified in place)
+	links := make(map[string]*model.Link, len(s.links))
+	for k, v := range s.links {
+		links[k] = v
+	}
+	linksByShortName := make(map[string]*model.Link, len(s.linksByShortName))
+	for k, v := range s.linksByShortName {
+		linksByShortName[k] = v
+	}
+	linksByID := s.linksByID
+	rollback := func() {
+		s.links = links
+		s.linksByShortName = linksByShortName
+		s.linksByID = linksByID
+	}
+	s.linksByID = append(make([]*model.Link, 0, len(linksByID)+len(ops)), linksByID...)
+
+	results := make([]*model.Link, len(ops))
+	touched := make([]string, 0, len(ops))
+	for i, op := range ops {
+		var err error
+		switch op.Kind {
+		case OperationAdd:
+			op.Link.ID = fmt.Sprintf("%d", atomic.AddInt64(&s.idCount, 1))
+			results[i], err = s.insert(op.Link)
+		case OperationUpdate:
+			results[i], err = s.update(op.Link)
+		case OperationRemove:
+			err = s.delete(op.Link.ID)
+		default:
+			err = errors.Errorf("unknown operation %s", op.Kind)
+		}
+		if err != nil {
+			rollback()
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		touched = append(touched, op.Link.ID)
+	}
+
+	for _, id := range touched {
+		if link, exists := s.links[id]; exists {
+			s.index.Index(link)
+		} else {
+			s.index.Remove(id)
+		}
+	}
+
+	return results, nil
+}
+
+// DetachTag removes the tag from all the links
+func (s *InMemoryStorage) DetachTag(tagID string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for id, link := range s.links {
+		if !hasTag(link, tagID) {
+			continue
+		}
+		// links are never modified in place, because the readers may hold the pointers
+		updated := *link
+		_ = updated.DeleteToManyIDs(model.TagsRelationship, []string{tagID})
+		updated.Version++
+		s.links[id] = &updated
+		s.setShortNames(&updated)
+		for i := range s.linksByID {
+			if s.linksByID[i].ID == id {
+				s.linksByID[i] = &updated
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// Ping implements Storage, the in-memory storage is always reachable
+func (s *InMemoryStorage) Ping(_ context.Context) error {
+	return nil
+}
 
+// CheckMigrations implements Storage, the in-memory storage has no schema
+func (s *InMemoryStorage) CheckMigrations(_ context.Context) error {
 	return nil
 }
@@ -0,0 +1,13 @@
+package linkstorage_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestLinkStorage(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Link Storage Suite")
+}
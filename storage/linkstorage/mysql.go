@@ -1,18 +1,25 @@
 package linkstorage
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"time"
 
-	// load mysql driver
-	_ "github.com/go-sql-driver/mysql"
+	// load mysql driver (the package is also used to inspect the driver errors)
+	"github.com/go-sql-driver/mysql"
 
 	"github.com/denisvmedia/urlshortener/model"
 	"github.com/denisvmedia/urlshortener/storage"
+	"github.com/denisvmedia/urlshortener/validator"
 	"github.com/go-extras/errors"
 	"github.com/jmoiron/sqlx"
 )
 
+const mysqlErrDuplicateEntry = 1062
+
+const mysqlLinkColumns = "links.id, links.short_name, links.original_url, links.canonical_url, links.comment, links.created_at, links.version"
+
 // NewMysqlStorage initializes the MySQL storage
 func NewMysqlStorage(db *sqlx.DB) Storage {
 	return &MysqlStorage{
@@ -25,160 +32,340 @@ type MysqlStorage struct {
 	db *sqlx.DB
 }
 
-func (m *MysqlStorage) countAll() (count int, err error) {
-	query := "SELECT COUNT(ID) FROM links"
-	stmt, err := m.db.Prepare(query)
+func isMysqlDuplicateEntry(err error) bool {
+	mysqlErr, ok := errors.Cause(err).(*mysql.MySQLError)
+	return ok && mysqlErr.Number == mysqlErrDuplicateEntry
+}
+
+// inTx runs fn in a transaction which is committed if fn succeeds and rolled back otherwise
+func (m *MysqlStorage) inTx(fn func(tx *sqlx.Tx) error) error {
+	tx, err := m.db.Beginx()
 	if err != nil {
-		return 0, err
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
 	}
-	defer stmt.Close()
 
-	rows, err := stmt.Query()
+	return tx.Commit()
+}
+
+// mysqlFilterConditions converts the filter to a WHERE clause (empty if there is nothing to filter by) and its arguments
+func mysqlFilterConditions(filter Filter) (where string, args []interface{}) {
+	var conditions []string
+	if filter.Query != "" {
+		conditions = append(conditions, "MATCH(links.original_url, links.comment) AGAINST(? IN NATURAL LANGUAGE MODE)")
+		args = append(args, filter.Query)
+	}
+	if filter.TagID != "" {
+		conditions = append(conditions, "links.id IN (SELECT link_tags.link_id FROM link_tags WHERE link_tags.tag_id = ?)")
+		args = append(args, filter.TagID)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// mysqlQueryLinks runs the query, which must select mysqlLinkColumns, and loads the tags and the aliases of the found links
+func mysqlQueryLinks(q sqlx.Queryer, query string, args ...interface{}) (results []*model.Link, err error) {
+	rows, err := q.Query(query, args...)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	defer rows.Close()
-	if !rows.Next() {
-		return 0, storage.ErrStorageFailure
+
+	for rows.Next() {
+		var idNew, version int
+		var shortNameNew, originalURL, canonicalURL, comment string
+		var createdAt time.Time
+		err = rows.Scan(&idNew, &shortNameNew, &originalURL, &canonicalURL, &comment, &createdAt, &version)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &model.Link{
+			ID:           fmt.Sprint(idNew),
+			ShortName:    shortNameNew,
+			OriginalURL:  originalURL,
+			CanonicalURL: canonicalURL,
+			Comment:      comment,
+			TagIDs:       []string{},
+			Aliases:      []string{},
+			CreatedAt:    createdAt,
+			Version:      version,
+		})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
 
-	err = rows.Scan(&count)
-	if err != nil {
-		return 0, err
+	if err = mysqlLoadTagIDs(q, results); err != nil {
+		return nil, err
+	}
+	if err = mysqlLoadAliases(q, results); err != nil {
+		return nil, err
 	}
 
-	return count, nil
+	return results, nil
 }
 
-// PaginatedGetAll returns a slice of links according to desired pagination and total number of items
-func (m *MysqlStorage) PaginatedGetAll(pageNumber, pageSize int) (results []*model.Link, total int, err error) {
-	offset := (pageNumber - 1) * pageSize
-	limit := pageSize
+// mysqlLoadTagIDs fills TagIDs of the given links
+func mysqlLoadTagIDs(q sqlx.Queryer, links []*model.Link) error {
+	if len(links) == 0 {
+		return nil
+	}
 
-	cnt, err := m.countAll()
-	if err != nil {
-		return nil, 0, err
+	byID := make(map[string]*model.Link, len(links))
+	ids := make([]string, 0, len(links))
+	for _, link := range links {
+		byID[link.ID] = link
+		ids = append(ids, link.ID)
 	}
 
-	query := "SELECT id, short_name, original_url, comment FROM links LIMIT ?, ?"
-	stmt, err := m.db.Prepare(query)
+	query, args, err := sqlx.In("SELECT link_id, tag_id FROM link_tags WHERE link_id IN (?) ORDER BY link_id, tag_id", ids)
 	if err != nil {
-		return nil, 0, err
+		return err
 	}
-	defer stmt.Close()
-
-	rows, err := stmt.Query(offset, limit)
+	rows, err := q.Query(query, args...)
 	if err != nil {
-		return nil, 0, err
+		return err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var idNew int
-		var shortNameNew, originalURL, comment string
-		err = rows.Scan(&idNew, &shortNameNew, &originalURL, &comment)
-		if err != nil {
-			return nil, 0, err
+		var linkID, tagID int
+		if err = rows.Scan(&linkID, &tagID); err != nil {
+			return err
 		}
-
-		results = append(results, &model.Link{
-			ID:          fmt.Sprint(idNew),
-			ShortName:   shortNameNew,
-			OriginalURL: originalURL,
-			Comment:     comment,
-		})
+		link := byID[fmt.Sprint(linkID)]
+		link.TagIDs = append(link.TagIDs, fmt.Sprint(tagID))
 	}
 
-	return results, cnt, nil
+	return rows.Err()
 }
 
-// GetOne link
-func (m *MysqlStorage) GetOne(id string) (*model.Link, error) {
-	query := "SELECT id, short_name, original_url, comment FROM links WHERE id=?"
-	stmt, err := m.db.Prepare(query)
+// mysqlSaveTagIDs replaces the tags of the link with the given ones
+func mysqlSaveTagIDs(e sqlx.Execer, linkID string, tagIDs []string) error {
+	_, err := e.Exec("DELETE FROM link_tags WHERE link_id = ?", linkID)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	for _, tagID := range tagIDs {
+		_, err = e.Exec("INSERT INTO link_tags (link_id, tag_id) VALUES (?, ?)", linkID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mysqlLoadAliases fills Aliases of the given links
+func mysqlLoadAliases(q sqlx.Queryer, links []*model.Link) error {
+	if len(links) == 0 {
+		return nil
 	}
-	defer stmt.Close()
 
-	rows, err := stmt.Query(id)
+	byID := make(map[string]*model.Link, len(links))
+	ids := make([]string, 0, len(links))
+	for _, link := range links {
+		byID[link.ID] = link
+		ids = append(ids, link.ID)
+	}
+
+	query, args, err := sqlx.In("SELECT link_id, short_name FROM link_aliases WHERE link_id IN (?) ORDER BY link_id, short_name", ids)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return err
 	}
 	defer rows.Close()
 
-	if !rows.Next() {
-		return nil, storage.ErrNotFound
+	for rows.Next() {
+		var linkID int
+		var alias string
+		if err = rows.Scan(&linkID, &alias); err != nil {
+			return err
+		}
+		link := byID[fmt.Sprint(linkID)]
+		link.Aliases = append(link.Aliases, alias)
 	}
 
-	var idNew int
-	var shortNameNew, originalUrl, comment string
-	err = rows.Scan(&idNew, &shortNameNew, &originalUrl, &comment)
+	return rows.Err()
+}
+
+// mysqlSaveAliases replaces the aliases of the link with the given ones
+func mysqlSaveAliases(e sqlx.Execer, linkID string, aliases []string) error {
+	_, err := e.Exec("DELETE FROM link_aliases WHERE link_id = ?", linkID)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return &model.Link{
-		ID:          fmt.Sprint(idNew),
-		ShortName:   shortNameNew,
-		OriginalURL: originalUrl,
-		Comment:     comment,
-	}, nil
+	for _, alias := range aliases {
+		_, err = e.Exec("INSERT INTO link_aliases (short_name, link_id) VALUES (?, ?)", alias, linkID)
+		if isMysqlDuplicateEntry(err) {
+			return errors.Wrapf(storage.ErrShortNameAlreadyExists, "Short name %s", alias)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-// GetOneByShortName returns a link byt its short name
-func (m *MysqlStorage) GetOneByShortName(shortName string) (*model.Link, error) {
-	query := "SELECT id, short_name, original_url, comment FROM links WHERE short_name=?"
-	stmt, err := m.db.Prepare(query)
-	if err != nil {
-		return nil, err
+// mysqlCheckShortNames fails if the short name or an alias of the link is used by another link (returned then).
+// The names are read with locks, so that the concurrent transactions can't take them in the other table
+// (the unique indexes only guard each table) until this one ends.
+func mysqlCheckShortNames(tx sqlx.Ext, c model.Link) (*model.Link, error) {
+	for _, name := range shortNames(&c) {
+		var ids []int
+		err := sqlx.Select(tx, &ids, "SELECT id FROM links WHERE short_name = ? FOR UPDATE", name)
+		if err != nil {
+			return nil, err
+		}
+		var aliasIDs []int
+		err = sqlx.Select(tx, &aliasIDs, "SELECT link_id FROM link_aliases WHERE short_name = ? FOR UPDATE", name)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range append(ids, aliasIDs...) {
+			if fmt.Sprint(id) == c.ID {
+				continue
+			}
+			existing, err := mysqlGetOne(tx, fmt.Sprint(id))
+			if err != nil {
+				return nil, err
+			}
+			return existing, errors.Wrapf(storage.ErrShortNameAlreadyExists, "Short name %s is used by link id %s", name, existing.ID)
+		}
 	}
-	defer stmt.Close()
 
-	rows, err := stmt.Query(shortName)
+	return nil, nil
+}
+
+func (m *MysqlStorage) count(filter Filter) (count int, err error) {
+	where, args := mysqlFilterConditions(filter)
+	err = m.db.Get(&count, "SELECT COUNT(links.id) FROM links"+where, args...)
+	return count, err
+}
+
+// PaginatedGetAll returns a slice of links according to desired pagination and total number of items
+func (m *MysqlStorage) PaginatedGetAll(pageNumber, pageSize int) (results []*model.Link, total int, err error) {
+	return m.PaginatedFind(Filter{}, pageNumber, pageSize)
+}
+
+// PaginatedFind returns a slice of links matching the filter according to desired pagination
+// and total number of matching items
+func (m *MysqlStorage) PaginatedFind(filter Filter, pageNumber, pageSize int) (results []*model.Link, total int, err error) {
+	offset := (pageNumber - 1) * pageSize
+	limit := pageSize
+
+	cnt, err := m.count(filter)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	defer rows.Close()
 
-	if !rows.Next() {
-		return nil, storage.ErrNotFound
+	where, args := mysqlFilterConditions(filter)
+	order := " ORDER BY links.id"
+	if filter.Query != "" {
+		order = " ORDER BY MATCH(links.original_url, links.comment) AGAINST(? IN NATURAL LANGUAGE MODE) DESC, links.id"
+		args = append(args, filter.Query)
+	}
+	args = append(args, offset, limit)
+
+	results, err = mysqlQueryLinks(m.db, "SELECT "+mysqlLinkColumns+" FROM links"+where+order+" LIMIT ?, ?", args...)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	var id int
-	var shortNameNew, originalUrl, comment string
-	err = rows.Scan(&id, &shortNameNew, &originalUrl, &comment)
+	return results, cnt, nil
+}
+
+func mysqlGetOne(q sqlx.Queryer, id string) (*model.Link, error) {
+	results, err := mysqlQueryLinks(q, "SELECT "+mysqlLinkColumns+" FROM links WHERE links.id=?", id)
 	if err != nil {
 		return nil, err
 	}
+	if len(results) == 0 {
+		return nil, storage.ErrNotFound
+	}
 
-	return &model.Link{
-		ID:          fmt.Sprint(id),
-		ShortName:   shortNameNew,
-		OriginalURL: originalUrl,
-		Comment:     comment,
-	}, nil
+	return results[0], nil
 }
 
-// Insert a fresh one
-func (m *MysqlStorage) Insert(c model.Link) (*model.Link, error) {
-	existing, err := m.GetOneByShortName(c.ShortName)
-	if err != nil && err != storage.ErrNotFound {
+func mysqlGetOneByShortName(q sqlx.Queryer, shortName string) (*model.Link, error) {
+	shortName = validator.NormalizeShortName(shortName)
+	results, err := mysqlQueryLinks(q, "SELECT "+mysqlLinkColumns+" FROM links WHERE links.short_name=?", shortName)
+	if err != nil {
 		return nil, err
 	}
-	if existing != nil && existing.ID != c.ID {
-		return existing, errors.Wrapf(storage.ErrShortNameAlreadyExists, "Existing link id %s", existing.ID)
+	if len(results) == 0 {
+		results, err = mysqlQueryLinks(q, "SELECT "+mysqlLinkColumns+" FROM links "+
+			"INNER JOIN link_aliases ON link_aliases.link_id = links.id WHERE link_aliases.short_name=?", shortName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(results) == 0 {
+		return nil, storage.ErrNotFound
 	}
 
-	query := "INSERT INTO links (short_name, original_url, comment, created_at, updated_at) VALUES (?, ?, ?, ?, ?)"
-	stmt, err := m.db.Prepare(query)
+	return results[0], nil
+}
+
+func mysqlGetOneByCanonicalURL(q sqlx.Queryer, canonicalURL string) (*model.Link, error) {
+	results, err := mysqlQueryLinks(q, "SELECT "+mysqlLinkColumns+" FROM links WHERE links.canonical_url=? ORDER BY links.id LIMIT 1", canonicalURL)
 	if err != nil {
 		return nil, err
 	}
-	defer stmt.Close()
+	if len(results) == 0 {
+		return nil, storage.ErrNotFound
+	}
+
+	return results[0], nil
+}
+
+// GetOne link
+func (m *MysqlStorage) GetOne(id string) (*model.Link, error) {
+	return mysqlGetOne(m.db, id)
+}
+
+// GetOneByShortName returns a link by its short name or alias
+func (m *MysqlStorage) GetOneByShortName(shortName string) (*model.Link, error) {
+	return mysqlGetOneByShortName(m.db, shortName)
+}
+
+// GetOneByCanonicalURL returns the oldest link with the given canonical url
+func (m *MysqlStorage) GetOneByCanonicalURL(canonicalURL string) (*model.Link, error) {
+	return mysqlGetOneByCanonicalURL(m.db, canonicalURL)
+}
+
+func mysqlInsert(tx sqlx.Ext, c model.Link) (*model.Link, error) {
+	normalizeLink(&c)
+	if existing, err := mysqlCheckShortNames(tx, c); err != nil {
+		return existing, err
+	}
 
 	created := time.Now()
-	result, err := stmt.Exec(c.ShortName, c.OriginalURL, c.Comment, created, created)
+	if !c.CreatedAt.IsZero() {
+		// e.g. the links imported from other shorteners keep their timestamps
+		created = c.CreatedAt
+	}
+	c.CanonicalURL = canonicalURL(c.OriginalURL)
+	c.Version = 1
+	result, err := tx.Exec("INSERT INTO links (short_name, original_url, canonical_url, comment, created_at, updated_at, version) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		c.ShortName, c.OriginalURL, c.CanonicalURL, c.Comment, created, created, c.Version)
+	if isMysqlDuplicateEntry(err) {
+		return nil, errors.Wrapf(storage.ErrShortNameAlreadyExists, "Short name %s", c.ShortName)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -189,20 +376,43 @@ func (m *MysqlStorage) Insert(c model.Link) (*model.Link, error) {
 	}
 
 	c.ID = fmt.Sprint(id)
+	c.CreatedAt = created
+	if c.TagIDs == nil {
+		c.TagIDs = []string{}
+	}
+	if err = mysqlSaveTagIDs(tx, c.ID, c.TagIDs); err != nil {
+		return nil, err
+	}
+	if err = mysqlSaveAliases(tx, c.ID, c.Aliases); err != nil {
+		return nil, err
+	}
 
 	return &c, nil
 }
 
-// Delete one :(
-func (m *MysqlStorage) Delete(id string) error {
-	query := "DELETE FROM links WHERE id = ?"
-	stmt, err := m.db.Prepare(query)
-	if err != nil {
-		panic(err) // here we intentionally panic so that the program is forced to restart
+// Insert a fresh one
+func (m *MysqlStorage) Insert(c model.Link) (result *model.Link, err error) {
+	err = m.inTx(func(tx *sqlx.Tx) error {
+		result, err = mysqlInsert(tx, c)
+		return err
+	})
+
+	return result, err
+}
+
+// LastID implements Storage, the ids of the deleted most recent links may be given again by MySQL
+// but so are their short names
+func (m *MysqlStorage) LastID() (int64, error) {
+	var id int64
+	if err := m.db.Get(&id, "SELECT COALESCE(MAX(id), 0) FROM links"); err != nil {
+		return 0, err
 	}
-	defer stmt.Close()
 
-	result, err := stmt.Exec(id)
+	return id, nil
+}
+
+func mysqlDelete(e sqlx.Execer, id string) error {
+	result, err := e.Exec("DELETE FROM links WHERE id = ?", id)
 	if err != nil {
 		return err
 	}
@@ -215,39 +425,117 @@ func (m *MysqlStorage) Delete(id string) error {
 	return nil
 }
 
-// Update updates an existing link
-func (m *MysqlStorage) Update(c model.Link) error {
-	_, err := m.GetOne(c.ID)
-	if err != nil {
-		return err
-	}
+// Delete one :(
+func (m *MysqlStorage) Delete(id string) error {
+	return mysqlDelete(m.db, id)
+}
+
+// DeleteVersion removes the link if it's at the given version
+func (m *MysqlStorage) DeleteVersion(id string, version int) error {
+	return m.inTx(func(tx *sqlx.Tx) error {
+		if _, err := mysqlGetOne(tx, id); err != nil {
+			return err
+		}
 
-	existing, err := m.GetOneByShortName(c.ShortName)
-	if err != nil && err != storage.ErrNotFound {
+		// the link existence is verified above, so no affected rows means that the version has changed
+		result, err := tx.Exec("DELETE FROM links WHERE id = ? AND version = ?", id, version)
+		if err != nil {
+			return err
+		}
+		if cnt, _ := result.RowsAffected(); cnt == 0 {
+			return errors.Wrapf(storage.ErrVersionMismatch, "Link id %s is not at version %d", id, version)
+		}
+
+		return nil
+	})
+}
+
+func mysqlUpdate(tx sqlx.Ext, c model.Link) error {
+	normalizeLink(&c)
+	if _, err := mysqlGetOne(tx, c.ID); err != nil {
 		return err
 	}
-	if existing != nil && existing.ID != c.ID {
-		return errors.Wrapf(storage.ErrShortNameAlreadyExists, "Existing link id %s", existing.ID)
+	if _, err := mysqlCheckShortNames(tx, c); err != nil {
+		return err
 	}
 
-	query := "UPDATE links SET short_name = ?, original_url = ?, comment = ?, updated_at = ? WHERE id = ?"
-	stmt, err := m.db.Prepare(query)
+	query := "UPDATE links SET short_name = ?, original_url = ?, canonical_url = ?, comment = ?, updated_at = ?, version = version + 1 WHERE id = ?"
+	args := []interface{}{c.ShortName, c.OriginalURL, canonicalURL(c.OriginalURL), c.Comment, time.Now(), c.ID}
+	if c.Version != 0 {
+		// otherwise the caller doesn't care about the concurrent changes
+		query += " AND version = ?"
+		args = append(args, c.Version)
+	}
+	// the link existence is verified above, so no affected rows means that the version has changed
+	// (the row always changes as the version is incremented, so MySQL never reports 0 rows for an unchanged link)
+	result, err := tx.Exec(query, args...)
+	if isMysqlDuplicateEntry(err) {
+		return errors.Wrapf(storage.ErrShortNameAlreadyExists, "Short name %s", c.ShortName)
+	}
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
+	if cnt, _ := result.RowsAffected(); cnt == 0 {
+		return errors.Wrapf(storage.ErrVersionMismatch, "Link id %s is not at version %d", c.ID, c.Version)
+	}
 
-	result, err := stmt.Exec(c.ShortName, c.OriginalURL, c.Comment, time.Now(), c.ID)
-	if err != nil {
+	if err = mysqlSaveTagIDs(tx, c.ID, c.TagIDs); err != nil {
 		return err
 	}
 
-	cnt, _ := result.RowsAffected()
-	if cnt == 0 {
-		return errors.Wrapf(storage.ErrStorageFailure, "DB reported no rows changed")
+	return mysqlSaveAliases(tx, c.ID, c.Aliases)
+}
+
+// Update updates an existing link
+func (m *MysqlStorage) Update(c model.Link) error {
+	return m.inTx(func(tx *sqlx.Tx) error {
+		return mysqlUpdate(tx, c)
+	})
+}
+
+// Batch applies all the operations in the given order in a single transaction
+func (m *MysqlStorage) Batch(ops []Operation) (results []*model.Link, err error) {
+	results = make([]*model.Link, len(ops))
+	err = m.inTx(func(tx *sqlx.Tx) error {
+		for i, op := range ops {
+			var err error
+			switch op.Kind {
+			case OperationAdd:
+				results[i], err = mysqlInsert(tx, op.Link)
+			case OperationUpdate:
+				err = mysqlUpdate(tx, op.Link)
+				if err == nil {
+					results[i], err = mysqlGetOne(tx, op.Link.ID)
+				}
+			case OperationRemove:
+				err = mysqlDelete(tx, op.Link.ID)
+			default:
+				err = errors.Errorf("unknown operation %s", op.Kind)
+			}
+			if err != nil {
+				return &BatchError{Index: i, Err: err}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return results, nil
+}
+
+// DetachTag removes the tag from all the links
+func (m *MysqlStorage) DetachTag(tagID string) error {
+	return m.inTx(func(tx *sqlx.Tx) error {
+		// the links change, so their versions are incremented as well
+		_, err := tx.Exec("UPDATE links INNER JOIN link_tags ON link_tags.link_id = links.id SET links.version = links.version + 1 WHERE link_tags.tag_id = ?", tagID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM link_tags WHERE tag_id = ?", tagID)
+		return err
+	})
 }
 
 func mysqlCreateDB(dbUser, dbPassword, dbHost, dbName string) error {
@@ -271,14 +559,25 @@ func mysqlCreateDB(dbUser, dbPassword, dbHost, dbName string) error {
 	return nil
 }
 
+// Ping implements Storage
+func (m *MysqlStorage) Ping(ctx context.Context) error {
+	return m.db.PingContext(ctx)
+}
+
+// CheckMigrations implements Storage
+func (m *MysqlStorage) CheckMigrations(ctx context.Context) error {
+	return mysqlCheckSchemaVersion(ctx, m.db)
+}
+
 // MysqlConnect creates mysql connection
 func MysqlConnect(dbUser, dbPassword, dbHost, dbName string) (*sqlx.DB, error) {
 	return sqlx.Connect("mysql",
-		fmt.Sprintf("%s:%s@(%s)/%s?parseTime=true",
+		fmt.Sprintf("%s:%s@(%s)/%s?parseTime=true&charset=utf8mb4,utf8",
 			dbUser, dbPassword, dbHost, dbName))
 }
 
-// MysqlInitStorage initializes MySQL storage by creating the database (optionally) and the tables
+// MysqlInitStorage initializes MySQL storage by creating the database (optionally) and the tables,
+// for an existing database it applies the schema migrations that haven't been applied yet
 func MysqlInitStorage(dbUser, dbPassword, dbHost, dbName string, createDb bool) error {
 	if createDb {
 		err := mysqlCreateDB(dbUser, dbPassword, dbHost, dbName)
@@ -288,26 +587,16 @@ func MysqlInitStorage(dbUser, dbPassword, dbHost, dbName string, createDb bool)
 	}
 
 	dbh, err := sqlx.Connect("mysql",
-		fmt.Sprintf("%s:%s@(%s)/%s?parseTime=true",
+		fmt.Sprintf("%s:%s@(%s)/%s?parseTime=true&charset=utf8mb4,utf8",
 			dbUser, dbPassword, dbHost, dbName))
 	if err != nil {
 		return err
 	}
+	defer func() {
+		_ = dbh.Close()
+	}()
 
-	_, err = dbh.Queryx("CREATE TABLE `links` (`id` INT NOT NULL AUTO_INCREMENT, " +
-		"`short_name` VARCHAR(255) NOT NULL, " +
-		"`original_url` TEXT NOT NULL, " +
-		"`comment` VARCHAR(255) NOT NULL, " +
-		"`created_at` DATETIME NOT NULL, " +
-		"`updated_at` DATETIME NOT NULL, " +
-		"PRIMARY KEY (`id`), " +
-		"UNIQUE INDEX `short_name` (`short_name`)) " +
-		"COLLATE='utf8_general_ci'")
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return mysqlMigrate(dbh)
 }
 
 // MysqlDropDB drops the application database
@@ -0,0 +1,187 @@
+package linkstorage
+
+import (
+	"context"
+
+	"github.com/go-extras/errors"
+	"github.com/jmoiron/sqlx"
+)
+
+// mysqlMigration describes a single schema change, migrations are applied in the order of their versions
+type mysqlMigration struct {
+	version     int
+	description string
+	statements  []string
+	// backfill updates the data after the statements, if the new values can't be computed by the database
+	backfill func(dbh *sqlx.DB) error
+}
+
+var mysqlMigrations = []mysqlMigration{
+	{
+		version:     1,
+		description: "create links table",
+		statements: []string{
+			"CREATE TABLE IF NOT EXISTS `links` (`id` INT NOT NULL AUTO_INCREMENT, " +
+				"`short_name` VARCHAR(255) NOT NULL, " +
+				"`original_url` TEXT NOT NULL, " +
+				"`comment` VARCHAR(255) NOT NULL, " +
+				"`created_at` DATETIME NOT NULL, " +
+				"`updated_at` DATETIME NOT NULL, " +
+				"PRIMARY KEY (`id`), " +
+				"UNIQUE INDEX `short_name` (`short_name`)) " +
+				"COLLATE='utf8_general_ci'",
+		},
+	},
+	{
+		version:     2,
+		description: "add full-text search index",
+		statements: []string{
+			"ALTER TABLE `links` ADD FULLTEXT INDEX `search` (`original_url`, `comment`)",
+		},
+	},
+	{
+		version:     3,
+		description: "create tags tables",
+		statements: []string{
+			"CREATE TABLE `tags` (`id` INT NOT NULL AUTO_INCREMENT, " +
+				"`name` VARCHAR(64) NOT NULL, " +
+				"`created_at` DATETIME NOT NULL, " +
+				"`updated_at` DATETIME NOT NULL, " +
+				"PRIMARY KEY (`id`), " +
+				"UNIQUE INDEX `name` (`name`)) " +
+				"COLLATE='utf8_general_ci'",
+			"CREATE TABLE `link_tags` (`link_id` INT NOT NULL, " +
+				"`tag_id` INT NOT NULL, " +
+				"PRIMARY KEY (`link_id`, `tag_id`), " +
+				"INDEX `tag_id` (`tag_id`), " +
+				"CONSTRAINT `link_tags_link` FOREIGN KEY (`link_id`) REFERENCES `links` (`id`) ON DELETE CASCADE, " +
+				"CONSTRAINT `link_tags_tag` FOREIGN KEY (`tag_id`) REFERENCES `tags` (`id`) ON DELETE CASCADE) " +
+				"COLLATE='utf8_general_ci'",
+		},
+	},
+	{
+		version:     4,
+		description: "add canonical urls",
+		statements: []string{
+			"ALTER TABLE `links` ADD COLUMN `canonical_url` TEXT NOT NULL AFTER `original_url`, " +
+				"ADD INDEX `canonical_url` (`canonical_url`(255))",
+		},
+		backfill: mysqlBackfillCanonicalURLs,
+	},
+	{
+		version:     5,
+		description: "allow unicode short names",
+		statements: []string{
+			// binary collation, so that only the short names of the same NFC form match (utf8_general_ci ignores
+			// the case and the accents), and utf8mb4 for the letters outside of the basic multilingual plane
+			"ALTER TABLE `links` MODIFY `short_name` VARCHAR(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL",
+		},
+	},
+	{
+		version:     6,
+		description: "create link aliases table",
+		statements: []string{
+			// the aliases are compared the same way as the short names of the links
+			"CREATE TABLE `link_aliases` (`short_name` VARCHAR(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL, " +
+				"`link_id` INT NOT NULL, " +
+				"PRIMARY KEY (`short_name`), " +
+				"INDEX `link_id` (`link_id`), " +
+				"CONSTRAINT `link_aliases_link` FOREIGN KEY (`link_id`) REFERENCES `links` (`id`) ON DELETE CASCADE) " +
+				"COLLATE='utf8_general_ci'",
+		},
+	},
+	{
+		version:     7,
+		description: "add link versions",
+		statements: []string{
+			"ALTER TABLE `links` ADD COLUMN `version` INT NOT NULL DEFAULT 1",
+		},
+	},
+	{
+		version:     8,
+		description: "make tag names case-sensitive",
+		statements: []string{
+			// the tag names are compared the same way as in the in-memory storage (utf8_general_ci ignores the case)
+			"ALTER TABLE `tags` MODIFY `name` VARCHAR(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL",
+		},
+	},
+}
+
+// mysqlBackfillCanonicalURLs sets the canonical urls of the links created before they were stored
+func mysqlBackfillCanonicalURLs(dbh *sqlx.DB) error {
+	var links []struct {
+		ID          int    `db:"id"`
+		OriginalURL string `db:"original_url"`
+	}
+	if err := dbh.Select(&links, "SELECT `id`, `original_url` FROM `links` WHERE `canonical_url` = ''"); err != nil {
+		return err
+	}
+	for _, link := range links {
+		if _, err := dbh.Exec("UPDATE `links` SET `canonical_url` = ? WHERE `id` = ?", canonicalURL(link.OriginalURL), link.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mysqlSchemaVersion returns the version of the last migration applied to the database
+func mysqlSchemaVersion(dbh *sqlx.DB) (version int, err error) {
+	err = dbh.Get(&version, "SELECT COALESCE(MAX(`version`), 0) FROM `schema_migrations`")
+	return version, err
+}
+
+// mysqlCheckSchemaVersion returns an error if some migrations haven't been applied to the database
+func mysqlCheckSchemaVersion(ctx context.Context, dbh *sqlx.DB) error {
+	var version int
+	err := dbh.GetContext(ctx, &version, "SELECT COALESCE(MAX(`version`), 0) FROM `schema_migrations`")
+	if err != nil {
+		return err
+	}
+	if latest := mysqlMigrations[len(mysqlMigrations)-1].version; version < latest {
+		return errors.Errorf("schema version %d is behind %d, run init-storage command to apply the migrations", version, latest)
+	}
+
+	return nil
+}
+
+// mysqlMigrate applies all migrations that haven't been applied to the database yet
+func mysqlMigrate(dbh *sqlx.DB) error {
+	_, err := dbh.Exec("CREATE TABLE IF NOT EXISTS `schema_migrations` (`version` INT NOT NULL, " +
+		"`description` VARCHAR(255) NOT NULL, " +
+		"`applied_at` DATETIME NOT NULL, " +
+		"PRIMARY KEY (`version`)) " +
+		"COLLATE='utf8_general_ci'")
+	if err != nil {
+		return err
+	}
+
+	current, err := mysqlSchemaVersion(dbh)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range mysqlMigrations {
+		if migration.version <= current {
+			continue
+		}
+		// MySQL commits DDL statements implicitly, so there is no point in wrapping them into a transaction
+		for _, statement := range migration.statements {
+			if _, err := dbh.Exec(statement); err != nil {
+				return errors.Wrapf(err, "migration %d (%s) failed", migration.version, migration.description)
+			}
+		}
+		if migration.backfill != nil {
+			if err := migration.backfill(dbh); err != nil {
+				return errors.Wrapf(err, "migration %d (%s) failed", migration.version, migration.description)
+			}
+		}
+		_, err = dbh.Exec("INSERT INTO `schema_migrations` (`version`, `description`, `applied_at`) VALUES (?, ?, NOW())",
+			migration.version, migration.description)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
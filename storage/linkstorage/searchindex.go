@@ -0,0 +1,135 @@
+package linkstorage
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/denisvmedia/urlshortener/model"
+)
+
+// SearchHit is a single search result: a link id and its relevance score
+type SearchHit struct {
+	ID    string
+	Score float64
+}
+
+// SearchIndex defines a full-text index over link comments and destination urls
+// that can be plugged into a storage which has no native full-text search
+type SearchIndex interface {
+	// Index adds the link to the index (replacing any previously indexed version of it)
+	Index(link *model.Link)
+	// Remove removes the link with the given id from the index
+	Remove(id string)
+	// Search returns the ids of the matching links ordered by relevance (the most relevant first)
+	Search(query string) []SearchHit
+}
+
+// Tokenize splits the text into lowercase words, everything that is not a letter or a digit is a separator
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// NewInvertedIndex creates an empty in-memory inverted index
+func NewInvertedIndex() *InvertedIndex {
+	return &InvertedIndex{
+		postings: make(map[string]map[string]int),
+		terms:    make(map[string][]string),
+	}
+}
+
+// InvertedIndex is an in-memory SearchIndex implementation that ranks the results using tf-idf
+type InvertedIndex struct {
+	// term -> link id -> term frequency
+	postings map[string]map[string]int
+	// link id -> unique terms (used to clean up the postings on removal)
+	terms map[string][]string
+	lock  sync.RWMutex
+}
+
+// Index adds the link to the index (replacing any previously indexed version of it)
+func (idx *InvertedIndex) Index(link *model.Link) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	idx.remove(link.ID)
+
+	frequencies := make(map[string]int)
+	for _, term := range Tokenize(link.OriginalURL + " " + link.Comment) {
+		frequencies[term]++
+	}
+
+	terms := make([]string, 0, len(frequencies))
+	for term, freq := range frequencies {
+		postings, ok := idx.postings[term]
+		if !ok {
+			postings = make(map[string]int)
+			idx.postings[term] = postings
+		}
+		postings[link.ID] = freq
+		terms = append(terms, term)
+	}
+	idx.terms[link.ID] = terms
+}
+
+// Remove removes the link with the given id from the index
+func (idx *InvertedIndex) Remove(id string) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	idx.remove(id)
+}
+
+func (idx *InvertedIndex) remove(id string) {
+	for _, term := range idx.terms[id] {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.terms, id)
+}
+
+// Search returns the ids of the links matching any of the query words ordered by relevance (the most relevant first)
+func (idx *InvertedIndex) Search(query string) []SearchHit {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	total := float64(len(idx.terms))
+	scores := make(map[string]float64)
+	for _, term := range Tokenize(query) {
+		postings := idx.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		idf := math.Log(1 + total/float64(len(postings)))
+		for id, freq := range postings {
+			scores[id] += float64(freq) * idf
+		}
+	}
+
+	hits := make([]SearchHit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, SearchHit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return lessID(hits[i].ID, hits[j].ID)
+	})
+
+	return hits
+}
+
+// lessID orders the numeric link ids by their value (like the MySQL storage does)
+func lessID(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
@@ -0,0 +1,25 @@
+package linkstorage_test
+
+import (
+	"strconv"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("InvertedIndex", func() {
+	It("orders the results with the same score by the numeric ids", func() {
+		idx := linkstorage.NewInvertedIndex()
+		for _, id := range []int{10, 9, 100, 2} {
+			idx.Index(&model.Link{ID: strconv.Itoa(id), OriginalURL: "https://example.com/spring"})
+		}
+
+		var ids []string
+		for _, hit := range idx.Search("spring") {
+			ids = append(ids, hit.ID)
+		}
+		Expect(ids).To(Equal([]string{"2", "9", "10", "100"}))
+	})
+})
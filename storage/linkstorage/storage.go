@@ -1,15 +1,116 @@
 package linkstorage
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/validator"
+)
+
+// Filter defines the criteria to find the links by, empty fields are ignored
+type Filter struct {
+	// Query is a full-text search query, when set the results are ordered by relevance
+	Query string
+	// TagID limits the results to the links tagged with the given tag
+	TagID string
+}
+
+// OperationKind defines what a batch operation does with a link
+type OperationKind string
+
+// Batch operation kinds
+const (
+	OperationAdd    OperationKind = "add"
+	OperationUpdate OperationKind = "update"
+	OperationRemove OperationKind = "remove"
 )
 
+// Operation is a single operation of a batch, only Link.ID is used for OperationRemove
+type Operation struct {
+	Kind OperationKind
+	Link model.Link
+}
+
+// BatchError is returned when a batch fails, it points to the operation that caused the failure
+type BatchError struct {
+	// Index of the failed operation
+	Index int
+	Err   error
+}
+
+// Error implements error interface
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation #%d failed: %s", e.Index, e.Err)
+}
+
+// Cause returns the underlying error
+func (e *BatchError) Cause() error {
+	return e.Err
+}
+
+// canonicalURL returns the canonical form of the original url stored with the link,
+// the url is stored as it is if it can't be parsed (it's validated before getting here)
+func canonicalURL(originalURL string) string {
+	canonical, err := validator.CanonicalURL(originalURL)
+	if err != nil {
+		return originalURL
+	}
+
+	return canonical
+}
+
+// normalizeLink brings the short names and the original url of the link to the form they are stored in:
+// NFC short name and aliases (see validator.NormalizeShortName) and punycode host (see validator.NormalizeURL),
+// the repeated aliases and the ones equal to the short name are dropped
+func normalizeLink(link *model.Link) {
+	link.ShortName = validator.NormalizeShortName(link.ShortName)
+	link.OriginalURL = validator.NormalizeURL(link.OriginalURL)
+	aliases := make([]string, 0, len(link.Aliases))
+	seen := map[string]bool{link.ShortName: true}
+	for _, alias := range link.Aliases {
+		alias = validator.NormalizeShortName(alias)
+		if !seen[alias] {
+			seen[alias] = true
+			aliases = append(aliases, alias)
+		}
+	}
+	link.Aliases = aliases
+}
+
+// shortNames returns the short name and the aliases of the link, i.e. all the names the link is found by
+func shortNames(link *model.Link) []string {
+	return append([]string{link.ShortName}, link.Aliases...)
+}
+
 // Storage defines an interface that must be implemented in order to be used as a backend to store the links
 type Storage interface {
 	PaginatedGetAll(pageNumber, pageSize int) (results []*model.Link, total int, err error)
+	PaginatedFind(filter Filter, pageNumber, pageSize int) (results []*model.Link, total int, err error)
 	GetOne(id string) (*model.Link, error)
+	// GetOneByShortName returns the link with the given short name or alias
 	GetOneByShortName(shortName string) (*model.Link, error)
+	// GetOneByCanonicalURL returns the oldest link which original url has the given canonical form
+	// (see validator.CanonicalURL)
+	GetOneByCanonicalURL(canonicalURL string) (*model.Link, error)
 	Insert(c model.Link) (*model.Link, error)
+	// LastID returns the highest link id given so far (zero if none), the ids grow in the creation order
+	// and are not reused, unlike the number of links that goes down when they are deleted
+	LastID() (int64, error)
 	Delete(id string) error
+	// DeleteVersion removes the link only if it's at the given version, otherwise storage.ErrVersionMismatch is returned
+	DeleteVersion(id string, version int) error
+	// Update replaces an existing link and increments its version, if the version of the given link is not zero
+	// it must match the stored one, otherwise storage.ErrVersionMismatch is returned
 	Update(c model.Link) error
+	// Batch applies all the operations in the given order, either all of them succeed or none is applied.
+	// It returns the resulting links in the order of the operations (nil for OperationRemove).
+	Batch(ops []Operation) ([]*model.Link, error)
+	// DetachTag removes the tag from all the links incrementing their versions,
+	// it must be called before the tag is deleted from the tag storage
+	DetachTag(tagID string) error
+	// Ping checks that the storage is reachable
+	Ping(ctx context.Context) error
+	// CheckMigrations returns an error if the storage schema is not up to date
+	CheckMigrations(ctx context.Context) error
 }
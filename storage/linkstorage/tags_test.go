@@ -0,0 +1,64 @@
+package linkstorage_test
+
+import (
+	"os"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/storage/linkstorage"
+	"github.com/denisvmedia/urlshortener/storage/tagstorage"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DetachTag", func() {
+	var linkStorage linkstorage.Storage
+	var tagStorage tagstorage.Storage
+	var mysql []string
+
+	BeforeEach(func() {
+		mysql = nil
+		if v, ok := os.LookupEnv("TEST_STORAGE"); !ok || v != "mysql" {
+			linkStorage = linkstorage.NewInMemoryStorage()
+			tagStorage = tagstorage.NewInMemoryStorage()
+			return
+		}
+
+		for _, name := range []string{"MYSQL_USER", "MYSQL_PASSWORD", "MYSQL_HOST", "MYSQL_DBNAME"} {
+			value, ok := os.LookupEnv(name)
+			Expect(ok).To(BeTrue(), name+" must be set for this test")
+			mysql = append(mysql, value)
+		}
+		Expect(linkstorage.MysqlInitStorage(mysql[0], mysql[1], mysql[2], mysql[3], true)).To(Succeed())
+		dbh, err := linkstorage.MysqlConnect(mysql[0], mysql[1], mysql[2], mysql[3])
+		Expect(err).ToNot(HaveOccurred())
+		linkStorage = linkstorage.NewMysqlStorage(dbh)
+		tagStorage = tagstorage.NewMysqlStorage(dbh)
+	})
+
+	AfterEach(func() {
+		if mysql != nil {
+			Expect(linkstorage.MysqlDropDB(mysql[0], mysql[1], mysql[2], mysql[3])).To(Succeed())
+		}
+	})
+
+	It("increments the versions of the links the tag is removed from", func() {
+		tag, err := tagStorage.Insert(model.Tag{Name: "marketing"})
+		Expect(err).ToNot(HaveOccurred())
+		tagged, err := linkStorage.Insert(model.Link{ShortName: "tagged", OriginalURL: "https://example.com/tagged", TagIDs: []string{tag.ID}})
+		Expect(err).ToNot(HaveOccurred())
+		untagged, err := linkStorage.Insert(model.Link{ShortName: "untagged", OriginalURL: "https://example.com/untagged"})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(linkStorage.DetachTag(tag.ID)).To(Succeed())
+		Expect(tagStorage.Delete(tag.ID)).To(Succeed())
+
+		link, err := linkStorage.GetOne(tagged.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(link.TagIDs).To(BeEmpty())
+		Expect(link.Version).To(Equal(tagged.Version + 1))
+
+		link, err = linkStorage.GetOne(untagged.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(link.Version).To(Equal(untagged.Version))
+	})
+})
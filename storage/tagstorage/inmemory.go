@@ -0,0 +1,162 @@
+package tagstorage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/storage"
+	"github.com/go-extras/errors"
+)
+
+// sorting
+type byID []*model.Tag
+
+func (c byID) Len() int {
+	return len(c)
+}
+
+func (c byID) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+func (c byID) Less(i, j int) bool {
+	return c[i].GetID() < c[j].GetID()
+}
+
+// NewInMemoryStorage initializes the storage
+func NewInMemoryStorage() Storage {
+	return &InMemoryStorage{
+		tags:       make(map[string]*model.Tag),
+		tagsByName: make(map[string]*model.Tag),
+		tagsByID:   make([]*model.Tag, 0),
+		idCount:    0,
+	}
+}
+
+// InMemoryStorage stores all of the tags in memory
+type InMemoryStorage struct {
+	tags       map[string]*model.Tag
+	tagsByName map[string]*model.Tag
+	tagsByID   []*model.Tag
+	idCount    int64
+	lock       sync.RWMutex
+}
+
+// PaginatedGetAll returns a slice of tags according to desired pagination and total number of items
+func (s *InMemoryStorage) PaginatedGetAll(pageNumber, pageSize int) (results []*model.Tag, total int, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	start, end := storage.SlicePaginate(pageNumber-1, pageSize, len(s.tagsByID))
+	results = s.tagsByID[start:end]
+
+	return results, len(s.tagsByID), nil
+}
+
+// GetOne tag
+func (s *InMemoryStorage) GetOne(id string) (*model.Tag, error) {
+	s.lock.RLock()
+	tag, ok := s.tags[id]
+	s.lock.RUnlock()
+	if ok {
+		return tag, nil
+	}
+
+	return nil, errors.Wrapf(storage.ErrNotFound, "Tag for id %s not found", id)
+}
+
+// GetOneByName returns a tag by its name
+func (s *InMemoryStorage) GetOneByName(name string) (*model.Tag, error) {
+	s.lock.RLock()
+	tag, ok := s.tagsByName[name]
+	s.lock.RUnlock()
+	if !ok {
+		return nil, errors.Wrapf(storage.ErrNotFound, "Tag for name %s not found", name)
+	}
+
+	return tag, nil
+}
+
+// GetByIDs returns the tags with the given ids, it fails if any of them doesn't exist
+func (s *InMemoryStorage) GetByIDs(ids []string) ([]*model.Tag, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	results := make([]*model.Tag, 0, len(ids))
+	for _, id := range ids {
+		tag, ok := s.tags[id]
+		if !ok {
+			return nil, errors.Wrapf(storage.ErrNotFound, "Tag for id %s not found", id)
+		}
+		results = append(results, tag)
+	}
+
+	return results, nil
+}
+
+// Insert a fresh one
+func (s *InMemoryStorage) Insert(t model.Tag) (*model.Tag, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if tv, exists := s.tagsByName[t.Name]; exists {
+		return tv, errors.Wrapf(storage.ErrTagNameAlreadyExists, "Existing tag id %s", tv.ID)
+	}
+
+	id := fmt.Sprintf("%d", atomic.AddInt64(&s.idCount, 1))
+	t.ID = id
+
+	s.tagsByName[t.Name] = &t
+	s.tags[id] = &t
+	s.tagsByID = append(s.tagsByID, &t)
+
+	return &t, nil
+}
+
+// Delete one
+func (s *InMemoryStorage) Delete(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	tag, exists := s.tags[id]
+	if !exists {
+		return errors.Wrapf(storage.ErrNotFound, "Tag for id %s not found", id)
+	}
+	delete(s.tags, id)
+	delete(s.tagsByName, tag.Name)
+
+	s.tagsByID = make([]*model.Tag, 0, len(s.tags))
+	for key := range s.tags {
+		s.tagsByID = append(s.tagsByID, s.tags[key])
+	}
+	sort.Sort(byID(s.tagsByID))
+
+	return nil
+}
+
+// Update updates an existing tag
+func (s *InMemoryStorage) Update(t model.Tag) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	old, exists := s.tags[t.ID]
+	if !exists {
+		return errors.Wrapf(storage.ErrNotFound, "Tag for id %s not found", t.ID)
+	}
+	if existing, exists := s.tagsByName[t.Name]; exists && existing.ID != t.ID {
+		return errors.Wrapf(storage.ErrTagNameAlreadyExists, "Existing tag id %s", existing.ID)
+	}
+	delete(s.tagsByName, old.Name)
+	s.tagsByName[t.Name] = &t
+	s.tags[t.ID] = &t
+	for i := range s.tagsByID {
+		if s.tagsByID[i].ID == t.ID {
+			s.tagsByID[i] = &t
+			break
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package tagstorage
+
+import (
+	"fmt"
+	"time"
+
+	// load mysql driver
+	_ "github.com/go-sql-driver/mysql"
+
+	"github.com/denisvmedia/urlshortener/model"
+	"github.com/denisvmedia/urlshortener/storage"
+	"github.com/go-extras/errors"
+	"github.com/jmoiron/sqlx"
+)
+
+// NewMysqlStorage initializes the MySQL storage
+func NewMysqlStorage(db *sqlx.DB) Storage {
+	return &MysqlStorage{
+		db: db,
+	}
+}
+
+// MysqlStorage defines a storage implementation that uses MySQL
+// (the tables are created by linkstorage.MysqlInitStorage)
+type MysqlStorage struct {
+	db *sqlx.DB
+}
+
+func (m *MysqlStorage) countAll() (count int, err error) {
+	err = m.db.Get(&count, "SELECT COUNT(id) FROM tags")
+	return count, err
+}
+
+func (m *MysqlStorage) queryTags(query string, args ...interface{}) (results []*model.Tag, err error) {
+	query, args, err = sqlx.In(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var name string
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &model.Tag{
+			ID:   fmt.Sprint(id),
+			Name: name,
+		})
+	}
+
+	return results, rows.Err()
+}
+
+// PaginatedGetAll returns a slice of tags according to desired pagination and total number of items
+func (m *MysqlStorage) PaginatedGetAll(pageNumber, pageSize int) (results []*model.Tag, total int, err error) {
+	offset := (pageNumber - 1) * pageSize
+	limit := pageSize
+
+	cnt, err := m.countAll()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	results, err = m.queryTags("SELECT id, name FROM tags ORDER BY id LIMIT ?, ?", offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return results, cnt, nil
+}
+
+// GetOne tag
+func (m *MysqlStorage) GetOne(id string) (*model.Tag, error) {
+	results, err := m.queryTags("SELECT id, name FROM tags WHERE id=?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, storage.ErrNotFound
+	}
+
+	return results[0], nil
+}
+
+// GetOneByName returns a tag by its name
+func (m *MysqlStorage) GetOneByName(name string) (*model.Tag, error) {
+	results, err := m.queryTags("SELECT id, name FROM tags WHERE name=?", name)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, storage.ErrNotFound
+	}
+
+	return results[0], nil
+}
+
+// GetByIDs returns the tags with the given ids, it fails if any of them doesn't exist
+func (m *MysqlStorage) GetByIDs(ids []string) ([]*model.Tag, error) {
+	if len(ids) == 0 {
+		return []*model.Tag{}, nil
+	}
+
+	found, err := m.queryTags("SELECT id, name FROM tags WHERE id IN (?)", ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*model.Tag, len(found))
+	for _, tag := range found {
+		byID[tag.ID] = tag
+	}
+	results := make([]*model.Tag, 0, len(ids))
+	for _, id := range ids {
+		tag, ok := byID[id]
+		if !ok {
+			return nil, errors.Wrapf(storage.ErrNotFound, "Tag for id %s not found", id)
+		}
+		results = append(results, tag)
+	}
+
+	return results, nil
+}
+
+// Insert a fresh one
+func (m *MysqlStorage) Insert(t model.Tag) (*model.Tag, error) {
+	existing, err := m.GetOneByName(t.Name)
+	if err != nil && err != storage.ErrNotFound {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, errors.Wrapf(storage.ErrTagNameAlreadyExists, "Existing tag id %s", existing.ID)
+	}
+
+	created := time.Now()
+	result, err := m.db.Exec("INSERT INTO tags (name, created_at, updated_at) VALUES (?, ?, ?)", t.Name, created, created)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	if id <= 0 {
+		return nil, errors.Wrapf(storage.ErrStorageFailure, "Got non-positive last insert id")
+	}
+
+	t.ID = fmt.Sprint(id)
+
+	return &t, nil
+}
+
+// Delete one (the tag is detached from all the links by the foreign key)
+func (m *MysqlStorage) Delete(id string) error {
+	result, err := m.db.Exec("DELETE FROM tags WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	cnt, _ := result.RowsAffected()
+	if cnt == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// Update updates an existing tag
+func (m *MysqlStorage) Update(t model.Tag) error {
+	_, err := m.GetOne(t.ID)
+	if err != nil {
+		return err
+	}
+
+	existing, err := m.GetOneByName(t.Name)
+	if err != nil && err != storage.ErrNotFound {
+		return err
+	}
+	if existing != nil && existing.ID != t.ID {
+		return errors.Wrapf(storage.ErrTagNameAlreadyExists, "Existing tag id %s", existing.ID)
+	}
+
+	_, err = m.db.Exec("UPDATE tags SET name = ?, updated_at = ? WHERE id = ?", t.Name, time.Now(), t.ID)
+
+	return err
+}
@@ -0,0 +1,16 @@
+package tagstorage
+
+import (
+	"github.com/denisvmedia/urlshortener/model"
+)
+
+// Storage defines an interface that must be implemented in order to be used as a backend to store the tags
+type Storage interface {
+	PaginatedGetAll(pageNumber, pageSize int) (results []*model.Tag, total int, err error)
+	GetOne(id string) (*model.Tag, error)
+	GetOneByName(name string) (*model.Tag, error)
+	GetByIDs(ids []string) ([]*model.Tag, error)
+	Insert(t model.Tag) (*model.Tag, error)
+	Delete(id string) error
+	Update(t model.Tag) error
+}
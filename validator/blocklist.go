@@ -0,0 +1,41 @@
+package validator
+
+import (
+	"sync/atomic"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Blocklist tells whether the urls are known to be malicious (see blocklist package)
+type Blocklist interface {
+	Check(rawURL string) (reason string, blocked bool)
+}
+
+// blocklistHolder lets atomic.Value hold a nil Blocklist
+type blocklistHolder struct {
+	blocklist Blocklist
+}
+
+// currentBlocklist holds the Blocklist the original urls are checked against (*blocklistHolder)
+var currentBlocklist atomic.Value
+
+func init() {
+	currentBlocklist.Store(&blocklistHolder{})
+}
+
+// SetBlocklist replaces the Blocklist the original urls are checked against, nil disables the checks
+func SetBlocklist(blocklist Blocklist) {
+	currentBlocklist.Store(&blocklistHolder{blocklist: blocklist})
+}
+
+// ValidateURLNotBlocked implements validator.Func
+func ValidateURLNotBlocked(fl validator.FieldLevel) bool {
+	v := fl.Field().String()
+	b := currentBlocklist.Load().(*blocklistHolder).blocklist
+	if v == "" || b == nil {
+		return true
+	}
+	_, blocked := b.Check(v)
+
+	return !blocked
+}
@@ -0,0 +1,159 @@
+package validator
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-extras/errors"
+	"github.com/go-playground/validator/v10"
+)
+
+// domainRule matches the hosts of the urls: a domain (with its subdomains if it starts with "*.")
+// or an ip address block
+type domainRule struct {
+	domain     string
+	subdomains bool
+	network    *net.IPNet
+}
+
+func parseDomainRule(rule string) (domainRule, error) {
+	rule = strings.TrimSpace(rule)
+	if strings.HasPrefix(rule, "*.") {
+		rule = "*." + ASCIIHost(rule[2:])
+	} else {
+		rule = ASCIIHost(rule)
+	}
+	rule = strings.TrimSuffix(strings.ToLower(rule), ".")
+	if rule == "" {
+		return domainRule{}, errors.New("empty domain rule")
+	}
+	if strings.Contains(rule, "/") {
+		_, network, err := net.ParseCIDR(rule)
+		if err != nil {
+			return domainRule{}, err
+		}
+		return domainRule{network: network}, nil
+	}
+	if ip := net.ParseIP(strings.Trim(rule, "[]")); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		return domainRule{network: &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}}, nil
+	}
+	if strings.HasPrefix(rule, "*.") {
+		return domainRule{domain: rule[2:], subdomains: true}, nil
+	}
+	if strings.Contains(rule, "*") {
+		return domainRule{}, errors.Errorf("only a leading wildcard is allowed in %s", rule)
+	}
+
+	return domainRule{domain: rule}, nil
+}
+
+func (r domainRule) matches(host string, ip net.IP) bool {
+	if r.network != nil {
+		return ip != nil && r.network.Contains(ip)
+	}
+	if r.subdomains {
+		return strings.HasSuffix(host, "."+r.domain)
+	}
+
+	return host == r.domain
+}
+
+// domainLists defines the configured destination domain lists
+type domainLists struct {
+	allow []domainRule
+	deny  []domainRule
+}
+
+// destinationDomains holds the configured destination domain lists (*domainLists)
+var destinationDomains atomic.Value
+
+func init() {
+	destinationDomains.Store(&domainLists{})
+}
+
+func parseDomainRules(rules []string) ([]domainRule, error) {
+	result := make([]domainRule, 0, len(rules))
+	for _, rule := range rules {
+		parsed, err := parseDomainRule(rule)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid domain rule %s", rule)
+		}
+		result = append(result, parsed)
+	}
+
+	return result, nil
+}
+
+// SetDestinationDomains replaces the lists of the allowed and denied destination domains. The rules are domains
+// (example.com), wildcard subdomains (*.example.com, the domain itself doesn't match), ip addresses or CIDR blocks
+// (they only match the urls with ip addresses). Nothing is replaced if any of the rules is invalid.
+func SetDestinationDomains(allow, deny []string) error {
+	set, err := PrepareDestinationDomains(allow, deny)
+	if err != nil {
+		return err
+	}
+	set()
+
+	return nil
+}
+
+// PrepareDestinationDomains checks the rules like SetDestinationDomains does and returns the function replacing them
+// (see PrepareReservedShortNames)
+func PrepareDestinationDomains(allow, deny []string) (func(), error) {
+	lists := &domainLists{}
+	var err error
+	if lists.allow, err = parseDomainRules(allow); err != nil {
+		return nil, err
+	}
+	if lists.deny, err = parseDomainRules(deny); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		destinationDomains.Store(lists)
+	}, nil
+}
+
+// IsAllowedDestination tells whether the urls with the host can be shortened: the host must not match
+// any denied rule and it must match an allowed one unless there are none
+func IsAllowedDestination(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(ASCIIHost(host)), ".")
+	ip := net.ParseIP(host)
+	lists := destinationDomains.Load().(*domainLists)
+	for _, rule := range lists.deny {
+		if rule.matches(host, ip) {
+			return false
+		}
+	}
+	if len(lists.allow) == 0 {
+		return true
+	}
+	for _, rule := range lists.allow {
+		if rule.matches(host, ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateURLDomain implements validator.Func
+func ValidateURLDomain(fl validator.FieldLevel) bool {
+	v := fl.Field().String()
+	if v == "" {
+		return true
+	}
+
+	u, err := url.Parse(v)
+	if err != nil {
+		return false
+	}
+
+	return IsAllowedDestination(u.Hostname())
+}
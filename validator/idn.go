@@ -0,0 +1,68 @@
+package validator
+
+import (
+	"net"
+	"net/url"
+
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/net/idna"
+)
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ASCIIHost converts an internationalized host name to its punycode form (e.g. xn--bcher-kva.example),
+// the ascii hosts and the ones that can't be converted are returned as they are
+func ASCIIHost(host string) string {
+	if isASCII(host) {
+		return host
+	}
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return host
+	}
+
+	return ascii
+}
+
+// NormalizeURL converts the internationalized host name of the url to punycode (see ASCIIHost),
+// the urls with the ascii hosts and the invalid ones are returned as they are
+func NormalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || isASCII(u.Host) {
+		return rawURL
+	}
+	host := ASCIIHost(u.Hostname())
+	if port := u.Port(); port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+	u.Host = host
+
+	return u.String()
+}
+
+// ValidateURLIDNHost implements validator.Func
+func ValidateURLIDNHost(fl validator.FieldLevel) bool {
+	v := fl.Field().String()
+	if v == "" {
+		return true
+	}
+
+	u, err := url.Parse(v)
+	if err != nil {
+		return false
+	}
+	if isASCII(u.Hostname()) {
+		return true
+	}
+	_, err = idna.Lookup.ToASCII(u.Hostname())
+
+	return err == nil
+}
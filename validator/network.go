@@ -0,0 +1,243 @@
+package validator
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-extras/errors"
+	"github.com/go-playground/validator/v10"
+)
+
+// resolveTimeout limits the time of the destination host lookups
+const resolveTimeout = 2 * time.Second
+
+// redirectCheckTTL is how long the results of the destination checks on redirect are reused
+const redirectCheckTTL = time.Minute
+
+// maxRedirectChecks limits the number of the cached results of the destination checks on redirect
+const maxRedirectChecks = 10000
+
+// ErrNonPublicDestination is returned when a destination host is (or resolves to) a non-public address
+var ErrNonPublicDestination = errors.New("not a public address")
+
+// Resolver looks up the ip addresses of the hosts, it's satisfied by *net.Resolver
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// nonPublicNetworks are the loopback, link-local, private and reserved address blocks
+var nonPublicNetworks = mustParseCIDRs(
+	// IPv4
+	"0.0.0.0/8",       // "this" network
+	"10.0.0.0/8",      // private
+	"100.64.0.0/10",   // carrier-grade NAT
+	"127.0.0.0/8",     // loopback
+	"169.254.0.0/16",  // link-local (incl. cloud metadata services)
+	"172.16.0.0/12",   // private
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // documentation
+	"192.88.99.0/24",  // 6to4 relay anycast
+	"192.168.0.0/16",  // private
+	"198.18.0.0/15",   // benchmarking
+	"198.51.100.0/24", // documentation
+	"203.0.113.0/24",  // documentation
+	"224.0.0.0/4",     // multicast
+	"240.0.0.0/4",     // reserved, incl. broadcast
+	// IPv6 (the IPv4-mapped addresses are checked as IPv4 ones)
+	"::/128",        // unspecified
+	"::1/128",       // loopback
+	"64:ff9b::/96",  // IPv4/IPv6 translation
+	"100::/64",      // discard
+	"2001::/23",     // IETF protocol assignments
+	"2001:db8::/32", // documentation
+	"2002::/16",     // 6to4
+	"fc00::/7",      // unique local
+	"fe80::/10",     // link-local
+	"ff00::/8",      // multicast
+)
+
+func mustParseCIDRs(blocks ...string) []*net.IPNet {
+	result := make([]*net.IPNet, 0, len(blocks))
+	for _, block := range blocks {
+		_, network, err := net.ParseCIDR(block)
+		if err != nil {
+			panic(err) // this should never happen
+		}
+		result = append(result, network)
+	}
+
+	return result
+}
+
+// IsPublicIP tells whether the ip address doesn't belong to a loopback, link-local, private or reserved block
+func IsPublicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// privateDestinations defines whether the destinations in the private networks are rejected
+type privateDestinations struct {
+	deny     bool
+	resolver Resolver
+	// redirectChecks are the results of the checks on redirect, they are dropped with the settings
+	redirectChecks *destinationChecks
+}
+
+// currentPrivateDestinations holds the current *privateDestinations
+var currentPrivateDestinations atomic.Value
+
+func init() {
+	currentPrivateDestinations.Store(newPrivateDestinations(false, net.DefaultResolver))
+}
+
+func newPrivateDestinations(deny bool, resolver Resolver) *privateDestinations {
+	return &privateDestinations{
+		deny:           deny,
+		resolver:       resolver,
+		redirectChecks: &destinationChecks{results: make(map[string]destinationCheck)},
+	}
+}
+
+// SetDenyPrivateDestinations enables or disables the rejection of the destinations in the private networks
+func SetDenyPrivateDestinations(deny bool) {
+	current := currentPrivateDestinations.Load().(*privateDestinations)
+	currentPrivateDestinations.Store(newPrivateDestinations(deny, current.resolver))
+}
+
+// SetResolver replaces the resolver of the destination hosts (net.DefaultResolver by default)
+func SetResolver(resolver Resolver) {
+	current := currentPrivateDestinations.Load().(*privateDestinations)
+	currentPrivateDestinations.Store(newPrivateDestinations(current.deny, resolver))
+}
+
+// destinationCheck is a cached result of a destination check
+type destinationCheck struct {
+	err     error
+	expires time.Time
+}
+
+// destinationChecks caches the results of the destination checks by the hosts
+type destinationChecks struct {
+	lock    sync.Mutex
+	results map[string]destinationCheck
+}
+
+func (c *destinationChecks) get(host string, now time.Time) (destinationCheck, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	result, ok := c.results[host]
+	if !ok || now.After(result.expires) {
+		return destinationCheck{}, false
+	}
+
+	return result, true
+}
+
+func (c *destinationChecks) put(host string, err error, now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.results) >= maxRedirectChecks {
+		// the cache is only meant for the hot links, it's simpler to start over than to track the usage
+		c.results = make(map[string]destinationCheck)
+	}
+	c.results[host] = destinationCheck{err: err, expires: now.Add(redirectCheckTTL)}
+}
+
+// CheckPublicDestination returns an error if the private destinations are denied and the url host is
+// (or resolves to) a non-public ip address (ErrNonPublicDestination is the cause then), or if it can't be resolved
+func CheckPublicDestination(ctx context.Context, rawURL string) error {
+	p := currentPrivateDestinations.Load().(*privateDestinations)
+	if !p.deny {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	return p.checkHost(ctx, destinationHost(u))
+}
+
+// CheckRedirectDestination is CheckPublicDestination for the redirects, the links were checked when they were stored,
+// so it only fails if the url host is now known to be (or resolve to) a non-public ip address: the lookup failures
+// are ignored, and the results of the lookups are reused for a while to keep them off the hot path
+func CheckRedirectDestination(ctx context.Context, rawURL string) error {
+	p := currentPrivateDestinations.Load().(*privateDestinations)
+	if !p.deny {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	host := destinationHost(u)
+	now := time.Now()
+	if result, ok := p.redirectChecks.get(host, now); ok {
+		return result.err
+	}
+	err = p.checkHost(ctx, host)
+	if err != nil && errors.Cause(err) != ErrNonPublicDestination {
+		return nil
+	}
+	p.redirectChecks.put(host, err, now)
+
+	return err
+}
+
+// destinationHost returns the host of the url in the form the checks expect
+func destinationHost(u *url.URL) string {
+	return strings.TrimSuffix(ASCIIHost(u.Hostname()), ".")
+}
+
+// checkHost implements CheckPublicDestination for the host of the url
+func (p *privateDestinations) checkHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if !IsPublicIP(ip) {
+			return errors.Wrapf(ErrNonPublicDestination, "%s", ip)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	addrs, err := p.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return errors.Wrapf(err, "can't resolve %s", host)
+	}
+	if len(addrs) == 0 {
+		return errors.Errorf("%s has no addresses", host)
+	}
+	// a single non-public address is enough, as the clients may pick any of them
+	for _, addr := range addrs {
+		if !IsPublicIP(addr.IP) {
+			return errors.Wrapf(ErrNonPublicDestination, "%s resolves to %s", host, addr.IP)
+		}
+	}
+
+	return nil
+}
+
+// ValidateURLPublicHost implements validator.Func
+func ValidateURLPublicHost(fl validator.FieldLevel) bool {
+	v := fl.Field().String()
+	if v == "" {
+		return true
+	}
+
+	return CheckPublicDestination(context.Background(), v) == nil
+}
@@ -0,0 +1,105 @@
+package validator
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-extras/errors"
+)
+
+// DefaultRedirectChainDepth is the default number of the short links a link may lead through
+const DefaultRedirectChainDepth = 3
+
+// RedirectChains defines how the links to the short links of the service itself are handled
+type RedirectChains struct {
+	// Hosts the short links are served on, a host without a port matches any port
+	Hosts []string
+	// MaxDepth limits the number of the short links a link may lead through, 0 rejects all the links to the hosts
+	MaxDepth int
+	// Flatten makes the links lead to the final destination of the chain instead of the short link
+	Flatten bool
+}
+
+// currentRedirectChains holds the current *RedirectChains
+var currentRedirectChains atomic.Value
+
+func init() {
+	currentRedirectChains.Store(&RedirectChains{MaxDepth: DefaultRedirectChainDepth})
+}
+
+// SetRedirectChains replaces the redirect chain settings, the hosts are normalized (lowercased, punycode)
+func SetRedirectChains(chains RedirectChains) error {
+	set, err := PrepareRedirectChains(chains)
+	if err != nil {
+		return err
+	}
+	set()
+
+	return nil
+}
+
+// PrepareRedirectChains checks the settings like SetRedirectChains does and returns the function replacing them
+// (see PrepareReservedShortNames)
+func PrepareRedirectChains(chains RedirectChains) (func(), error) {
+	if chains.MaxDepth < 0 {
+		return nil, errors.New("redirect chain depth must not be negative")
+	}
+	hosts := make([]string, 0, len(chains.Hosts))
+	for _, host := range chains.Hosts {
+		host = strings.TrimSpace(host)
+		if hostname, port, err := net.SplitHostPort(host); err == nil {
+			host = net.JoinHostPort(strings.TrimSuffix(ASCIIHost(hostname), "."), port)
+		}
+		host = strings.TrimSuffix(strings.ToLower(ASCIIHost(host)), ".")
+		if host == "" || strings.ContainsAny(host, "/?#") {
+			return nil, errors.Errorf("invalid self host %q, a host with an optional port is expected", host)
+		}
+		hosts = append(hosts, host)
+	}
+	chains.Hosts = hosts
+
+	return func() {
+		currentRedirectChains.Store(&chains)
+	}, nil
+}
+
+// CurrentRedirectChains returns the current redirect chain settings
+func CurrentRedirectChains() RedirectChains {
+	return *currentRedirectChains.Load().(*RedirectChains)
+}
+
+// isSelfHost tells whether the url points to one of the hosts
+func (c RedirectChains) isSelfHost(u *url.URL) bool {
+	hostname := strings.TrimSuffix(strings.ToLower(ASCIIHost(u.Hostname())), ".")
+	host := hostname
+	if port := u.Port(); port != "" {
+		host += ":" + port
+	}
+	for _, selfHost := range c.Hosts {
+		if selfHost == hostname || selfHost == host {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SelfShortName returns the short name the url points to if the url is a short link of the service itself
+// (the short name is taken from the path as the redirects do, the service root is not a short link)
+func (c RedirectChains) SelfShortName(rawURL string) (string, bool) {
+	if len(c.Hosts) == 0 {
+		return "", false
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || !c.isSelfHost(u) {
+		return "", false
+	}
+	shortName := strings.Trim(u.Path, "/ ")
+	if shortName == "" {
+		return "", false
+	}
+
+	return shortName, true
+}
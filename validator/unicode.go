@@ -0,0 +1,169 @@
+package validator
+
+import (
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/go-extras/errors"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// caseInsensitiveShortNames holds whether the short names are case-insensitive (bool)
+var caseInsensitiveShortNames atomic.Value
+
+func init() {
+	caseInsensitiveShortNames.Store(false)
+}
+
+// SetCaseInsensitiveShortNames makes the short names case-insensitive (see NormalizeShortName), it must be set
+// before the storages are used as it changes the form the short names are stored in
+func SetCaseInsensitiveShortNames(enabled bool) {
+	caseInsensitiveShortNames.Store(enabled)
+}
+
+// CaseInsensitiveShortNames tells whether the short names are case-insensitive
+func CaseInsensitiveShortNames() bool {
+	return caseInsensitiveShortNames.Load().(bool)
+}
+
+// NormalizeShortName returns the NFC form of the short name (case-folded if the short names are case-insensitive),
+// the short names are stored and looked up in this form so that the different byte sequences of the same text
+// lead to the same link
+func NormalizeShortName(name string) string {
+	if CaseInsensitiveShortNames() {
+		return FoldShortName(name)
+	}
+
+	return norm.NFC.String(name)
+}
+
+// FoldShortName returns the case-folded NFC form of the short name (e.g. "Straße" gives "strasse"),
+// the short names that differ in case only have the same folded form
+func FoldShortName(name string) string {
+	return norm.NFC.String(cases.Fold().String(norm.NFC.String(name)))
+}
+
+// confusables map the most common Cyrillic and Greek homoglyphs of the Latin letters to them
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'е': 'e', 'ѕ': 's', 'і': 'i', 'ј': 'j', 'о': 'o', 'р': 'p', 'с': 'c', 'у': 'y', 'х': 'x',
+	'ԁ': 'd', 'һ': 'h', 'ԛ': 'q', 'ԝ': 'w', 'ӏ': 'l',
+	'А': 'A', 'В': 'B', 'Е': 'E', 'Ѕ': 'S', 'І': 'I', 'Ј': 'J', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O', 'Р': 'P',
+	'С': 'C', 'Т': 'T', 'Х': 'X', 'Ү': 'Y',
+	// Greek
+	'α': 'a', 'ι': 'i', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'υ': 'u',
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M', 'Ν': 'N', 'Ο': 'O', 'Ρ': 'P',
+	'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+}
+
+// ShortNameSkeleton returns the form of the short name the look-alike names share: the compatibility chars
+// (e.g. fullwidth letters) are decomposed (NFKC) and the Cyrillic and Greek homoglyphs are replaced with
+// the Latin letters, e.g. "аpі" with the Cyrillic "а" and "і" gives "api"
+func ShortNameSkeleton(name string) string {
+	return strings.Map(func(r rune) rune {
+		if latin, ok := confusables[r]; ok {
+			return latin
+		}
+		return r
+	}, norm.NFKC.String(name))
+}
+
+// scriptOf returns the unicode script of the char, or an empty string for the chars shared
+// by the scripts (Common and Inherited, e.g. the digits, the dashes and the combining marks)
+func scriptOf(r rune) string {
+	for name, table := range unicode.Scripts {
+		if name != "Common" && name != "Inherited" && unicode.Is(table, r) {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// scriptCombinations are the sets of the scripts that may be mixed in a short name,
+// as the languages use them together (the "highly restrictive" level of Unicode TR 39)
+var scriptCombinations = []map[string]bool{
+	{"Latin": true, "Han": true, "Hiragana": true, "Katakana": true},
+	{"Latin": true, "Han": true, "Bopomofo": true},
+	{"Latin": true, "Han": true, "Hangul": true},
+}
+
+// shortNameScripts holds the scripts allowed in the short names (map[string]bool), all are allowed if it's empty
+var shortNameScripts atomic.Value
+
+func init() {
+	shortNameScripts.Store(map[string]bool{})
+}
+
+// SetShortNameScripts replaces the unicode scripts the letters of the short names must belong to
+// (the names of unicode.Scripts, case-insensitive), all the scripts are allowed if none is given.
+// Nothing is replaced if any of the scripts is unknown.
+func SetShortNameScripts(scripts []string) error {
+	set, err := PrepareShortNameScripts(scripts)
+	if err != nil {
+		return err
+	}
+	set()
+
+	return nil
+}
+
+// PrepareShortNameScripts checks the scripts like SetShortNameScripts does and returns the function replacing them
+// (see PrepareReservedShortNames)
+func PrepareShortNameScripts(scripts []string) (func(), error) {
+	allowed := make(map[string]bool, len(scripts))
+	for _, script := range scripts {
+		found := false
+		for name := range unicode.Scripts {
+			if strings.EqualFold(name, strings.TrimSpace(script)) {
+				allowed[name] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("unknown unicode script %s", script)
+		}
+	}
+
+	return func() {
+		shortNameScripts.Store(allowed)
+	}, nil
+}
+
+// hasAllowedScripts tells whether all the letters of the short name belong to the allowed scripts
+// and the scripts are not mixed (except for the combinations used by the languages, see scriptCombinations)
+func hasAllowedScripts(name string) bool {
+	allowed := shortNameScripts.Load().(map[string]bool)
+	scripts := make(map[string]bool)
+	for _, r := range name {
+		script := scriptOf(r)
+		if script == "" {
+			continue
+		}
+		if len(allowed) > 0 && !allowed[script] {
+			return false
+		}
+		scripts[script] = true
+	}
+	if len(scripts) <= 1 {
+		return true
+	}
+
+	for _, combination := range scriptCombinations {
+		mixable := true
+		for script := range scripts {
+			if !combination[script] {
+				mixable = false
+				break
+			}
+		}
+		if mixable {
+			return true
+		}
+	}
+
+	return false
+}
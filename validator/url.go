@@ -0,0 +1,55 @@
+package validator
+
+import (
+	"net/url"
+	"strings"
+)
+
+// defaultPorts are the ports omitted in the canonical urls
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// CanonicalURL returns the canonical form of the url, so that the urls that differ trivially
+// (host case and internationalized form, default port, trailing slash, order of the query parameters) have the same one
+func CanonicalURL(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.TrimSuffix(strings.ToLower(ASCIIHost(u.Hostname())), ".")
+	if strings.Contains(host, ":") {
+		// ipv6 address
+		host = "[" + host + "]"
+	}
+	if port := u.Port(); port != "" && port != defaultPorts[u.Scheme] {
+		host += ":" + port
+	}
+	u.Host = host
+
+	switch {
+	case u.Path == "" && u.Host != "":
+		u.Path = "/"
+		u.RawPath = ""
+	case len(u.Path) > 1 && strings.HasSuffix(u.Path, "/"):
+		u.Path = strings.TrimRight(u.Path, "/")
+		if u.Path == "" {
+			u.Path = "/"
+		}
+		u.RawPath = ""
+	}
+
+	if u.RawQuery != "" {
+		query, err := url.ParseQuery(u.RawQuery)
+		if err == nil {
+			// the values of the same parameter keep their order, as it may matter
+			u.RawQuery = query.Encode()
+		}
+	}
+	u.ForceQuery = false
+
+	return u.String(), nil
+}
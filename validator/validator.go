@@ -1,30 +1,181 @@
 package validator
 
 import (
+	"github.com/go-extras/errors"
 	"github.com/go-playground/validator/v10"
 	"net/url"
+	"reflect"
 	"regexp"
+	"strings"
+	"sync/atomic"
 )
 
-const urlShortNameString = "^[a-zA-Z0-9\\-]+$"
-const blackListedValuesString = "^(api|swagger|metrics)$"
+// urlShortNameString allows the letters of any script, the digits and the dashes, the combining marks can't go first
+const urlShortNameString = `^[\p{L}\p{Nd}\-][\p{L}\p{M}\p{Nd}\-]*$`
 
 var urlShortNameRegex = regexp.MustCompile(urlShortNameString)
-var blackListedValuesRegex = regexp.MustCompile(blackListedValuesString)
+
+// defaultRouteShortNames are the top-level routes of the server when all of them are served on the same listener
+var defaultRouteShortNames = []string{"api", "swagger", "metrics", "healthz", "readyz"}
+
+// routeShortNames holds the short names taken by the top-level routes of the server (map[string]bool)
+var routeShortNames atomic.Value
+
+// reserved defines the configured reserved short names
+type reserved struct {
+	names map[string]bool
+	// folded are the case-folded names, they are used if the short names are case-insensitive
+	folded   map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// reservedShortNames holds the configured reserved short names (*reserved),
+// it's replaced as a whole so that it can be reloaded while the requests are validated
+var reservedShortNames atomic.Value
+
+func init() {
+	SetRouteShortNames(defaultRouteShortNames)
+	reservedShortNames.Store(&reserved{})
+}
+
+// SetRouteShortNames replaces the short names taken by the top-level routes of the server
+func SetRouteShortNames(names []string) {
+	routeShortNames.Store(stringSet(names))
+}
+
+// SetReservedShortNames replaces the short names that are reserved in addition to the route ones,
+// a short name is reserved if it's one of the names or if it matches one of the patterns as a whole.
+// Nothing is replaced if any of the patterns is invalid.
+func SetReservedShortNames(names []string, patterns []string) error {
+	set, err := PrepareReservedShortNames(names, patterns)
+	if err != nil {
+		return err
+	}
+	set()
+
+	return nil
+}
+
+// PrepareReservedShortNames checks the reserved short names like SetReservedShortNames does and returns
+// the function replacing them, so that several settings can be checked before any of them is replaced
+func PrepareReservedShortNames(names []string, patterns []string) (func(), error) {
+	r := &reserved{
+		names:    stringSet(names),
+		folded:   make(map[string]bool, len(names)),
+		patterns: make([]*regexp.Regexp, 0, len(patterns)),
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid reserved short name pattern %s", pattern)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	for _, name := range names {
+		r.folded[FoldShortName(name)] = true
+	}
+
+	return func() {
+		reservedShortNames.Store(r)
+	}, nil
+}
+
+// IsReservedShortName tells whether the short name is taken by a route or reserved by the configuration,
+// if the short names are case-insensitive the names are compared case-folded and the patterns are matched
+// against both the given and the folded short name
+func IsReservedShortName(name string) bool {
+	r := reservedShortNames.Load().(*reserved)
+	names := []string{name}
+	if CaseInsensitiveShortNames() {
+		folded := FoldShortName(name)
+		if r.folded[folded] {
+			return true
+		}
+		names = append(names, folded)
+	}
+	for _, name := range names {
+		if routeShortNames.Load().(map[string]bool)[name] || r.names[name] {
+			return true
+		}
+		for _, re := range r.patterns {
+			if re.MatchString(name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+
+	return set
+}
+
+// New creates a validator with the custom validations used by the link model registered
+func New() *validator.Validate {
+	validate := validator.New()
+	// the errors refer to the fields by their json names (the api attributes), FieldError.StructField() gives the go ones
+	validate.RegisterTagNameFunc(jsonFieldName)
+	err := validate.RegisterValidation("shortname", ValidateURLShortName)
+	if err != nil {
+		panic(err) // this should never happen
+	}
+	err = validate.RegisterValidation("urlscheme", ValidateURLScheme)
+	if err != nil {
+		panic(err) // this should never happen
+	}
+	err = validate.RegisterValidation("idnhost", ValidateURLIDNHost)
+	if err != nil {
+		panic(err) // this should never happen
+	}
+	err = validate.RegisterValidation("urldomain", ValidateURLDomain)
+	if err != nil {
+		panic(err) // this should never happen
+	}
+	err = validate.RegisterValidation("notblocked", ValidateURLNotBlocked)
+	if err != nil {
+		panic(err) // this should never happen
+	}
+	err = validate.RegisterValidation("publichost", ValidateURLPublicHost)
+	if err != nil {
+		panic(err) // this should never happen
+	}
+
+	return validate
+}
+
+// jsonFieldName returns the json name of the struct field (the relationship name of the fields
+// given as a JSON:API relationship), or an empty string for the go name
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return field.Tag.Get("relationship")
+	}
+
+	return name
+}
 
 // ValidateURLShortName implements validator.Func
 func ValidateURLShortName(fl validator.FieldLevel) bool {
-	v := fl.Field().String()
+	v := NormalizeShortName(fl.Field().String())
 	if v == "" {
 		return true
 	}
 
-	if blackListedValuesRegex.MatchString(v) {
-		// blacklisted value
+	if !urlShortNameRegex.MatchString(v) {
+		return false
+	}
+	// the look-alikes of the reserved names would be confusing too, e.g. "аpі" with the Cyrillic letters
+	if IsReservedShortName(v) || IsReservedShortName(ShortNameSkeleton(v)) {
 		return false
 	}
 
-	return urlShortNameRegex.MatchString(v)
+	return hasAllowedScripts(v)
 }
 
 // ValidateURLScheme implements validator.Func
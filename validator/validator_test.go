@@ -1,8 +1,11 @@
 package validator_test
 
 import (
+	"context"
 	"fmt"
+	"github.com/go-extras/errors"
 	"github.com/go-playground/validator/v10"
+	"net"
 
 	. "github.com/denisvmedia/urlshortener/validator"
 	. "github.com/onsi/ginkgo"
@@ -60,6 +63,8 @@ var _ = Describe("Functional Tests", func() {
 				"api",
 				"swagger",
 				"metrics",
+				"healthz",
+				"readyz",
 			}
 			for _, value := range validValues {
 				By(fmt.Sprintf("should BOT accept blacklisted value %s", value))
@@ -67,6 +72,328 @@ var _ = Describe("Functional Tests", func() {
 				Expect(err).To(HaveOccurred(), "should NOT have accepted invalid value %s", value)
 			}
 		})
+
+		It("Should only reject the configured route names", func() {
+			defer SetRouteShortNames([]string{"api", "swagger", "metrics", "healthz", "readyz"})
+
+			SetRouteShortNames([]string{"api"})
+			Expect(validate.Var("api", "shortname")).To(HaveOccurred())
+			Expect(validate.Var("metrics", "shortname")).To(Succeed())
+			Expect(validate.Var("swagger", "shortname")).To(Succeed())
+		})
+
+		It("Should fail to validate the configured reserved values", func() {
+			defer SetReservedShortNames(nil, nil)
+
+			Expect(validate.Var("admin", "shortname")).To(Succeed())
+			Expect(SetReservedShortNames([]string{"admin", "login"}, []string{"team-.+", "[0-9]{3}"})).To(Succeed())
+			for _, value := range []string{"admin", "login", "team-a", "404"} {
+				Expect(validate.Var(value, "shortname")).To(HaveOccurred(), value)
+			}
+			// the patterns must match the whole short name
+			for _, value := range []string{"my-team-a", "4040", "team-"} {
+				Expect(validate.Var(value, "shortname")).To(Succeed(), value)
+			}
+
+			Expect(SetReservedShortNames([]string{"other"}, []string{"("})).To(MatchError(ContainSubstring("invalid reserved short name pattern")))
+			Expect(validate.Var("admin", "shortname")).To(HaveOccurred(), "the previous values must be kept")
+			Expect(SetReservedShortNames(nil, nil)).To(Succeed())
+			Expect(validate.Var("admin", "shortname")).To(Succeed())
+		})
+	})
+
+	Context("Unicode short names", func() {
+		var validate *validator.Validate
+
+		BeforeEach(func() {
+			validate = New()
+		})
+
+		AfterEach(func() {
+			Expect(SetShortNameScripts(nil)).To(Succeed())
+		})
+
+		It("Should accept the letters of any script", func() {
+			for _, value := range []string{"привет", "café", "cafe\u0301", "東京-2020", "とうきょう", "서울", "مرحبا", "ελληνικά", "東京タワー", "tokyo-東京"} {
+				Expect(validate.Var(value, "shortname")).To(Succeed(), value)
+			}
+		})
+
+		It("Should reject the mixed scripts and the look-alikes of the reserved names", func() {
+			for _, value := range []string{
+				"pаypal",           // Cyrillic "а"
+				"привет-hello",     // Cyrillic and Latin
+				"\u0301abc",        // a combining mark first
+				"арі",              // all Cyrillic "api"
+				"ａｐｉ",              // fullwidth "api"
+				"hello\u200bworld", // zero width space
+				"emoji-\U0001F600", // not a letter
+			} {
+				Expect(validate.Var(value, "shortname")).To(HaveOccurred(), value)
+			}
+		})
+
+		It("Should only accept the configured scripts", func() {
+			Expect(SetShortNameScripts([]string{"latin", "Cyrillic"})).To(Succeed())
+			Expect(validate.Var("привет", "shortname")).To(Succeed())
+			Expect(validate.Var("hello-2", "shortname")).To(Succeed())
+			Expect(validate.Var("東京", "shortname")).To(HaveOccurred())
+			Expect(validate.Var("ελληνικά", "shortname")).To(HaveOccurred())
+
+			Expect(SetShortNameScripts([]string{"Klingon"})).To(MatchError(ContainSubstring("unknown unicode script Klingon")))
+			Expect(validate.Var("東京", "shortname")).To(HaveOccurred(), "the previous scripts must be kept")
+		})
+
+		It("Should normalize the short names", func() {
+			Expect(NormalizeShortName("cafe\u0301")).To(Equal("café"))
+			Expect(ShortNameSkeleton("раураl")).To(Equal("paypal"))
+			Expect(ShortNameSkeleton("ＡＰＩ")).To(Equal("API"))
+			Expect(ShortNameSkeleton("привет")).To(Equal("пpивeт"))
+		})
+	})
+
+	Context("Case-insensitive short names", func() {
+		var validate *validator.Validate
+
+		BeforeEach(func() {
+			validate = New()
+			Expect(SetReservedShortNames([]string{"Admin"}, []string{"[a-z]+-old"})).To(Succeed())
+		})
+
+		AfterEach(func() {
+			SetCaseInsensitiveShortNames(false)
+			Expect(SetReservedShortNames(nil, nil)).To(Succeed())
+		})
+
+		It("Should fold the short names", func() {
+			Expect(FoldShortName("Hello")).To(Equal("hello"))
+			Expect(FoldShortName("Straße")).To(Equal("strasse"))
+			Expect(FoldShortName("ΣΟΦΙΑ")).To(Equal(FoldShortName("σοφια")))
+			Expect(FoldShortName("CAFÉ")).To(Equal("café"))
+
+			Expect(NormalizeShortName("Hello")).To(Equal("Hello"))
+			SetCaseInsensitiveShortNames(true)
+			Expect(CaseInsensitiveShortNames()).To(BeTrue())
+			Expect(NormalizeShortName("Hello")).To(Equal("hello"))
+		})
+
+		It("Should compare the reserved names case-folded", func() {
+			for _, value := range []string{"API", "Swagger", "ADMIN", "Abc-OLD"} {
+				Expect(validate.Var(value, "shortname")).To(Succeed(), value)
+			}
+
+			SetCaseInsensitiveShortNames(true)
+			for _, value := range []string{"API", "Swagger", "admin", "ADMIN", "Abc-OLD", "аРІ"} {
+				Expect(validate.Var(value, "shortname")).To(HaveOccurred(), value)
+			}
+			Expect(validate.Var("Hello", "shortname")).To(Succeed())
+		})
+	})
+
+	Context("Internationalized domain names", func() {
+		var validate *validator.Validate
+
+		BeforeEach(func() {
+			validate = New()
+		})
+
+		AfterEach(func() {
+			Expect(SetDestinationDomains(nil, nil)).To(Succeed())
+		})
+
+		It("Should convert the hosts to punycode", func() {
+			Expect(ASCIIHost("Bücher.example")).To(Equal("xn--bcher-kva.example"))
+			Expect(ASCIIHost("example.com")).To(Equal("example.com"))
+			Expect(NormalizeURL("https://Bücher.example:8443/päth?q=ü#top")).To(Equal("https://xn--bcher-kva.example:8443/p%C3%A4th?q=ü#top"))
+			Expect(NormalizeURL("https://example.com/päth")).To(Equal("https://example.com/päth"))
+
+			canonical, err := CanonicalURL("https://bücher.example/")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(canonical).To(Equal("https://xn--bcher-kva.example/"))
+		})
+
+		It("Should validate the internationalized hosts", func() {
+			Expect(validate.Var("https://bücher.example/", "idnhost")).To(Succeed())
+			Expect(validate.Var("https://пример.рф/", "idnhost")).To(Succeed())
+			Expect(validate.Var("https://exa_mple.com/", "idnhost")).To(Succeed())
+			Expect(validate.Var("https://-bücher.example/", "idnhost")).To(HaveOccurred())
+			Expect(validate.Var("https://ü⒈.example/", "idnhost")).To(HaveOccurred())
+		})
+
+		It("Should apply the domain rules to the punycode form", func() {
+			Expect(SetDestinationDomains(nil, []string{"*.рф", "xn--bcher-kva.example"})).To(Succeed())
+			Expect(validate.Var("https://пример.рф/", "urldomain")).To(HaveOccurred())
+			Expect(validate.Var("https://xn--e1afmkfd.xn--p1ai/", "urldomain")).To(HaveOccurred())
+			Expect(validate.Var("https://BÜCHER.example/", "urldomain")).To(HaveOccurred())
+			Expect(validate.Var("https://example.com/", "urldomain")).To(Succeed())
+		})
+	})
+
+	Context("ValidateURLDomain", func() {
+		var validate *validator.Validate
+
+		BeforeEach(func() {
+			validate = New()
+		})
+
+		AfterEach(func() {
+			Expect(SetDestinationDomains(nil, nil)).To(Succeed())
+		})
+
+		It("Should allow everything by default", func() {
+			Expect(validate.Var("https://example.com/", "urldomain")).To(Succeed())
+			Expect(validate.Var("http://10.0.0.1/", "urldomain")).To(Succeed())
+		})
+
+		It("Should reject the denied domains", func() {
+			Expect(SetDestinationDomains(nil, []string{"evil.com", "*.phish.net", "10.0.0.0/8", "2001:db8::/32", "192.168.1.1"})).To(Succeed())
+			for _, value := range []string{
+				"https://evil.com/",
+				"https://EVIL.com./page",
+				"https://login.phish.net/",
+				"https://a.b.phish.net/",
+				"http://10.1.2.3:8080/",
+				"http://[2001:db8::1]/",
+				"http://192.168.1.1/",
+			} {
+				Expect(validate.Var(value, "urldomain")).To(HaveOccurred(), value)
+			}
+			for _, value := range []string{
+				"https://notevil.com/",
+				"https://evil.com.example.org/",
+				"https://phish.net/",
+				"http://11.0.0.1/",
+				"http://192.168.1.2/",
+			} {
+				Expect(validate.Var(value, "urldomain")).To(Succeed(), value)
+			}
+		})
+
+		It("Should only accept the allowed domains and prefer the denied ones", func() {
+			Expect(SetDestinationDomains([]string{"example.com", "*.example.com"}, []string{"internal.example.com"})).To(Succeed())
+			Expect(validate.Var("https://example.com/", "urldomain")).To(Succeed())
+			Expect(validate.Var("https://www.example.com/", "urldomain")).To(Succeed())
+			Expect(validate.Var("https://internal.example.com/", "urldomain")).To(HaveOccurred())
+			Expect(validate.Var("https://example.org/", "urldomain")).To(HaveOccurred())
+			Expect(validate.Var("http://127.0.0.1/", "urldomain")).To(HaveOccurred())
+		})
+
+		It("Should reject invalid rules and keep the previous lists", func() {
+			Expect(SetDestinationDomains(nil, []string{"evil.com"})).To(Succeed())
+			Expect(SetDestinationDomains(nil, []string{"10.0.0.0/33"})).To(MatchError(ContainSubstring("invalid domain rule")))
+			Expect(SetDestinationDomains([]string{"ex*ample.com"}, nil)).To(MatchError(ContainSubstring("invalid domain rule")))
+			Expect(validate.Var("https://evil.com/", "urldomain")).To(HaveOccurred())
+		})
+	})
+
+	Context("ValidateURLPublicHost", func() {
+		var validate *validator.Validate
+
+		BeforeEach(func() {
+			validate = New()
+			SetResolver(stubResolver{
+				"example.com":      {"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946"},
+				"localhost":        {"127.0.0.1"},
+				"metadata.example": {"169.254.169.254"},
+				"mixed.example":    {"93.184.216.34", "10.0.0.1"},
+			})
+		})
+
+		AfterEach(func() {
+			SetDenyPrivateDestinations(false)
+			SetResolver(net.DefaultResolver)
+		})
+
+		It("Should allow everything by default", func() {
+			Expect(validate.Var("http://169.254.169.254/latest/meta-data/", "publichost")).To(Succeed())
+			Expect(validate.Var("http://localhost/", "publichost")).To(Succeed())
+		})
+
+		It("Should reject the private destinations", func() {
+			SetDenyPrivateDestinations(true)
+			for _, value := range []string{
+				"http://169.254.169.254/latest/meta-data/",
+				"http://127.0.0.1:8080/",
+				"http://10.1.2.3/",
+				"http://[::1]/",
+				"http://[::ffff:127.0.0.1]/",
+				"http://[fd00::1]/",
+				"http://localhost/",
+				"http://localhost./",
+				"http://metadata.example/",
+				"http://mixed.example/",
+				"http://unknown.example/",
+			} {
+				Expect(validate.Var(value, "publichost")).To(HaveOccurred(), value)
+			}
+			for _, value := range []string{
+				"https://example.com/",
+				"http://8.8.8.8/",
+				"http://[2606:4700:4700::1111]/",
+			} {
+				Expect(validate.Var(value, "publichost")).To(Succeed(), value)
+			}
+		})
+
+		It("Should explain the rejection", func() {
+			SetDenyPrivateDestinations(true)
+			Expect(CheckPublicDestination(context.Background(), "http://metadata.example/")).To(MatchError(ContainSubstring("169.254.169.254")))
+			Expect(CheckPublicDestination(context.Background(), "http://unknown.example/")).To(MatchError(ContainSubstring("can't resolve unknown.example")))
+		})
+
+		It("Should only reject the redirects to the known non-public destinations and reuse the lookups", func() {
+			resolver := &countingResolver{resolver: stubResolver{
+				"example.com":      {"93.184.216.34"},
+				"metadata.example": {"169.254.169.254"},
+			}, lookups: map[string]int{}}
+			SetResolver(resolver)
+			Expect(CheckRedirectDestination(context.Background(), "http://metadata.example/")).To(Succeed(), "nothing is checked by default")
+
+			SetDenyPrivateDestinations(true)
+			for i := 0; i < 3; i++ {
+				err := CheckRedirectDestination(context.Background(), "http://metadata.example/latest")
+				Expect(errors.Cause(err)).To(Equal(ErrNonPublicDestination))
+				Expect(CheckRedirectDestination(context.Background(), "https://example.com/page")).To(Succeed())
+				Expect(CheckRedirectDestination(context.Background(), "http://unknown.example/")).To(Succeed(), "the lookup failures are ignored")
+				Expect(errors.Cause(CheckRedirectDestination(context.Background(), "http://127.0.0.1/"))).To(Equal(ErrNonPublicDestination))
+			}
+			Expect(resolver.lookups).To(Equal(map[string]int{
+				"metadata.example": 1,
+				"example.com":      1,
+				"unknown.example":  3,
+			}), "only the successful lookups are reused")
+
+			// the settings changes drop the results
+			SetDenyPrivateDestinations(true)
+			Expect(errors.Cause(CheckRedirectDestination(context.Background(), "http://metadata.example/"))).To(Equal(ErrNonPublicDestination))
+			Expect(resolver.lookups["metadata.example"]).To(Equal(2))
+		})
+	})
+
+	Context("CanonicalURL", func() {
+		It("Should give the same canonical url to the trivially different urls", func() {
+			urls := map[string]string{
+				"https://example.com/page":                      "https://example.com/page",
+				"HTTPS://EXAMPLE.COM/page/":                     "https://example.com/page",
+				"https://example.com:443/page":                  "https://example.com/page",
+				"http://example.com:80":                         "http://example.com/",
+				"http://example.com:8080/":                      "http://example.com:8080/",
+				"https://example.com./page?b=2&a=1&a=0":         "https://example.com/page?a=1&a=0&b=2",
+				"https://example.com/page?":                     "https://example.com/page",
+				"https://example.com/Page#Section":              "https://example.com/Page#Section",
+				"https://[2001:DB8::1]:443/":                    "https://[2001:db8::1]/",
+				"https://user@example.com/a%20b/":               "https://user@example.com/a%20b",
+				"https://example.com/search?q=a+b&lang=en%2Dus": "https://example.com/search?lang=en-us&q=a+b",
+			}
+			for rawURL, expected := range urls {
+				Expect(CanonicalURL(rawURL)).To(Equal(expected), rawURL)
+			}
+		})
+
+		It("Should fail on invalid urls", func() {
+			_, err := CanonicalURL("https://exa mple.com:port/")
+			Expect(err).To(HaveOccurred())
+		})
 	})
 
 	Context("ValidateURLScheme", func() {
@@ -110,3 +437,30 @@ var _ = Describe("Functional Tests", func() {
 		})
 	})
 })
+
+// countingResolver counts the lookups of the hosts
+type countingResolver struct {
+	resolver Resolver
+	lookups  map[string]int
+}
+
+func (r *countingResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.lookups[host]++
+	return r.resolver.LookupIPAddr(ctx, host)
+}
+
+// stubResolver resolves the hosts from the map, the rest are not found
+type stubResolver map[string][]string
+
+func (r stubResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	result := make([]net.IPAddr, 0, len(ips))
+	for _, ip := range ips {
+		result = append(result, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+
+	return result, nil
+}